@@ -1,16 +1,25 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/base64"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"runtime"
+	"sort"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/mtnmunuklu/logen/sigma"
 	"github.com/mtnmunuklu/logen/sigma/sevaluator"
+	"github.com/mtnmunuklu/logen/sigma/sevaluator/formatters"
+	"github.com/mtnmunuklu/logen/sigma/sevaluator/modifiers"
 )
 
 var (
@@ -23,8 +32,36 @@ var (
 	version       bool
 	caseSensitive bool
 	apiKey        string
+	llmBackend    string
+	llmModel      string
+	llmBaseURL    string
+	llmAPIVersion string
+	llmFixture    string
+	temperature   float64
+	maxTokens     int
+	timeout       time.Duration
+	outputFormat  string
+	writeEVTX     bool
+	negatives     bool
+	verify        bool
+	keywordFields string
+	startTime     string
+	interval      time.Duration
+	jitter        time.Duration
+	distribution  string
+	timezone      string
+	outside       bool
+	seed          int64
+	workers       int
+	rateLimit     float64
+	retries       int
+	queryBackend  string
+	branches      int
 )
 
+// seededStart is the time of the first generated log of seeded runs that don't set a start time, so that their output is reproducible
+var seededStart = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
 // Set up the command-line flags
 func init() {
 	flag.StringVar(&filePath, "filepath", "", "Name or path of the file or directory to read")
@@ -33,9 +70,38 @@ func init() {
 	flag.StringVar(&configContent, "configcontent", "", "Base64-encoded content of the configuration file")
 	flag.BoolVar(&showHelp, "help", false, "Show usage")
 	flag.StringVar(&outputPath, "output", "", "Output directory for writing files")
+	flag.StringVar(&outputFormat, "format", "text", "Output format: text, auto (native format of each rule's logsource, or the rule's custom 'format' attribute), jsonl, json, xml, evtx, ecs, cef, leef, syslog, syslog3164, auditd, zeek or csv")
+	flag.BoolVar(&writeEVTX, "evtx", false, "Also write a binary .evtx file for windows rules to the output directory")
 	flag.BoolVar(&version, "version", false, "Show version information")
 	flag.BoolVar(&caseSensitive, "cs", false, "Case sensitive mode")
-	flag.StringVar(&apiKey, "apikey", "", "Api key for ChatGPT")
+	flag.StringVar(&keywordFields, "keywordfields", "", "Comma-separated free-text fields that keywords are searched in, overriding the config (default message)")
+	flag.BoolVar(&negatives, "negatives", false, "Also generate near-miss logs that must not trigger the rules")
+	flag.StringVar(&startTime, "start", "", "Time of the first generated log in RFC 3339 format, e.g. 2024-01-02T15:04:05Z (default now)")
+	flag.DurationVar(&interval, "interval", 0, "Mean time between consecutive logs, e.g. 2s (logs of aggregation rules are spread over the rule's timeframe by default)")
+	flag.DurationVar(&jitter, "jitter", 0, "Maximum random deviation of the time between consecutive logs, e.g. 500ms")
+	flag.StringVar(&distribution, "distribution", "constant", "Distribution of the time between consecutive logs: constant, uniform or exponential")
+	flag.StringVar(&timezone, "timezone", "", "Time zone of the log timestamps, e.g. UTC or Europe/Istanbul (default local time zone)")
+	flag.BoolVar(&outside, "outsidetimeframe", false, "Log the negative samples after the timeframe of the positive logs of their condition")
+	flag.Int64Var(&seed, "seed", 0, "Seed of the random generator, the same rules, config and seed give identical logs, starting at 2024-01-01T00:00:00Z unless -start is set (default random)")
+	flag.IntVar(&branches, "branches", sevaluator.DefaultMaxBranches, "Maximum number of logs generated for a condition, one for each way to satisfy it, e.g. each search of '1 of selection*'")
+	flag.BoolVar(&verify, "verify", false, "Match the generated logs back against the rules and exit with an error if any positive log doesn't match or any negative log does")
+	flag.StringVar(&apiKey, "apikey", "", "Api key for the LLM backend (optional, enriches the generated logs)")
+	flag.StringVar(&llmBackend, "llm", "", "LLM backend used to enrich the generated logs: openai, local, azure or mock (default openai if an api key is given)")
+	flag.StringVar(&llmModel, "model", "", "Model or Azure deployment used by the LLM backend (default gpt-3.5-turbo)")
+	flag.StringVar(&llmBaseURL, "baseurl", "", "Base URL of the LLM backend, e.g. a local llama.cpp or Ollama server")
+	flag.StringVar(&llmAPIVersion, "apiversion", "", "API version of the Azure OpenAI backend")
+	flag.StringVar(&llmFixture, "fixture", "", "Path to the recorded responses used by the mock backend")
+	flag.Float64Var(&temperature, "temperature", 0, "Sampling temperature of the LLM backend")
+	flag.IntVar(&maxTokens, "maxtokens", 0, "Maximum number of tokens generated by the LLM backend")
+	flag.DurationVar(&timeout, "timeout", 0, "Timeout of a single LLM request, e.g. 30s")
+	flag.IntVar(&workers, "workers", runtime.NumCPU(), "Number of rules and correlations processed concurrently")
+	flag.Float64Var(&rateLimit, "ratelimit", 0, "Maximum number of LLM requests per second, shared by all workers (default unlimited)")
+	flag.IntVar(&retries, "retries", 5, "Maximum number of times an LLM request is retried when the backend rate limits it with a 429 response")
+	flag.StringVar(&queryBackend, "backend", "", "Query language that the queries of the rules are rendered in: "+strings.Join(sevaluator.BackendNames(), ", ")+" (default Sigma-like pseudo-queries)")
+}
+
+// Parse the command-line flags and check that they are valid, printing the usage and exiting if they aren't
+func parseFlags() {
 	flag.Parse()
 
 	// If the version flag is provided, print version information and exit
@@ -52,28 +118,77 @@ func init() {
 
 	// Check if both filecontent and configcontent are provided
 	if (filePath == "" && fileContent == "") || (configPath == "" && configContent == "") {
-		fmt.Println("Please provide either file paths or file contents, and either config path or config content.")
+		fmt.Fprintln(os.Stderr, "Please provide either file paths or file contents, and either config path or config content.")
+		printUsage()
+		os.Exit(1)
+	}
+
+	// Check if the output format is supported
+	if _, err := formatters.Lookup(outputFormat); err != nil && outputFormat != "text" && outputFormat != "auto" {
+		fmt.Fprintln(os.Stderr, "Unsupported output format:", outputFormat)
+		printUsage()
+		os.Exit(1)
+	}
+
+	// Check that binary formats are written to files, rather than mixed with the text printed by the other rules
+	if formatters.BinaryFormats[outputFormat] && outputPath == "" {
+		fmt.Fprintln(os.Stderr, "The", outputFormat, "output format requires an output directory")
 		printUsage()
 		os.Exit(1)
 	}
 
-	// Check if API key is provided
-	if apiKey == "" {
-		fmt.Println("Please provide API key for ChatGPT.")
+	// Check if the number of workers is valid
+	if workers < 1 {
+		fmt.Fprintln(os.Stderr, "The number of workers must be at least 1")
+		printUsage()
+		os.Exit(1)
+	}
+
+	// Check if the maximum number of branches is valid
+	if branches < 1 {
+		fmt.Fprintln(os.Stderr, "The maximum number of branches must be at least 1")
+		printUsage()
+		os.Exit(1)
+	}
+
+	// Check if the inter-arrival distribution is supported
+	if _, ok := sevaluator.InterArrivals[distribution]; !ok {
+		fmt.Fprintln(os.Stderr, "Unsupported distribution:", distribution)
+		printUsage()
+		os.Exit(1)
+	}
+
+	// Check if the query backend is supported
+	if _, err := sevaluator.LookupBackend(queryBackend); err != nil && queryBackend != "" {
+		fmt.Fprintln(os.Stderr, "Unsupported query backend:", queryBackend)
 		printUsage()
 		os.Exit(1)
 	}
 }
 
 func printUsage() {
-	fmt.Println("Usage: logen -filepath <path> -config <path> -apikey <apikey> [flags]")
+	fmt.Println("Usage: logen -filepath <path> -config <path> [flags]")
 	fmt.Println("Flags:")
 	flag.PrintDefaults()
 	fmt.Println("Example:")
+	fmt.Println("  logen -filepath /path/to/file -config /path/to/config")
 	fmt.Println("  logen -filepath /path/to/file -config /path/to/config -apikey apikey")
+	fmt.Println("  logen -filepath /path/to/file -config /path/to/config -format auto -output /path/to/output")
+	fmt.Println("  logen -filepath /path/to/file -config /path/to/config -llm local -baseurl http://localhost:11434/v1 -model llama3")
+	fmt.Println("  logen -filepath /path/to/file -config /path/to/config -format ecs -start 2024-01-02T15:04:05Z -interval 2s -distribution exponential -timezone UTC")
 }
 
 func main() {
+	parseFlags()
+
+	// The exit status of the run, non-zero if any rule failed to parse or to be turned into logs, any generated log failed verification, the run was aborted by an error or interrupted
+	exitCode := 0
+	defer func() {
+		if exitCode != 0 {
+			os.Exit(exitCode)
+		}
+	}()
+
 	// Read the contents of the file(s) specified by the filepath flag or filecontent flag
 	fileContents := make(map[string][]byte)
 	var err error
@@ -83,7 +198,7 @@ func main() {
 		// Check if the filepath is a directory
 		fileInfo, err := os.Stat(filePath)
 		if err != nil {
-			fmt.Println("Error getting file/directory info:", err)
+			fmt.Fprintln(os.Stderr, "Error getting file/directory info:", err)
 			return
 		}
 
@@ -91,14 +206,14 @@ func main() {
 			// filePath is a directory, so walk the directory to read all the files inside it
 			filepath.Walk(filePath, func(path string, info os.FileInfo, err error) error {
 				if err != nil {
-					fmt.Println("Error accessing file:", err)
+					fmt.Fprintln(os.Stderr, "Error accessing file:", err)
 					return nil
 				}
 				if !info.IsDir() {
 					// read file content
 					content, err := os.ReadFile(path)
 					if err != nil {
-						fmt.Println("Error reading file:", err)
+						fmt.Fprintln(os.Stderr, "Error reading file:", err)
 						return nil
 					}
 					fileContents[path] = content
@@ -109,7 +224,7 @@ func main() {
 			// filePath is a file, so read its contents
 			fileContents[filePath], err = os.ReadFile(filePath)
 			if err != nil {
-				fmt.Println("Error reading file:", err)
+				fmt.Fprintln(os.Stderr, "Error reading file:", err)
 				return
 			}
 		}
@@ -122,7 +237,7 @@ func main() {
 				// decode base64 content
 				decodedContent, err := base64.StdEncoding.DecodeString(line)
 				if err != nil {
-					fmt.Println("Error decoding base64 content:", err)
+					fmt.Fprintln(os.Stderr, "Error decoding base64 content:", err)
 					return
 				}
 				fileContents[line] = decodedContent
@@ -132,7 +247,7 @@ func main() {
 			// decode base64 content
 			decodedContent, err := base64.StdEncoding.DecodeString(fileContent)
 			if err != nil {
-				fmt.Println("Error decoding base64 content:", err)
+				fmt.Fprintln(os.Stderr, "Error decoding base64 content:", err)
 				return
 			}
 			fileContents["filecontent"] = decodedContent
@@ -144,85 +259,432 @@ func main() {
 	if configPath != "" {
 		configContents, err = os.ReadFile(configPath)
 		if err != nil {
-			fmt.Println("Error reading configuration file:", err)
+			fmt.Fprintln(os.Stderr, "Error reading configuration file:", err)
 			return
 		}
 	} else if configContent != "" {
 		// decode base64 content
 		decodedContent, err := base64.StdEncoding.DecodeString(configContent)
 		if err != nil {
-			fmt.Println("Error decoding base64 content:", err)
+			fmt.Fprintln(os.Stderr, "Error decoding base64 content:", err)
 			return
 		}
 		configContents = decodedContent
 	}
 
-	// Loop over each file and parse its contents as a Sigma rule
-	for _, fileContent := range fileContents {
-		sigmaRule, err := sigma.ParseRule(fileContent)
+	// Set up the LLM backend if the generated logs should be enriched
+	var provider sevaluator.Provider
+	if apiKey != "" || llmBackend != "" {
+		provider, err = sevaluator.NewProvider(sevaluator.ProviderConfig{
+			Backend:     llmBackend,
+			APIKey:      apiKey,
+			BaseURL:     llmBaseURL,
+			APIVersion:  llmAPIVersion,
+			Model:       llmModel,
+			Temperature: float32(temperature),
+			MaxTokens:   maxTokens,
+			Timeout:     timeout,
+			FixturePath: llmFixture,
+
+			RequestsPerSecond: rateLimit,
+			MaxRetries:        retries,
+		})
 		if err != nil {
-			fmt.Println("Error parsing rule:", err)
-			continue
+			fmt.Fprintln(os.Stderr, "Error setting up LLM backend:", err)
+			return
 		}
+	}
 
-		// Parse the configuration file as a Sigma config
-		config, err := sigma.ParseConfig(configContents)
+	// Set up the timeline that the generated logs are spread over
+	timeline := sevaluator.Timeline{
+		Interval:                  interval,
+		Jitter:                    jitter,
+		Distribution:              distribution,
+		NegativesOutsideTimeframe: outside,
+	}
+	if startTime != "" {
+		timeline.Start, err = time.Parse(time.RFC3339, startTime)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error parsing start time:", err)
+			return
+		}
+	} else if seed != 0 {
+		timeline.Start = seededStart
+	}
+	if timezone != "" {
+		timeline.Location, err = time.LoadLocation(timezone)
 		if err != nil {
-			fmt.Println("Error parsing config:", err)
+			fmt.Fprintln(os.Stderr, "Error loading time zone:", err)
+			return
+		}
+	}
+
+	// Parse the configuration file as a Sigma config
+	config, err := sigma.ParseConfig(configContents)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error parsing config:", err)
+		return
+	}
+
+	// Evaluate the Sigma rules against the config, optionally using case sensitive mode
+	options := []sevaluator.Option{sevaluator.WithConfig(config), sevaluator.WithTimeline(timeline), sevaluator.WithMaxBranches(branches)}
+	if caseSensitive {
+		options = append(options, sevaluator.CaseSensitive)
+	}
+	if negatives {
+		options = append(options, sevaluator.NegativeSamples)
+	}
+	if keywordFields != "" {
+		options = append(options, sevaluator.WithKeywordFields(strings.Split(keywordFields, ",")...))
+	}
+	if queryBackend != "" {
+		backend, _ := sevaluator.LookupBackend(queryBackend)
+		options = append(options, sevaluator.WithBackend(backend))
+	}
+
+	// Loop over each file and parse its contents as Sigma rules and correlation rules, a file may hold several YAML documents
+	// The files are read in the order of their names, so that seeded runs process the rules in the same order
+	paths := make([]string, 0, len(fileContents))
+	for path := range fileContents {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var rules []sigma.Rule
+	var correlations []sigma.CorrelationRule
+	unparsed := 0
+	for _, path := range paths {
+		ruleSet, err := sigma.ParseRuleSet(fileContents[path])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error parsing rule:", err)
+			unparsed++
+			continue
+		}
+		rules = append(rules, ruleSet.Rules...)
+		correlations = append(correlations, ruleSet.Correlations...)
+	}
+
+	// Every rule and correlation is a job of the worker pool
+	var jobs []job
+	skipped := 0
+	for _, sigmaRule := range rules {
+		// Rules referenced by a correlation only generate logs of their own if the correlation asks for it
+		if correlated(sigmaRule, correlations) {
+			skipped++
 			continue
 		}
 
-		var sr *sevaluator.RuleEvaluator
+		sigmaRule := sigmaRule
+		ruleOptions := jobOptions(options, len(jobs))
+		jobs = append(jobs, job{title: sigmaRule.Title, run: func(ctx context.Context, output *jobOutput) error {
+			sr := sevaluator.ForRule(sigmaRule, ruleOptions...)
+			result, err := sr.Alters(ctx)
+			if err != nil {
+				fmt.Fprintln(&output.stderr, "Error converting rule:", err)
+				output.failed = true
+				return nil
+			}
 
-		if caseSensitive {
-			// Evaluate the Sigma rule against the config using case sensitive mode
-			sr = sevaluator.ForRule(sigmaRule, sevaluator.WithConfig(config), sevaluator.CaseSensitive)
-		} else {
-			// Evaluate the Sigma rule against the config
-			sr = sevaluator.ForRule(sigmaRule, sevaluator.WithConfig(config))
+			// Choose the output format of the rule, the native format of its logsource in auto mode
+			format := outputFormat
+			if format == "auto" {
+				format = formatters.FormatForRule(sr.Rule)
+			}
+
+			// Build a structured record for each synthetic event and optionally match the final records back against the rule
+			records := formatters.NewRecords(sr.Rule, result)
+			return writeRecords(ctx, provider, output, sigmaRule.Title, format, records, func(records []formatters.Record) bool {
+				return verifyRecords(ctx, output, sr, records)
+			})
+		}})
+	}
+
+	for _, correlation := range correlations {
+		correlation := correlation
+		correlationOptions := jobOptions(options, len(jobs))
+		jobs = append(jobs, job{title: correlation.Title, run: func(ctx context.Context, output *jobOutput) error {
+			cr, err := sevaluator.ForCorrelation(correlation, rules, correlationOptions...)
+			if err != nil {
+				fmt.Fprintln(&output.stderr, "Error resolving correlation:", err)
+				output.failed = true
+				return nil
+			}
+			result, err := cr.Alters(ctx)
+			if err != nil {
+				fmt.Fprintln(&output.stderr, "Error converting correlation:", err)
+				output.failed = true
+				return nil
+			}
+
+			// The logs of a correlation are rendered in the format of its first rule in auto mode
+			format := outputFormat
+			if format == "auto" {
+				format = formatters.FormatForRule(cr.Rules()[0].Rule)
+			}
+
+			// Build a structured record for each event of the sequence and optionally match the final records back against the correlation
+			records := formatters.NewCorrelationRecords(cr, result)
+			return writeRecords(ctx, provider, output, correlation.Title, format, records, func(records []formatters.Record) bool {
+				return verifyCorrelationRecords(ctx, output, cr, records)
+			})
+		}})
+	}
+
+	// Stop starting new jobs and cancel the pending LLM requests on the first SIGINT, a second one exits immediately
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	summary := runJobs(ctx, jobs, workers, os.Stdout, os.Stderr)
+	summary.skipped = skipped
+	summary.unparsed = unparsed
+	fmt.Fprintln(os.Stderr, summary)
+
+	switch {
+	case summary.interrupted:
+		exitCode = 130
+	case summary.err != nil || summary.failed > 0 || summary.unparsed > 0 || summary.unverified > 0:
+		exitCode = 1
+	}
+}
+
+// jobOptions returns the evaluation options of the job with the given index.
+// Seeded runs give every job a generator of its own, seeded from the seed and the index of the job,
+// so that the output doesn't depend on the order in which the workers run the jobs.
+func jobOptions(options []sevaluator.Option, index int) []sevaluator.Option {
+	if seed == 0 {
+		return options
+	}
+	generator := modifiers.NewSeededSyntheticDataGenerator(seed + int64(index))
+	return append(options[:len(options):len(options)], sevaluator.WithGenerator(generator))
+}
+
+// correlated reports whether a rule is referenced by a correlation that doesn't generate alerts for its rules.
+func correlated(rule sigma.Rule, correlations []sigma.CorrelationRule) bool {
+	for _, correlation := range correlations {
+		if !correlation.Correlation.Generate && correlation.Correlates(rule) {
+			return true
 		}
+	}
+	return false
+}
 
-		ctx := context.Background()
-		result, err := sr.Alters(ctx)
+// writeRecords renders the records of a rule or correlation in the given format, optionally enriching them using the LLM backend,
+// and writes them to the output directory, or prints them to the output of the job if there is none.
+// If verification is enabled, the final records are checked with the verify function and the outcome is stored in the output of the job.
+// Errors that only affect this rule or correlation are printed, and the error of an LLM request is returned.
+func writeRecords(ctx context.Context, provider sevaluator.Provider, output *jobOutput, title string, format string, records []formatters.Record, verifyFunc func([]formatters.Record) bool) error {
+	var formatter formatters.Formatter
+	if format != "text" {
+		var err error
+		formatter, err = formatters.Lookup(format)
+		if err == nil && formatters.BinaryFormats[format] && outputPath == "" {
+			err = fmt.Errorf("the %s output format of %s requires an output directory", format, title)
+		}
 		if err != nil {
-			fmt.Println("Error converting rule:", err)
-			continue
+			fmt.Fprintln(&output.stderr, "Error choosing output format:", err)
+			output.failed = true
+			return nil
 		}
+	}
 
-		var output string
+	var builder strings.Builder
+	lastCondition, lastQuery := -1, ""
+	for i, record := range records {
+		// Render the synthetic event generated from the rule
+		log, err := json.MarshalIndent(record.Fields, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error encoding event: %w", err)
+		}
 
-		// Print the results of the query
-		var builder strings.Builder
-		for i, query := range result.Queries {
-			builder.WriteString("Query:" + query + "\n")
-			content := fmt.Sprintf("Generate a synthetic log in the 'evtx' format that meets the following conditions for %s:\n%s", result.SourceTypes[i], query)
+		response := string(log)
+
+		// Optionally enrich the synthetic event using the LLM backend
+		if provider != nil {
+			// Negative samples must only just miss the conditions
+			requirement := "meets"
+			if record.Negative {
+				requirement = "almost, but not quite, meets"
+			}
 
-			response, err := sevaluator.SendMessageToOpenAI(apiKey, content)
+			var content string
+			if format == "text" {
+				native := formatters.Describe(formatters.FormatForLogsource(record.Logsource))
+				content = fmt.Sprintf("Generate a synthetic log in the %s format that %s the following conditions for %s:\n%s\nUse the following event fields and values in the log:\n%s", native, requirement, record.SourceType, record.Query, log)
+			} else {
+				content = fmt.Sprintf("Generate a synthetic log for %s that %s the following conditions:\n%s\nStart from the following event fields and values, and add the other fields a real log would have:\n%s\nRespond only with a flat JSON object that maps field names to values.", record.SourceType, requirement, record.Query, log)
+			}
+
+			output.llmRequests++
+			response, err = provider.SendMessage(ctx, content)
 			if err != nil {
-				fmt.Println(err)
-				return
+				return err
+			}
+
+			// Coerce the response into the record, keeping the generated values so that it still satisfies the query
+			if format != "text" {
+				fields, err := formatters.ParseFields(response)
+				if err != nil {
+					fmt.Fprintln(&output.stderr, "Error parsing LLM response, keeping the generated event:", err)
+				} else {
+					for field, value := range record.Fields {
+						fields[field] = value
+					}
+					records[i].Fields = fields
+				}
+			}
+		}
+
+		if format == "text" {
+			// The logs of a correlation may alternate between the queries of its rules
+			if record.Condition != lastCondition || record.Query != lastQuery {
+				builder.WriteString("Query:" + record.Query + "\n")
+				lastCondition, lastQuery = record.Condition, record.Query
+			}
+			if record.Negative {
+				builder.WriteString("Negative log:\n" + response + "\n")
+			} else {
+				builder.WriteString("Log:\n" + response + "\n")
 			}
+		}
+	}
+
+	// Optionally match the final records back against the rule
+	if verify && !verifyFunc(records) {
+		output.unverified = true
+	}
+
+	// Write the structured records in the requested format
+	extension := ".log"
+	if formatter != nil {
+		if err := formatter.Format(&builder, records); err != nil {
+			fmt.Fprintln(&output.stderr, "Error encoding records:", err)
+			output.failed = true
+			return nil
+		}
+		extension = formatter.Extension()
+	}
+
+	logs := builder.String()
+
+	// Check if outputPath is provided
+	if outputPath == "" {
+		output.stdout.WriteString(logs)
+		return nil
+	}
+
+	// Create the output file path using the title of the rule
+	outputFilePath := filepath.Join(outputPath, title+extension)
+
+	// Write the output string to the output file
+	if err := os.WriteFile(outputFilePath, []byte(logs), 0644); err != nil {
+		fmt.Fprintln(&output.stderr, "Error writing output to file:", err)
+		output.failed = true
+		return nil
+	}
+
+	fmt.Fprintf(&output.stdout, "Output for rule '%s' written to file: %s\n", title, outputFilePath)
+
+	// Optionally write the records of windows rules as a binary event log as well
+	var windowsRecords []formatters.Record
+	for _, record := range records {
+		if formatters.IsWindows(record.Logsource) {
+			windowsRecords = append(windowsRecords, record)
+		}
+	}
+	if writeEVTX && len(windowsRecords) > 0 {
+		var evtx bytes.Buffer
+		if err := formatters.WriteEVTX(&evtx, windowsRecords); err != nil {
+			fmt.Fprintln(&output.stderr, "Error encoding evtx:", err)
+			output.failed = true
+			return nil
+		}
 
-			builder.WriteString("Log:\n" + response + "\n")
+		evtxFilePath := filepath.Join(outputPath, title+".evtx")
+		if err := os.WriteFile(evtxFilePath, evtx.Bytes(), 0644); err != nil {
+			fmt.Fprintln(&output.stderr, "Error writing evtx to file:", err)
+			output.failed = true
+			return nil
 		}
 
-		output = builder.String()
+		fmt.Fprintf(&output.stdout, "Event log for rule '%s' written to file: %s\n", title, evtxFilePath)
+	}
+	return nil
+}
 
-		// Check if outputPath is provided
-		if outputPath != "" {
-			// Create the output file path using the Name field from the rule
-			outputFilePath := filepath.Join(outputPath, fmt.Sprintf("%s.log", sigmaRule.Title))
+// verifyCorrelationRecords matches the records of a correlation back against it and reports the outcome.
+// It returns false if the records don't trigger the correlation within its timespan.
+func verifyCorrelationRecords(ctx context.Context, output *jobOutput, cr *sevaluator.CorrelationEvaluator, records []formatters.Record) bool {
+	events := make([]sevaluator.Event, len(records))
+	for i, record := range records {
+		events[i] = sevaluator.Event{Fields: record.Fields, Timestamp: record.Timestamp}
+	}
 
-			// Write the output string to the output file
-			err := os.WriteFile(outputFilePath, []byte(output), 0644)
+	passed, err := cr.Matches(ctx, events)
+	if err != nil {
+		fmt.Fprintf(&output.stderr, "Error verifying correlation '%s': %v\n", cr.Title, err)
+		return false
+	}
+	if passed {
+		fmt.Fprintf(&output.stderr, "Verification of correlation '%s': PASS\n", cr.Title)
+	} else {
+		fmt.Fprintf(&output.stderr, "Verification of correlation '%s': FAIL\n", cr.Title)
+	}
+	return passed
+}
+
+// verifyRecords matches the records of a rule back against it and reports the outcome for each condition.
+// It returns false if any positive record doesn't trigger its condition or any negative record does,
+// or if the positive records of a condition with an aggregation don't satisfy it together.
+func verifyRecords(ctx context.Context, output *jobOutput, sr *sevaluator.RuleEvaluator, records []formatters.Record) bool {
+	passed := true
+	failures := map[int]int{}
+	positives := map[int][]sevaluator.Event{}
+	for _, record := range records {
+		event := sevaluator.Event{Fields: record.Fields, Timestamp: record.Timestamp}
+		result, err := sr.Matches(ctx, event)
+		if err != nil {
+			fmt.Fprintf(&output.stderr, "Error verifying rule '%s': %v\n", sr.Title, err)
+			return false
+		}
+		// Positive records of near conditions may match one of the searches of the near expression instead
+		matched := result.Conditions[record.Condition]
+		if !record.Negative {
+			matched = matched || result.Adjacent[record.Condition]
+		}
+		if matched == record.Negative {
+			failures[record.Condition]++
+			passed = false
+		}
+
+		// Keep the positive records of each condition to check its aggregation
+		if !record.Negative {
+			positives[record.Condition] = append(positives[record.Condition], event)
+		}
+	}
+
+	for conditionIndex, condition := range sr.Detection.Conditions {
+		aggregated := true
+		if condition.Aggregation != nil {
+			var err error
+			aggregated, err = sr.MatchesAggregation(ctx, conditionIndex, positives[conditionIndex])
 			if err != nil {
-				fmt.Println("Error writing output to file:", err)
-				continue
+				fmt.Fprintf(&output.stderr, "Error verifying rule '%s': %v\n", sr.Title, err)
+				return false
 			}
+			passed = passed && aggregated
+		}
 
-			fmt.Printf("Output for rule '%s' written to file: %s\n", sigmaRule.Title, outputFilePath)
+		if failures[conditionIndex] > 0 {
+			fmt.Fprintf(&output.stderr, "Verification of rule '%s' condition %d: FAIL (%d logs)\n", sr.Title, conditionIndex, failures[conditionIndex])
+		} else if !aggregated {
+			fmt.Fprintf(&output.stderr, "Verification of rule '%s' condition %d: FAIL (aggregation)\n", sr.Title, conditionIndex)
 		} else {
-			fmt.Printf("%s", output)
+			fmt.Fprintf(&output.stderr, "Verification of rule '%s' condition %d: PASS\n", sr.Title, conditionIndex)
 		}
 	}
+	return passed
 }
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// job is a rule or correlation that the worker pool turns into logs.
+type job struct {
+	title string                                             // The title of the rule or correlation
+	run   func(ctx context.Context, output *jobOutput) error // Generates, writes and verifies the logs, an error aborts the whole run
+}
+
+// jobOutput collects what a job prints and how it went, so that the outputs of concurrent jobs are printed in the order of the jobs.
+type jobOutput struct {
+	stdout bytes.Buffer
+	stderr bytes.Buffer
+
+	started     bool  // Whether the job was started, jobs aren't started once the run is cancelled
+	failed      bool  // Whether the rule or correlation couldn't be turned into logs
+	unverified  bool  // Whether the logs failed verification
+	llmRequests int   // The number of requests sent to the LLM backend
+	err         error // The error that stopped the job
+	aborted     bool  // Whether the error of the job aborted the run, as it was the first one
+}
+
+// summary counts the outcome of the jobs of a run.
+type summary struct {
+	jobs        int           // The number of rules and correlations to process
+	processed   int           // The number of jobs that ran to completion
+	failed      int           // The number of rules and correlations that couldn't be turned into logs
+	unverified  int           // The number of rules and correlations whose logs failed verification
+	skipped     int           // The number of rules that only generate logs as part of a correlation
+	unparsed    int           // The number of rule files that couldn't be parsed
+	llmRequests int           // The number of requests sent to the LLM backend
+	elapsed     time.Duration // The duration of the run
+	interrupted bool          // Whether the run was cancelled before all the jobs were run
+	err         error         // The error that aborted the run
+}
+
+// runJobs runs the jobs on a pool of workers and prints the output of each job as soon as all the jobs before it are done,
+// so that the output is the same as if the jobs were run one after another.
+// No new jobs are started once the context is cancelled, e.g. on SIGINT, or once a job returns an error,
+// in which case the jobs that are still running are cancelled as well.
+func runJobs(parent context.Context, jobs []job, workers int, stdout, stderr io.Writer) summary {
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	start := time.Now()
+	result := summary{jobs: len(jobs)}
+
+	// Every job reports to a channel of its own, which are read in the order of the jobs
+	outputs := make([]chan *jobOutput, len(jobs))
+	for i := range outputs {
+		outputs[i] = make(chan *jobOutput, 1)
+	}
+	indexes := make(chan int)
+	go func() {
+		defer close(indexes)
+		for i := range jobs {
+			indexes <- i
+		}
+	}()
+
+	// The first job that fails aborts the run, the errors of the jobs it cancels are the outcome of the abort, not its cause
+	var abort sync.Once
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				output := &jobOutput{}
+				if ctx.Err() == nil {
+					output.started = true
+					output.err = jobs[i].run(ctx, output)
+				}
+				if output.err != nil && parent.Err() == nil {
+					abort.Do(func() {
+						output.aborted = true
+						cancel()
+					})
+				}
+				outputs[i] <- output
+			}
+		}()
+	}
+
+	for i := range jobs {
+		output := <-outputs[i]
+		stdout.Write(output.stdout.Bytes())
+		stderr.Write(output.stderr.Bytes())
+
+		if !output.started {
+			continue
+		}
+		result.llmRequests += output.llmRequests
+
+		if output.err != nil {
+			if output.aborted {
+				result.err = fmt.Errorf("%s: %w", jobs[i].title, output.err)
+				fmt.Fprintln(stderr, output.err)
+			}
+			continue
+		}
+
+		result.processed++
+		if output.failed {
+			result.failed++
+		}
+		if output.unverified {
+			result.unverified++
+		}
+	}
+	wg.Wait()
+
+	result.interrupted = parent.Err() != nil
+	result.elapsed = time.Since(start)
+	return result
+}
+
+// String renders the summary as printed at the end of a run.
+func (s summary) String() string {
+	counts := []string{fmt.Sprintf("%d failed", s.failed)}
+	if verify {
+		counts = append(counts, fmt.Sprintf("%d failed verification", s.unverified))
+	}
+	if s.unparsed > 0 {
+		counts = append(counts, fmt.Sprintf("%d unparsable files", s.unparsed))
+	}
+	if s.skipped > 0 {
+		counts = append(counts, fmt.Sprintf("%d skipped as part of a correlation", s.skipped))
+	}
+	if s.llmRequests > 0 {
+		counts = append(counts, fmt.Sprintf("%d LLM requests", s.llmRequests))
+	}
+
+	text := fmt.Sprintf("Processed %d of %d rules and correlations in %v: %s", s.processed, s.jobs, s.elapsed.Round(time.Millisecond), strings.Join(counts, ", "))
+	switch {
+	case s.interrupted:
+		text += " (interrupted)"
+	case s.err != nil:
+		text += fmt.Sprintf(" (aborted by %v)", s.err)
+	}
+	return text
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestRunJobs checks that the outputs of concurrent jobs are printed in the order of the jobs, and that their outcomes are counted.
+func TestRunJobs(t *testing.T) {
+	var jobs []job
+	var expected strings.Builder
+	for i := 0; i < 20; i++ {
+		i := i
+		jobs = append(jobs, job{title: fmt.Sprint(i), run: func(ctx context.Context, output *jobOutput) error {
+			// Later jobs finish first
+			time.Sleep(time.Duration(20-i) * time.Millisecond)
+			fmt.Fprintf(&output.stdout, "job %d\n", i)
+			output.failed = i%5 == 0
+			output.unverified = i%4 == 0
+			output.llmRequests = 2
+			return nil
+		}})
+		fmt.Fprintf(&expected, "job %d\n", i)
+	}
+
+	var stdout, stderr bytes.Buffer
+	result := runJobs(context.Background(), jobs, 8, &stdout, &stderr)
+	if stdout.String() != expected.String() {
+		t.Errorf("expected the outputs in the order of the jobs, got:\n%s", stdout.String())
+	}
+	if result.processed != 20 || result.failed != 4 || result.unverified != 5 || result.llmRequests != 40 || result.interrupted || result.err != nil {
+		t.Errorf("unexpected summary %+v", result)
+	}
+}
+
+// TestRunJobs_Abort checks that an error of a job aborts the run, so that the jobs after it don't start.
+func TestRunJobs_Abort(t *testing.T) {
+	var jobs []job
+	for i := 0; i < 20; i++ {
+		i := i
+		jobs = append(jobs, job{title: fmt.Sprint(i), run: func(ctx context.Context, output *jobOutput) error {
+			if i == 2 {
+				return errors.New("backend unavailable")
+			}
+			return nil
+		}})
+	}
+
+	var stdout, stderr bytes.Buffer
+	result := runJobs(context.Background(), jobs, 1, &stdout, &stderr)
+	if result.err == nil || !strings.Contains(result.err.Error(), "backend unavailable") {
+		t.Errorf("expected the run to be aborted by the error of job 2, got %v", result.err)
+	}
+	if result.processed != 2 || result.interrupted {
+		t.Errorf("expected the jobs after the error not to run, got %+v", result)
+	}
+	// Errors are kept out of the logs written to stdout
+	if strings.Contains(stdout.String(), "backend unavailable") || !strings.Contains(stderr.String(), "backend unavailable") {
+		t.Errorf("expected the error on stderr only, got stdout %q and stderr %q", stdout.String(), stderr.String())
+	}
+}
+
+// TestRunJobs_Interrupt checks that cancelling the context, as SIGINT does, cancels the running jobs and doesn't start new ones.
+func TestRunJobs_Interrupt(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var jobs []job
+	for i := 0; i < 20; i++ {
+		i := i
+		jobs = append(jobs, job{title: fmt.Sprint(i), run: func(ctx context.Context, output *jobOutput) error {
+			if i == 3 {
+				cancel()
+			}
+			if i >= 3 {
+				<-ctx.Done()
+				return ctx.Err()
+			}
+			return nil
+		}})
+	}
+
+	var stdout, stderr bytes.Buffer
+	result := runJobs(ctx, jobs, 1, &stdout, &stderr)
+	if !result.interrupted || result.err != nil {
+		t.Errorf("expected the run to be interrupted, got %+v", result)
+	}
+	if result.processed != 3 {
+		t.Errorf("expected 3 jobs to complete before the interruption, got %d", result.processed)
+	}
+	if !strings.Contains(result.String(), "(interrupted)") {
+		t.Errorf("expected the summary to mention the interruption, got %s", result.String())
+	}
+}
@@ -45,6 +45,15 @@ func ParseCondition(input string) (Condition, error) {
 		return Condition{}, err
 	}
 
+	// A near expression takes the place of the aggregation
+	if root.Near != nil {
+		near, err := searchToAST(*root.Near)
+		if err != nil {
+			return Condition{}, err
+		}
+		aggregation = Near{Condition: near}
+	}
+
 	// Return a new Condition struct that contains the ASTs for the search and aggregation expressions
 	return Condition{
 		Search:      search,
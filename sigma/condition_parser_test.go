@@ -28,6 +28,10 @@ func TestParseCondition(t *testing.T) {
 		{"a | count(b) >= 0", Condition{Search: SearchIdentifier{"a"}, Aggregation: Comparison{Func: Count{Field: "b"}, Op: GreaterThanEqual, Threshold: 0}}},
 		// the eighth test case expects an And expression with two SearchIdentifier expressions
 		{"note and pad", Condition{Search: And{SearchIdentifier{"note"}, SearchIdentifier{"pad"}}}},
+		// the ninth test case expects a SearchIdentifier expression and a Near expression with an And expression
+		{"a | near b and not c", Condition{Search: SearchIdentifier{"a"}, Aggregation: Near{Condition: And{SearchIdentifier{"b"}, Not{SearchIdentifier{"c"}}}}}},
+		// the tenth test case expects an Or expression and a Near expression with a SearchIdentifier expression
+		{"a or b | near nearby", Condition{Search: Or{SearchIdentifier{"a"}, SearchIdentifier{"b"}}, Aggregation: Near{Condition: SearchIdentifier{"nearby"}}}},
 	}
 
 	// iterate over each test case and execute the test
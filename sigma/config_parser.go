@@ -12,8 +12,9 @@ type Config struct {
 	FieldMappings map[string]FieldMapping
 	Logsources    map[string]LogsourceMapping
 	// TODO: LogsourceMerging option
-	DefaultIndex string                   // Defines a default index if no logsources match
-	Placeholders map[string][]interface{} // Defines values for placeholders that might appear in Sigma rules
+	DefaultIndex  string                   // Defines a default index if no logsources match
+	Placeholders  map[string][]interface{} // Defines values for placeholders that might appear in Sigma rules
+	KeywordFields KeywordFields            // Defines the free-text fields that keywords are searched in if no logsources declare any
 }
 
 // FieldMapping is a struct that defines the target fields to be matched in Sigma rules
@@ -47,6 +48,8 @@ type LogsourceMapping struct {
 	Index      LogsourceIndexes // The index(es) that should be used for this logsource
 	Conditions Search           // Conditions that are added to all rules targeting this logsource
 	Rewrite    Logsource        // Rewrites this logsource (i.e. so that it can be matched by another lower precedence config)
+
+	KeywordFields KeywordFields // The free-text fields that keywords are searched in for this logsource (e.g. message, CommandLine or raw)
 }
 
 // LogsourceIndexes is a list of strings representing indexes for a logsource
@@ -71,6 +74,28 @@ func (i *LogsourceIndexes) UnmarshalYAML(value *yaml.Node) error {
 	return nil
 }
 
+// KeywordFields is a list of event field names that Sigma keywords are searched in
+type KeywordFields []string
+
+// UnmarshalYAML is a custom method for unmarshaling YAML data into KeywordFields
+func (k *KeywordFields) UnmarshalYAML(value *yaml.Node) error {
+	switch value.Kind {
+	case yaml.ScalarNode:
+		// If the YAML value is a scalar (single value), set it as the only element in the slice
+		*k = []string{value.Value}
+
+	case yaml.SequenceNode:
+		// If the YAML value is a sequence (list), decode it into a slice
+		var values []string
+		err := value.Decode(&values)
+		if err != nil {
+			return err
+		}
+		*k = values
+	}
+	return nil
+}
+
 // ParseConfig takes a byte slice of YAML data and returns a Config struct or an error if unmarshaling fails
 func ParseConfig(contents []byte) (Config, error) {
 	config := Config{}
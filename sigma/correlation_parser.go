@@ -0,0 +1,254 @@
+package sigma
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CorrelationRule is a Sigma correlation rule, which triggers on a combination of events that match other rules.
+type CorrelationRule struct {
+	// Required fields
+	Title       string      // The title of the correlation rule
+	Correlation Correlation // The correlation logic of the rule
+
+	// Optional fields
+	ID          string   `yaml:",omitempty" json:",omitempty"` // The unique ID of the correlation rule
+	Name        string   `yaml:",omitempty" json:",omitempty"` // The unique name of the correlation rule, used by other correlation rules to reference it
+	Status      string   `yaml:",omitempty" json:",omitempty"` // The status of the correlation rule (e.g. "test", "stable")
+	Description string   `yaml:",omitempty" json:",omitempty"` // A brief description of the correlation rule
+	Author      string   `yaml:",omitempty" json:",omitempty"` // The author of the correlation rule
+	Level       string   `yaml:",omitempty" json:",omitempty"` // The severity level of the correlation rule (e.g. "low", "medium", "high")
+	References  []string `yaml:",omitempty" json:",omitempty"` // References related to the correlation rule
+	Tags        []string `yaml:",omitempty" json:",omitempty"` // Tags that can be used to organize the correlation rules
+
+	// Any non-standard fields will end up in here
+	AdditionalFields map[string]interface{} `yaml:",inline,omitempty" json:",inline,omitempty"` // Any additional fields in the YAML document
+}
+
+// CorrelationType is the type of a correlation, which defines how the events of the referenced rules are combined.
+type CorrelationType string
+
+// The correlation types defined by the Sigma specification
+const (
+	EventCount      CorrelationType = "event_count"      // The number of events that match the rules meets the condition
+	ValueCount      CorrelationType = "value_count"      // The number of distinct values of a field in the events meets the condition
+	Temporal        CorrelationType = "temporal"         // Every rule matches an event within the timespan, in any order
+	TemporalOrdered CorrelationType = "temporal_ordered" // Every rule matches an event within the timespan, in the order the rules are listed
+)
+
+// Correlation defines how the events that match the referenced rules are combined.
+type Correlation struct {
+	Type      CorrelationType              // The type of the correlation
+	Rules     []string                     // The names or IDs of the rules whose events are correlated
+	GroupBy   []string                     `yaml:"group-by,omitempty" json:",omitempty"` // The fields whose values must be the same in all the correlated events
+	Timespan  Timespan                     `yaml:",omitempty" json:",omitempty"`         // The time window that the correlated events must fall in
+	Condition *CorrelationCondition        `yaml:",omitempty" json:",omitempty"`         // The condition on the number of events or values, for event_count and value_count correlations
+	Aliases   map[string]map[string]string `yaml:",omitempty" json:",omitempty"`         // Maps alias field names to the name of the field in the events of each referenced rule
+	Generate  bool                         `yaml:",omitempty" json:",omitempty"`         // Whether the referenced rules also generate alerts of their own
+}
+
+// Timespan is the time window of a correlation, written as a number followed by a unit, e.g. 30s, 5m, 1h or 1d.
+type Timespan time.Duration
+
+// UnmarshalYAML parses a timespan, accepting days and weeks on top of the units supported by time.ParseDuration.
+func (t *Timespan) UnmarshalYAML(node *yaml.Node) error {
+	duration, err := parseTimespan(strings.TrimSpace(node.Value))
+	if err != nil {
+		return fmt.Errorf("invalid timespan (line %d): %w", node.Line, err)
+	}
+	*t = Timespan(duration)
+	return nil
+}
+
+// parseTimespan parses a Sigma timespan into a duration.
+func parseTimespan(value string) (time.Duration, error) {
+	units := map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour}
+	for suffix, unit := range units {
+		if number, ok := strings.CutSuffix(value, suffix); ok {
+			count, err := strconv.ParseFloat(number, 64)
+			if err != nil {
+				return 0, err
+			}
+			return time.Duration(count * float64(unit)), nil
+		}
+	}
+	return time.ParseDuration(value)
+}
+
+// CorrelationCondition is the condition on the number of events, or distinct values, of a correlation.
+type CorrelationCondition struct {
+	Thresholds []CorrelationThreshold // The comparisons that the number must satisfy, all of them
+	Field      string                 // The field whose distinct values are counted, for value_count correlations
+}
+
+// CorrelationThreshold compares the number of events, or distinct values, of a correlation with a threshold.
+type CorrelationThreshold struct {
+	Op        ComparisonOp // The comparison operator to use
+	Threshold float64      // The threshold value to compare against
+}
+
+// CorrelationOperators maps the keys of a correlation condition to their comparison operators.
+var CorrelationOperators = map[string]ComparisonOp{
+	"gt":  GreaterThan,
+	"gte": GreaterThanEqual,
+	"lt":  LessThan,
+	"lte": LessThanEqual,
+	"eq":  Equal,
+	"neq": NotEqual,
+}
+
+// UnmarshalYAML decodes a correlation condition, a mapping of comparison operators to thresholds and an optional field.
+func (c *CorrelationCondition) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("invalid correlation condition (line %d). Expected a mapping", node.Line)
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if key.Value == "field" {
+			c.Field = value.Value
+			continue
+		}
+
+		op, ok := CorrelationOperators[key.Value]
+		if !ok {
+			return fmt.Errorf("unknown correlation condition operator %s (line %d)", key.Value, key.Line)
+		}
+		threshold, err := strconv.ParseFloat(value.Value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid correlation condition threshold %s (line %d): %w", value.Value, value.Line, err)
+		}
+		c.Thresholds = append(c.Thresholds, CorrelationThreshold{Op: op, Threshold: threshold})
+	}
+	return nil
+}
+
+// Matches reports whether a number of events, or distinct values, satisfies every threshold of the condition.
+func (c CorrelationCondition) Matches(count float64) bool {
+	for _, threshold := range c.Thresholds {
+		var match bool
+		switch threshold.Op {
+		case Equal:
+			match = count == threshold.Threshold
+		case NotEqual:
+			match = count != threshold.Threshold
+		case LessThan:
+			match = count < threshold.Threshold
+		case LessThanEqual:
+			match = count <= threshold.Threshold
+		case GreaterThan:
+			match = count > threshold.Threshold
+		case GreaterThanEqual:
+			match = count >= threshold.Threshold
+		}
+		if !match {
+			return false
+		}
+	}
+	return true
+}
+
+// Resolve returns the rules referenced by the correlation, in the order they are referenced.
+// Rules are referenced by their name or their ID.
+func (c CorrelationRule) Resolve(rules []Rule) ([]Rule, error) {
+	referenced := make([]Rule, 0, len(c.Correlation.Rules))
+	for _, reference := range c.Correlation.Rules {
+		found := false
+		for _, rule := range rules {
+			if isReference(rule, reference) {
+				referenced = append(referenced, rule)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown rule %s referenced by correlation %s", reference, c.Title)
+		}
+	}
+	return referenced, nil
+}
+
+// Correlates reports whether the correlation references the given rule.
+func (c CorrelationRule) Correlates(rule Rule) bool {
+	for _, reference := range c.Correlation.Rules {
+		if isReference(rule, reference) {
+			return true
+		}
+	}
+	return false
+}
+
+// isReference reports whether a reference of a correlation rule refers to the given rule, by its name or its ID.
+func isReference(rule Rule, reference string) bool {
+	return (rule.Name != "" && rule.Name == reference) || (rule.ID != "" && rule.ID == reference)
+}
+
+// Field returns the name of a field in the events of a referenced rule, resolving aliases.
+func (c CorrelationRule) Field(field string, rule Rule) string {
+	if aliases, ok := c.Correlation.Aliases[field]; ok {
+		for _, reference := range []string{rule.Name, rule.ID} {
+			if name, ok := aliases[reference]; ok && reference != "" {
+				return name
+			}
+		}
+	}
+	return field
+}
+
+// RuleSet holds the rules and correlation rules read from a YAML stream of one or more documents.
+type RuleSet struct {
+	Rules        []Rule            // The detection rules in the order they appear
+	Correlations []CorrelationRule // The correlation rules in the order they appear
+}
+
+// ParseRuleSet reads a YAML stream of one or more documents and returns the rules and correlation rules it holds.
+// Documents with a correlation section are parsed as correlation rules, all other documents as rules.
+// The collection actions of the documents are applied first, see ParseRuleCollection.
+func ParseRuleSet(input []byte) (RuleSet, error) {
+	documents, err := parseCollection(input)
+	if err != nil {
+		return RuleSet{}, err
+	}
+
+	var set RuleSet
+	for document, node := range documents {
+		if isCorrelation(node) {
+			correlation := CorrelationRule{}
+			if err := node.Decode(&correlation); err != nil {
+				return RuleSet{}, fmt.Errorf("error parsing correlation rule %d: %w", document, err)
+			}
+			set.Correlations = append(set.Correlations, correlation)
+		} else {
+			rule := Rule{}
+			if err := node.Decode(&rule); err != nil {
+				return RuleSet{}, fmt.Errorf("error parsing rule %d: %w", document, err)
+			}
+			set.Rules = append(set.Rules, rule)
+		}
+	}
+	return set, nil
+}
+
+// ParseCorrelationRule reads a byte slice and returns a parsed CorrelationRule object and an error (if any)
+func ParseCorrelationRule(input []byte) (CorrelationRule, error) {
+	correlation := CorrelationRule{}
+	err := yaml.Unmarshal(input, &correlation)
+	return correlation, err
+}
+
+// isCorrelation reports whether a YAML document holds a correlation rule.
+func isCorrelation(node *yaml.Node) bool {
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	for i := 0; i < len(node.Content); i += 2 {
+		if node.Content[i].Kind == yaml.ScalarNode && node.Content[i].Value == "correlation" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,98 @@
+package sigma
+
+import (
+	"testing"
+	"time"
+)
+
+const correlationTestRuleSet = `
+title: Failed Logon
+name: failed_logon
+logsource:
+  product: windows
+  service: security
+detection:
+  selection:
+    EventID: 4625
+  condition: selection
+---
+title: Successful Logon
+id: 5b1a8a9c-6f3e-4c2a-9f1d-2f0e6c1d7a11
+logsource:
+  product: windows
+  service: security
+detection:
+  selection:
+    EventID: 4624
+  condition: selection
+---
+title: Brute Force Followed By Logon
+correlation:
+  type: temporal_ordered
+  rules:
+    - failed_logon
+    - 5b1a8a9c-6f3e-4c2a-9f1d-2f0e6c1d7a11
+  group-by:
+    - user
+  timespan: 1d
+  aliases:
+    user:
+      failed_logon: TargetUserName
+      5b1a8a9c-6f3e-4c2a-9f1d-2f0e6c1d7a11: SubjectUserName
+---
+title: Many Users Failing
+correlation:
+  type: value_count
+  rules:
+    - failed_logon
+  timespan: 15m
+  condition:
+    gte: 10
+    lt: 20
+    field: TargetUserName
+`
+
+func TestParseRuleSet(t *testing.T) {
+	set, err := ParseRuleSet([]byte(correlationTestRuleSet))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(set.Rules) != 2 || len(set.Correlations) != 2 {
+		t.Fatalf("expected 2 rules and 2 correlations, got %d and %d", len(set.Rules), len(set.Correlations))
+	}
+
+	ordered := set.Correlations[0]
+	if ordered.Correlation.Type != TemporalOrdered {
+		t.Errorf("expected a temporal_ordered correlation, got %s", ordered.Correlation.Type)
+	}
+	if time.Duration(ordered.Correlation.Timespan) != 24*time.Hour {
+		t.Errorf("expected a timespan of a day, got %v", time.Duration(ordered.Correlation.Timespan))
+	}
+
+	rules, err := ordered.Resolve(set.Rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 2 || rules[0].Title != "Failed Logon" || rules[1].Title != "Successful Logon" {
+		t.Fatalf("expected the rules to be resolved by name and ID in order, got %v", rules)
+	}
+	if field := ordered.Field("user", rules[1]); field != "SubjectUserName" {
+		t.Errorf("expected the alias to resolve to SubjectUserName, got %s", field)
+	}
+	if field := ordered.Field("host", rules[1]); field != "host" {
+		t.Errorf("expected fields without an alias to be kept, got %s", field)
+	}
+
+	counted := set.Correlations[1]
+	if counted.Correlation.Condition == nil || counted.Correlation.Condition.Field != "TargetUserName" {
+		t.Fatalf("expected a condition on TargetUserName, got %+v", counted.Correlation.Condition)
+	}
+	for count, match := range map[float64]bool{9: false, 10: true, 19: true, 20: false} {
+		if counted.Correlation.Condition.Matches(count) != match {
+			t.Errorf("expected a count of %v to match %v", count, match)
+		}
+	}
+	if !counted.Correlates(set.Rules[0]) || counted.Correlates(set.Rules[1]) {
+		t.Errorf("expected the correlation to reference only the failed logon rule")
+	}
+}
@@ -1,8 +1,9 @@
 package grammar
 
 type Condition struct {
-	Search      Disjunction  `@@`        // Represents the search condition
-	Aggregation *Aggregation `("|" @@)?` // Represents an optional aggregation function and its parameters
+	Search      Disjunction  `@@`              // Represents the search condition
+	Near        *Disjunction `("|" ("near" @@` // Represents an optional near expression that has to match close in time
+	Aggregation *Aggregation `| @@))?`         // Represents an optional aggregation function and its parameters
 }
 
 type Disjunction struct {
@@ -0,0 +1,137 @@
+package sigma
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CollectionAction is the action of a document in a Sigma rule collection, which defines how it is combined with the other documents.
+type CollectionAction string
+
+// The collection actions defined by the Sigma specification
+const (
+	GlobalAction CollectionAction = "global" // The document is merged into all the rules that follow it
+	ResetAction  CollectionAction = "reset"  // The global documents read so far are discarded
+	RepeatAction CollectionAction = "repeat" // The document is merged into a copy of the previous rule, giving a new rule
+)
+
+// ParseRuleCollection reads a YAML stream of one or more documents and returns the rules it holds, in the order they appear.
+// Documents with an action are combined with the rules around them as described by CollectionAction.
+// Correlation rules are skipped, use ParseRuleSet to read them as well.
+func ParseRuleCollection(input []byte) ([]Rule, error) {
+	set, err := ParseRuleSet(input)
+	return set.Rules, err
+}
+
+// parseCollection reads the documents of a YAML stream and applies their collection actions.
+// It returns the mapping node of every rule or correlation rule, in the order they appear.
+// The nodes keep the line numbers of the documents they were merged from, so that errors point at the right place.
+func parseCollection(input []byte) ([]*yaml.Node, error) {
+	var documents []*yaml.Node
+	var global, previous *yaml.Node
+
+	decoder := yaml.NewDecoder(bytes.NewReader(input))
+	for document := 0; ; document++ {
+		var node yaml.Node
+		if err := decoder.Decode(&node); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("error parsing document %d: %w", document, err)
+		}
+
+		// Skip empty documents, e.g. after a trailing document separator
+		root := &node
+		if root.Kind == yaml.DocumentNode && len(root.Content) > 0 {
+			root = root.Content[0]
+		}
+		if root.Kind == yaml.DocumentNode || root.Tag == "!!null" {
+			continue
+		}
+		if root.Kind != yaml.MappingNode {
+			return nil, fmt.Errorf("invalid document %d (line %d). Expected a mapping", document, root.Line)
+		}
+
+		action, root := removeAction(root)
+		switch CollectionAction(action) {
+		case GlobalAction:
+			global = mergeNodes(global, root)
+
+		case ResetAction:
+			global = nil
+
+		case RepeatAction:
+			if previous == nil {
+				return nil, fmt.Errorf("action repeat in document %d (line %d) doesn't follow a rule", document, root.Line)
+			}
+			previous = mergeNodes(previous, root)
+			documents = append(documents, previous)
+
+		case "":
+			previous = mergeNodes(global, root)
+			documents = append(documents, previous)
+
+		default:
+			return nil, fmt.Errorf("unknown action %s in document %d (line %d)", action, document, root.Line)
+		}
+	}
+	return documents, nil
+}
+
+// removeAction returns the action of a document and a copy of its mapping node without the action key.
+func removeAction(node *yaml.Node) (string, *yaml.Node) {
+	action := ""
+	stripped := *node
+	stripped.Content = nil
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == "action" {
+			action = node.Content[i+1].Value
+			continue
+		}
+		stripped.Content = append(stripped.Content, node.Content[i], node.Content[i+1])
+	}
+	return action, &stripped
+}
+
+// mergeNodes returns a copy of the base mapping node with the overlay merged into it.
+// Mappings are merged key by key, any other value of the overlay replaces the one of the base.
+// Keys of the base keep their position, new keys of the overlay are added after them.
+func mergeNodes(base *yaml.Node, overlay *yaml.Node) *yaml.Node {
+	if base == nil || base.Kind != yaml.MappingNode || overlay.Kind != yaml.MappingNode {
+		return copyNode(overlay)
+	}
+
+	merged := copyNode(base)
+	for i := 0; i+1 < len(overlay.Content); i += 2 {
+		key, value := overlay.Content[i], overlay.Content[i+1]
+
+		found := false
+		for j := 0; j+1 < len(merged.Content); j += 2 {
+			if merged.Content[j].Value == key.Value {
+				merged.Content[j+1] = mergeNodes(merged.Content[j+1], value)
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged.Content = append(merged.Content, copyNode(key), copyNode(value))
+		}
+	}
+	return merged
+}
+
+// copyNode returns a deep copy of a YAML node, so that merging into it leaves the original untouched.
+func copyNode(node *yaml.Node) *yaml.Node {
+	if node == nil {
+		return nil
+	}
+	copied := *node
+	copied.Content = make([]*yaml.Node, len(node.Content))
+	for i, child := range node.Content {
+		copied.Content[i] = copyNode(child)
+	}
+	return &copied
+}
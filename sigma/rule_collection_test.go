@@ -0,0 +1,100 @@
+package sigma
+
+import (
+	"strings"
+	"testing"
+)
+
+const ruleCollectionTest = `
+action: global
+title: Suspicious Use Of Net
+author: Logen
+detection:
+  condition: selection
+level: medium
+---
+id: 1
+logsource:
+  product: windows
+  service: sysmon
+detection:
+  selection:
+    Image|endswith: '\net.exe'
+---
+action: repeat
+id: 2
+logsource:
+  service: security
+level: high
+---
+action: reset
+---
+title: No Global
+id: 3
+logsource:
+  product: linux
+detection:
+  selection:
+    exe|endswith: /net
+  condition: selection
+---
+`
+
+func TestParseRuleCollection(t *testing.T) {
+	rules, err := ParseRuleCollection([]byte(ruleCollectionTest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 3 {
+		t.Fatalf("expected 3 rules, got %d", len(rules))
+	}
+
+	tt := []struct {
+		ID      string
+		Title   string
+		Author  string
+		Level   string
+		Product string
+		Service string
+	}{
+		{"1", "Suspicious Use Of Net", "Logen", "medium", "windows", "sysmon"},
+		{"2", "Suspicious Use Of Net", "Logen", "high", "windows", "security"},
+		{"3", "No Global", "", "", "linux", ""},
+	}
+
+	for i, tc := range tt {
+		rule := rules[i]
+		if rule.ID != tc.ID || rule.Title != tc.Title || rule.Author != tc.Author || rule.Level != tc.Level {
+			t.Errorf("rule %d: expected %s %q by %q at level %q, got %s %q by %q at level %q", i, tc.ID, tc.Title, tc.Author, tc.Level, rule.ID, rule.Title, rule.Author, rule.Level)
+		}
+		if rule.Logsource.Product != tc.Product || rule.Logsource.Service != tc.Service {
+			t.Errorf("rule %d: expected logsource %s/%s, got %s/%s", i, tc.Product, tc.Service, rule.Logsource.Product, rule.Logsource.Service)
+		}
+		if len(rule.Detection.Conditions) != 1 || len(rule.Detection.Searches) != 1 {
+			t.Errorf("rule %d: expected the detection to be merged, got %+v", i, rule.Detection)
+		}
+	}
+}
+
+func TestParseRuleCollection_Errors(t *testing.T) {
+	tt := []struct {
+		Name  string
+		Input string
+		Error string
+	}{
+		{"RepeatFirst", "action: repeat\ntitle: Nothing To Repeat\n", "doesn't follow a rule"},
+		{"UnknownAction", "action: merge\ntitle: Unknown\n", "unknown action merge"},
+		{"NotAMapping", "- title: A List\n", "Expected a mapping"},
+		// Errors point at the line of the document that the value was merged from
+		{"Line", "action: global\nlevel: high\n---\ntitle: Bad Level\ndetection:\n  selection:\n    foo: bar\n  condition: selection\nlevel:\n  - low\n", "line 10"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			_, err := ParseRuleCollection([]byte(tc.Input))
+			if err == nil || !strings.Contains(err.Error(), tc.Error) {
+				t.Errorf("expected an error containing %q, got %v", tc.Error, err)
+			}
+		})
+	}
+}
@@ -20,10 +20,11 @@ type FileType string
 
 // Possible file types
 const (
-	UnknownFile FileType = ""        // Unknown file type
-	InvalidFile FileType = "invalid" // Invalid file type
-	RuleFile    FileType = "rule"    // Sigma rule file type
-	ConfigFile  FileType = "config"  // Sigma config file type
+	UnknownFile     FileType = ""            // Unknown file type
+	InvalidFile     FileType = "invalid"     // Invalid file type
+	RuleFile        FileType = "rule"        // Sigma rule file type
+	ConfigFile      FileType = "config"      // Sigma config file type
+	CorrelationFile FileType = "correlation" // Sigma correlation rule file type
 )
 
 // UnmarshalYAML is a custom unmarshaller for the FileType type.
@@ -41,6 +42,10 @@ func (f *FileType) UnmarshalYAML(node *yaml.Node) error {
 			*f = ConfigFile // If the node contains the "logsources" key, assume it's a config file
 			return nil
 		}
+		if node.Kind == yaml.ScalarNode && node.Value == "correlation" {
+			*f = CorrelationFile // If the node contains the "correlation" key, assume it's a correlation rule file
+			return nil
+		}
 	}
 	return nil
 }
@@ -32,6 +32,16 @@ detection:
 `,
 			RuleFile, // The expected type is RuleFile
 		},
+		{
+			// An example correlation rule file content
+			`title: foo
+correlation:
+    type: event_count
+    rules:
+        - bar
+`,
+			CorrelationFile, // The expected type is CorrelationFile
+		},
 		{
 			// An example invalid file content
 			`this: |
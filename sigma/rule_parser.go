@@ -2,6 +2,7 @@ package sigma
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
@@ -16,6 +17,7 @@ type Rule struct {
 
 	// Optional fields
 	ID          string        `yaml:",omitempty" json:",omitempty"` // The unique ID of the rule
+	Name        string        `yaml:",omitempty" json:",omitempty"` // The unique name of the rule, used by correlation rules to reference it
 	Related     []RelatedRule `yaml:",omitempty" json:",omitempty"` // Related rules, if any
 	Status      string        `yaml:",omitempty" json:",omitempty"` // The status of the rule (e.g. "testing", "production")
 	Description string        `yaml:",omitempty" json:",omitempty"` // A brief description of the rule
@@ -44,9 +46,32 @@ type Logsource struct {
 }
 
 type Detection struct {
-	Searches   map[string]Search `yaml:",inline" json:",inline"`       // Searches holds a map of search query strings and their corresponding configurations.
-	Conditions Conditions        `yaml:"condition" json:"condition"`   // Conditions holds a slice of conditions to be checked for the detection to occur.
-	Timeframe  time.Duration     `yaml:",omitempty" json:",omitempty"` // Timeframe specifies the time duration within which the detection must occur.
+	Searches    map[string]Search `yaml:",inline" json:",inline"`       // Searches holds a map of search query strings and their corresponding configurations.
+	SearchOrder []string          `yaml:"-" json:",omitempty"`          // SearchOrder holds the identifiers of the searches in the order they are declared in the rule.
+	Conditions  Conditions        `yaml:"condition" json:"condition"`   // Conditions holds a slice of conditions to be checked for the detection to occur.
+	Timeframe   time.Duration     `yaml:",omitempty" json:",omitempty"` // Timeframe specifies the time duration within which the detection must occur.
+}
+
+// SearchNames returns the identifiers of the searches in the order they are declared in the rule.
+// Searches that are missing from SearchOrder, e.g. because they were added to the map in code, follow in alphabetical order.
+func (d Detection) SearchNames() []string {
+	names := make([]string, 0, len(d.Searches))
+	declared := make(map[string]bool, len(d.SearchOrder))
+	for _, name := range d.SearchOrder {
+		if _, ok := d.Searches[name]; ok && !declared[name] {
+			declared[name] = true
+			names = append(names, name)
+		}
+	}
+
+	var undeclared []string
+	for name := range d.Searches {
+		if !declared[name] {
+			undeclared = append(undeclared, name)
+		}
+	}
+	sort.Strings(undeclared)
+	return append(names, undeclared...)
 }
 
 func (d *Detection) UnmarshalYAML(node *yaml.Node) error {
@@ -86,6 +111,10 @@ func (d *Detection) UnmarshalYAML(node *yaml.Node) error {
 			if d.Searches == nil {
 				d.Searches = map[string]Search{}
 			}
+			// Searches are kept in the order they are declared in, which maps don't preserve
+			if _, ok := d.Searches[key.Value]; !ok {
+				d.SearchOrder = append(d.SearchOrder, key.Value)
+			}
 			d.Searches[key.Value] = search
 		}
 
@@ -93,6 +122,34 @@ func (d *Detection) UnmarshalYAML(node *yaml.Node) error {
 	return nil
 }
 
+// MarshalYAML encodes the Detection with its searches in the order they are declared in, followed by its conditions and timeframe.
+func (d Detection) MarshalYAML() (interface{}, error) {
+	result := &yaml.Node{Kind: yaml.MappingNode}
+	add := func(key string, value interface{}) error {
+		node := &yaml.Node{}
+		if err := node.Encode(value); err != nil {
+			return err
+		}
+		result.Content = append(result.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, node)
+		return nil
+	}
+
+	for _, name := range d.SearchNames() {
+		if err := add(name, d.Searches[name]); err != nil {
+			return nil, err
+		}
+	}
+	if err := add("condition", d.Conditions); err != nil {
+		return nil, err
+	}
+	if d.Timeframe != 0 {
+		if err := add("timeframe", d.Timeframe.String()); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
 type Conditions []Condition
 
 // UnmarshalYAML unmarshals the YAML node to the Conditions slice.
@@ -327,6 +384,7 @@ func (f *FieldMatcher) marshal() (field_node *yaml.Node, value_node *yaml.Node,
 }
 
 // ParseRule reads a byte slice and returns a parsed Rule object and an error (if any)
+// Only the first YAML document is read, use ParseRuleCollection for files with several documents.
 func ParseRule(input []byte) (Rule, error) {
 	// Create a Rule instance to hold the parsed YAML data
 	rule := Rule{}
@@ -89,3 +89,48 @@ func TestMarshalRule(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+// TestDetection_SearchNames checks that the searches keep the order they are declared in, also when the rule is marshalled and parsed again.
+func TestDetection_SearchNames(t *testing.T) {
+	rule, err := ParseRule([]byte(`
+title: Search Order
+logsource:
+  product: windows
+detection:
+  selection_process:
+    Image|endswith: '\cmd.exe'
+  filter:
+    User: SYSTEM
+  selection_network:
+    DestinationPort: 443
+  condition: 1 of selection_* and not filter
+  timeframe: 5m
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"selection_process", "filter", "selection_network"}
+	if !cmp.Equal(rule.Detection.SearchNames(), expected) {
+		t.Errorf("expected searches %v, got %v", expected, rule.Detection.SearchNames())
+	}
+
+	marshalled, err := yaml.Marshal(&rule)
+	if err != nil {
+		t.Fatal(err)
+	}
+	copied, err := ParseRule(marshalled)
+	if err != nil {
+		t.Fatalf("error parsing marshalled rule: %v\n%s", err, marshalled)
+	}
+	if !cmp.Equal(copied.Detection.SearchNames(), expected) || copied.Detection.Timeframe != rule.Detection.Timeframe {
+		t.Errorf("expected the marshalled rule to keep the searches %v and the timeframe, got:\n%s", expected, marshalled)
+	}
+
+	// Searches added in code follow the declared ones in alphabetical order
+	rule.Detection.Searches["b"] = Search{}
+	rule.Detection.Searches["a"] = Search{}
+	if names := rule.Detection.SearchNames(); !cmp.Equal(names, append(expected, "a", "b")) {
+		t.Errorf("expected the searches added in code to come last, got %v", names)
+	}
+}
@@ -0,0 +1,375 @@
+package sevaluator
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/mtnmunuklu/logen/sigma"
+)
+
+// DefaultTimeframe is the time window that the events of an aggregation are spread over if the rule doesn't declare a timeframe.
+const DefaultTimeframe = time.Minute
+
+// AggregationBurstSize is the number of events generated for min, max, avg and sum aggregations.
+const AggregationBurstSize = 3
+
+// MaxAggregationEvents is the maximum number of events generated for a single aggregation, to guard against huge thresholds.
+const MaxAggregationEvents = 10000
+
+// distinctAttempts is the number of times a value is regenerated when it has to differ from the values of the other events.
+const distinctAttempts = 100
+
+// generateEvents builds the synthetic events that together satisfy a condition of the rule.
+// A condition without an aggregation gets an event for each branch that satisfies it, an aggregation needs a burst of events
+// that match its first branch, and a near condition needs an event for the search followed by events for the near expression.
+// The events are generated from the query tree of the search of the condition.
+func (rule RuleEvaluator) generateEvents(ctx context.Context, condition sigma.Condition, node QueryNode) ([]Event, error) {
+	if condition.Aggregation == nil {
+		return rule.generateBranches(ctx, node)
+	}
+
+	switch aggregation := condition.Aggregation.(type) {
+	case sigma.Near:
+		return rule.generateNear(ctx, node, aggregation)
+	case sigma.Comparison:
+		builder, err := rule.constrainQuery(ctx, node)
+		if err != nil {
+			return nil, err
+		}
+		return rule.generateAggregation(aggregation, builder)
+	}
+	return nil, fmt.Errorf("unsupported aggregation %T", condition.Aggregation)
+}
+
+// generateAggregation builds a burst of events that match the search of a condition and together satisfy its aggregation.
+// All the events share the same value of the group-by field, and are timestamped within the timeframe of the rule by scheduleCondition.
+// Counted fields get a distinct value in every event, aggregated numeric fields get values whose min, max, avg or sum meets the threshold.
+func (rule RuleEvaluator) generateAggregation(comparison sigma.Comparison, builder *eventBuilder) ([]Event, error) {
+	field, groupedBy, err := aggregationFields(comparison.Func)
+	if err != nil {
+		return nil, err
+	}
+
+	var values []float64
+	count := AggregationBurstSize
+	switch comparison.Func.(type) {
+	case sigma.Count:
+		if count, err = aggregationCount(comparison); err != nil {
+			return nil, err
+		}
+	case sigma.Min:
+		values = minValues(aggregationTarget(comparison), count)
+	case sigma.Max:
+		values = maxValues(aggregationTarget(comparison), count)
+	case sigma.Average:
+		values = averageValues(aggregationTarget(comparison), count)
+	case sigma.Sum:
+		values = sumValues(aggregationTarget(comparison), count)
+	}
+
+	// Numeric aggregations need a field to aggregate
+	if values != nil && field == "" {
+		return nil, fmt.Errorf("aggregation %v needs a field", comparison.Func)
+	}
+
+	events := make([]Event, count)
+	seen := map[string]bool{}
+	for i := range events {
+		var err error
+		if events[i], err = builder.build(); err != nil {
+			return nil, err
+		}
+
+		if groupedBy != "" {
+			groupField := rule.eventField(groupedBy)
+			if i == 0 {
+				// The group-by value of the first event is shared with the rest of the burst
+				if _, ok := events[0].Fields[groupField]; !ok {
+					// Unconstrained values can always be generated
+					events[0].Fields[groupField], _ = builder.synthesize()
+				}
+			} else {
+				events[i].Fields[groupField] = events[0].Fields[groupField]
+			}
+		}
+
+		if field == "" {
+			continue
+		}
+		eventField := rule.eventField(field)
+		if values != nil {
+			events[i].Fields[eventField] = numericValue(values[i])
+			continue
+		}
+
+		// Counted fields need a different value in every event
+		value, ok := events[i].Fields[eventField]
+		for attempt := 0; !ok || seen[fmt.Sprint(value)]; attempt++ {
+			if attempt == distinctAttempts {
+				return nil, fmt.Errorf("unable to generate %d distinct values of %s", count, field)
+			}
+			var err error
+			if value, err = builder.synthesize(builder.constraints[eventField]...); err != nil {
+				return nil, err
+			}
+			ok = true
+		}
+		events[i].Fields[eventField] = value
+		seen[fmt.Sprint(value)] = true
+	}
+	return events, nil
+}
+
+// aggregationCount returns the number of events, or distinct values, that satisfies a count aggregation.
+func aggregationCount(comparison sigma.Comparison) (int, error) {
+	threshold := comparison.Threshold
+	var count float64
+	switch comparison.Op {
+	case sigma.GreaterThan, sigma.NotEqual:
+		count = math.Floor(threshold) + 1
+	case sigma.GreaterThanEqual:
+		count = math.Ceil(threshold)
+	case sigma.LessThan:
+		count = math.Ceil(threshold) - 1
+	case sigma.LessThanEqual:
+		count = math.Floor(threshold)
+	default:
+		if threshold != math.Floor(threshold) {
+			return 0, fmt.Errorf("no number of events equals %v", threshold)
+		}
+		count = threshold
+	}
+
+	// Bursts always have at least one event, which can't satisfy an upper bound below one
+	if count < 1 {
+		switch comparison.Op {
+		case sigma.LessThan, sigma.LessThanEqual, sigma.Equal:
+			return 0, fmt.Errorf("no burst of events satisfies count %s %v", comparison.Op, threshold)
+		}
+		count = 1
+	}
+	if count > MaxAggregationEvents {
+		return 0, fmt.Errorf("aggregation needs %v events, more than the maximum of %d", count, MaxAggregationEvents)
+	}
+	return int(count), nil
+}
+
+// aggregationTarget returns a value that satisfies the comparison of a numeric aggregation.
+func aggregationTarget(comparison sigma.Comparison) float64 {
+	switch comparison.Op {
+	case sigma.GreaterThan, sigma.NotEqual:
+		return comparison.Threshold + 1
+	case sigma.LessThan:
+		return comparison.Threshold - 1
+	default:
+		return comparison.Threshold
+	}
+}
+
+// minValues returns count values whose minimum is the target.
+func minValues(target float64, count int) []float64 {
+	values := make([]float64, count)
+	for i := range values {
+		values[i] = target + float64(i)
+	}
+	return values
+}
+
+// maxValues returns count values whose maximum is the target.
+func maxValues(target float64, count int) []float64 {
+	values := make([]float64, count)
+	for i := range values {
+		values[i] = target - float64(i)
+	}
+	return values
+}
+
+// averageValues returns count values spread evenly around the target, so that their average is the target.
+func averageValues(target float64, count int) []float64 {
+	values := make([]float64, count)
+	for i := range values {
+		values[i] = target + float64(i) - float64(count-1)/2
+	}
+	return values
+}
+
+// sumValues returns count values that add up to the target, the last value takes the remainder.
+func sumValues(target float64, count int) []float64 {
+	part := math.Floor(target / float64(count))
+	values := make([]float64, count)
+	for i := range values {
+		values[i] = part
+	}
+	values[count-1] = target - part*float64(count-1)
+	return values
+}
+
+// numericValue converts an aggregated value to an integer if it has no fractional part, as most numeric event fields are integers.
+func numericValue(value float64) interface{} {
+	if value == math.Trunc(value) && math.Abs(value) < math.MaxInt64 {
+		return int64(value)
+	}
+	return value
+}
+
+// MatchesAggregation reports whether events together satisfy the aggregation of a condition of the rule.
+// Only the events that match the search of the condition are aggregated. They are grouped by the group-by field,
+// and the aggregation is computed over every window of the timeframe of the rule, using the Timestamp of the events.
+// A near condition is satisfied if the near expression matches the events logged within the timeframe of an event that matches the search.
+// Conditions without an aggregation are satisfied by any matching event.
+func (rule RuleEvaluator) MatchesAggregation(ctx context.Context, conditionIndex int, events []Event) (bool, error) {
+	if conditionIndex < 0 || conditionIndex >= len(rule.Detection.Conditions) {
+		return false, fmt.Errorf("unknown condition %d", conditionIndex)
+	}
+	condition := rule.Detection.Conditions[conditionIndex]
+
+	results := make([]MatchResult, len(events))
+	var matching []Event
+	for i, event := range events {
+		var err error
+		results[i], err = rule.Matches(ctx, event)
+		if err != nil {
+			return false, err
+		}
+		if results[i].Conditions[conditionIndex] {
+			matching = append(matching, event)
+		}
+	}
+
+	var comparison sigma.Comparison
+	switch aggregation := condition.Aggregation.(type) {
+	case nil:
+		return len(matching) > 0, nil
+	case sigma.Near:
+		return rule.matchNear(conditionIndex, aggregation, events, results)
+	case sigma.Comparison:
+		comparison = aggregation
+	default:
+		return false, fmt.Errorf("unsupported aggregation %T", condition.Aggregation)
+	}
+
+	field, groupedBy, err := aggregationFields(comparison.Func)
+	if err != nil {
+		return false, err
+	}
+
+	// Group the events by the value of the group-by field
+	groups := map[string][]Event{}
+	for _, event := range matching {
+		key := ""
+		if groupedBy != "" {
+			key = fmt.Sprint(event.Fields[rule.eventField(groupedBy)])
+		}
+		groups[key] = append(groups[key], event)
+	}
+
+	timeframe := rule.timeframe()
+	for _, group := range groups {
+		sort.SliceStable(group, func(i, j int) bool { return group[i].Timestamp.Before(group[j].Timestamp) })
+
+		// Every event starts a window that holds the events logged within the timeframe after it
+		for start := range group {
+			end := start
+			for end < len(group) && group[end].Timestamp.Sub(group[start].Timestamp) < timeframe {
+				end++
+			}
+
+			value, ok := rule.aggregate(comparison.Func, field, group[start:end])
+			if ok && compareAggregation(comparison.Op, value, comparison.Threshold) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// aggregationFields returns the aggregated field and the group-by field of an aggregation function.
+func aggregationFields(function sigma.AggregationFunc) (string, string, error) {
+	switch f := function.(type) {
+	case sigma.Count:
+		return f.Field, f.GroupedBy, nil
+	case sigma.Min:
+		return f.Field, f.GroupedBy, nil
+	case sigma.Max:
+		return f.Field, f.GroupedBy, nil
+	case sigma.Average:
+		return f.Field, f.GroupedBy, nil
+	case sigma.Sum:
+		return f.Field, f.GroupedBy, nil
+	}
+	return "", "", fmt.Errorf("unsupported aggregation function %T", function)
+}
+
+// aggregate computes an aggregation function over events.
+// Counts without a field count the events and counts with a field count its distinct values.
+// The other functions only take the numeric values of the field into account, and return false if there are none.
+func (rule RuleEvaluator) aggregate(function sigma.AggregationFunc, field string, events []Event) (float64, bool) {
+	eventField := rule.eventField(field)
+
+	if _, ok := function.(sigma.Count); ok {
+		if field == "" {
+			return float64(len(events)), true
+		}
+		distinct := map[string]bool{}
+		for _, event := range events {
+			if value, ok := event.Fields[eventField]; ok && value != nil {
+				distinct[fmt.Sprint(value)] = true
+			}
+		}
+		return float64(len(distinct)), true
+	}
+
+	var values []float64
+	for _, event := range events {
+		value, err := strconv.ParseFloat(fmt.Sprint(event.Fields[eventField]), 64)
+		if err == nil {
+			values = append(values, value)
+		}
+	}
+	if len(values) == 0 {
+		return 0, false
+	}
+
+	result := values[0]
+	switch function.(type) {
+	case sigma.Min:
+		for _, value := range values[1:] {
+			result = math.Min(result, value)
+		}
+	case sigma.Max:
+		for _, value := range values[1:] {
+			result = math.Max(result, value)
+		}
+	case sigma.Average, sigma.Sum:
+		for _, value := range values[1:] {
+			result += value
+		}
+		if _, ok := function.(sigma.Average); ok {
+			result /= float64(len(values))
+		}
+	}
+	return result, true
+}
+
+// compareAggregation compares an aggregated value with the threshold of a comparison.
+func compareAggregation(op sigma.ComparisonOp, value float64, threshold float64) bool {
+	switch op {
+	case sigma.Equal:
+		return value == threshold
+	case sigma.NotEqual:
+		return value != threshold
+	case sigma.LessThan:
+		return value < threshold
+	case sigma.LessThanEqual:
+		return value <= threshold
+	case sigma.GreaterThan:
+		return value > threshold
+	case sigma.GreaterThanEqual:
+		return value >= threshold
+	}
+	return false
+}
@@ -0,0 +1,110 @@
+package sevaluator_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mtnmunuklu/logen/sigma"
+	"github.com/mtnmunuklu/logen/sigma/sevaluator"
+)
+
+const aggregateTestRule = `
+title: Aggregate Test
+logsource:
+  category: process_creation
+  product: windows
+detection:
+  selection:
+    Image|endswith: '\net.exe'
+  timeframe: 10m
+  condition: selection | %s
+`
+
+// TestRuleEvaluator_Aggregations checks that aggregation conditions get a burst of events that satisfies them within the timeframe.
+func TestRuleEvaluator_Aggregations(t *testing.T) {
+	config, err := sigma.ParseConfig([]byte(testConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tt := []struct {
+		Aggregation string
+		Events      int
+		NeedsAll    bool // Whether the aggregation is missed without the first event
+	}{
+		{"count() > 5", 6, true},
+		{"count() >= 3", 3, true},
+		{"count() < 3", 2, false},
+		{"count(CommandLine) by User > 4", 5, true},
+		{"count(Image) = 2", 2, true},
+		{"min(Bytes) >= 100", sevaluator.AggregationBurstSize, false},
+		{"max(Bytes) < 10", sevaluator.AggregationBurstSize, false},
+		{"avg(Bytes) by User > 1000", sevaluator.AggregationBurstSize, false},
+		{"sum(Bytes) = 1000", sevaluator.AggregationBurstSize, true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Aggregation, func(t *testing.T) {
+			rule, err := sigma.ParseRule([]byte(fmt.Sprintf(aggregateTestRule, tc.Aggregation)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			evaluator := sevaluator.ForRule(rule, sevaluator.WithConfig(config))
+			result, err := evaluator.Alters(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			events := result.Events[0]
+			if len(events) != tc.Events {
+				t.Fatalf("expected %d events, got %d", tc.Events, len(events))
+			}
+			for _, event := range events {
+				if offset := event.Timestamp.Sub(events[0].Timestamp); offset < 0 || offset >= 10*time.Minute {
+					t.Errorf("event logged outside the timeframe at %v", offset)
+				}
+			}
+			if !result.Verifications[0].Passed() {
+				t.Errorf("expected the events to pass verification, got %+v", result.Verifications[0])
+			}
+
+			// Dropping the first event breaks the aggregations that need every event of the burst
+			aggregated, err := evaluator.MatchesAggregation(context.Background(), 0, events[1:])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if aggregated == tc.NeedsAll {
+				t.Errorf("expected the aggregation to be satisfied without the first event: %v, got %v", !tc.NeedsAll, aggregated)
+			}
+		})
+	}
+}
+
+// TestRuleEvaluator_ImpossibleAggregations checks that aggregations that no burst of events can satisfy are reported as errors.
+func TestRuleEvaluator_ImpossibleAggregations(t *testing.T) {
+	for _, aggregation := range []string{"count() < 1", "count() <= 0", "count() = 0", "count(User) < 0"} {
+		rule, err := sigma.ParseRule([]byte(fmt.Sprintf(aggregateTestRule, aggregation)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := sevaluator.ForRule(rule).Alters(context.Background()); err == nil {
+			t.Errorf("expected an error for %s", aggregation)
+		}
+	}
+
+	// Lower bounds below one are met by a single event
+	rule, err := sigma.ParseRule([]byte(fmt.Sprintf(aggregateTestRule, "count() >= 0")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := sevaluator.ForRule(rule).Alters(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Events[0]) != 1 || !result.Verifications[0].Passed() {
+		t.Errorf("expected a single event that passes verification, got %v and %+v", result.Events[0], result.Verifications[0])
+	}
+}
@@ -0,0 +1,367 @@
+package sevaluator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ErrUnsupported is returned by a Backend for predicates that can't be expressed in its query language.
+var ErrUnsupported = errors.New("not supported by the query language")
+
+// unsupported returns an ErrUnsupported error for the given kind of predicate.
+func unsupported(what string) error {
+	return fmt.Errorf("%s are %w", what, ErrUnsupported)
+}
+
+// Backend renders the conditions of Sigma rules as queries in the language of a SIEM.
+// RenderQuery walks the query tree of a condition and calls the backend for its predicates and the boolean operators that combine them,
+// grouping the operands that are combinations themselves in parentheses.
+type Backend interface {
+	Predicate(predicate FieldPredicate) (string, error) // Predicate renders a predicate, or returns ErrUnsupported if it can't be expressed
+	And(operands []string) string                       // And renders the conjunction of two or more operands
+	Or(operands []string) string                        // Or renders the disjunction of two or more operands
+	Not(operand string) string                          // Not renders the negation of an operand
+	Query(indexes []string, expression string) string   // Query renders the query of an expression against the indexes of the rule, the expression is empty if it matches any event
+}
+
+// pipeBackend is implemented by backends that can filter the results of a search with predicates that the search itself can't express,
+// e.g. regular expressions in Splunk, as long as every event that matches the condition has to satisfy them.
+type pipeBackend interface {
+	Backend
+	Filter(predicate FieldPredicate) (string, error) // Filter renders a predicate as a filter of the results of the search
+	Pipe(query string, filters []string) string      // Pipe appends the filters to the query
+}
+
+// Backends maps the names of the supported query languages to their Backend.
+var Backends = map[string]Backend{
+	"splunk": splunk{},
+	"lucene": lucene{},
+	"kql":    kibana{},
+	"eql":    eql{},
+	"esql":   esql{},
+	"kusto":  kusto{},
+	"aql":    aql{},
+}
+
+// BackendDescriptions maps the names of the supported query languages to a human readable description.
+var BackendDescriptions = map[string]string{
+	"splunk": "Splunk Search Processing Language (SPL)",
+	"lucene": "Elasticsearch Lucene query string",
+	"kql":    "Kibana Query Language (KQL)",
+	"eql":    "Elastic Event Query Language (EQL)",
+	"esql":   "Elasticsearch Query Language (ES|QL)",
+	"kusto":  "Microsoft Kusto Query Language (KQL)",
+	"aql":    "IBM QRadar Ariel Query Language (AQL)",
+}
+
+// LookupBackend returns the Backend registered under the given name.
+func LookupBackend(name string) (Backend, error) {
+	backend, ok := Backends[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown query backend %s", name)
+	}
+	return backend, nil
+}
+
+// BackendNames returns the sorted names of the supported query languages.
+func BackendNames() []string {
+	names := make([]string, 0, len(Backends))
+	for name := range Backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// clause is a rendered part of a query. Compound clauses are grouped in parentheses when they are combined with others.
+type clause struct {
+	text     string
+	compound bool
+}
+
+// queryBuilder combines the clauses of a query with the boolean operators of a Backend.
+type queryBuilder struct {
+	backend Backend
+	filters []string // The predicates that are piped after the search, for backends that can't express them within it
+}
+
+// and returns the conjunction of the clauses, leaving out the empty ones.
+func (q *queryBuilder) and(clauses []clause) clause {
+	operands := q.group(clauses)
+	switch len(operands) {
+	case 0:
+		return clause{}
+	case 1:
+		return nonEmpty(clauses)[0]
+	}
+	return clause{text: q.backend.And(operands), compound: true}
+}
+
+// or returns the disjunction of the clauses, leaving out the empty ones.
+func (q *queryBuilder) or(clauses []clause) clause {
+	operands := q.group(clauses)
+	switch len(operands) {
+	case 0:
+		return clause{}
+	case 1:
+		return nonEmpty(clauses)[0]
+	}
+	return clause{text: q.backend.Or(operands), compound: true}
+}
+
+// not returns the negation of the clause.
+func (q *queryBuilder) not(c clause) clause {
+	if c.text == "" {
+		return c
+	}
+	return clause{text: q.backend.Not(q.group([]clause{c})[0])}
+}
+
+// group returns the text of the non-empty clauses, with the compound ones in parentheses.
+func (q *queryBuilder) group(clauses []clause) []string {
+	var operands []string
+	for _, c := range nonEmpty(clauses) {
+		if c.compound {
+			operands = append(operands, "("+c.text+")")
+		} else {
+			operands = append(operands, c.text)
+		}
+	}
+	return operands
+}
+
+// nonEmpty returns the clauses that aren't empty.
+func nonEmpty(clauses []clause) []clause {
+	var result []clause
+	for _, c := range clauses {
+		if c.text != "" {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// predicate renders a predicate. If every event that matches the condition has to satisfy it, predicates that the backend can't
+// express within the search are piped after it instead, if the backend supports that.
+func (q *queryBuilder) predicate(predicate FieldPredicate, required bool) (clause, error) {
+	text, err := q.backend.Predicate(predicate)
+	if pipe, ok := q.backend.(pipeBackend); ok && required && errors.Is(err, ErrUnsupported) {
+		if filter, filterErr := pipe.Filter(predicate); filterErr == nil {
+			q.filters = append(q.filters, filter)
+			return clause{}, nil
+		}
+	}
+	if err != nil {
+		return clause{}, fmt.Errorf("%s|%s: %w", predicate.Field, predicate.Operator, err)
+	}
+	return clause{text: text}, nil
+}
+
+// build renders the query of the expression against the indexes, followed by the filters piped after it.
+func (q *queryBuilder) build(indexes []string, expression clause) string {
+	query := q.backend.Query(indexes, expression.text)
+	if pipe, ok := q.backend.(pipeBackend); ok && len(q.filters) > 0 {
+		query = pipe.Pipe(query, q.filters)
+	}
+	return query
+}
+
+// render renders a query tree.
+// Required is whether every event that matches the query has to match the node.
+func (q *queryBuilder) render(node QueryNode, required bool) (clause, error) {
+	switch n := node.(type) {
+	case And:
+		return q.renderAll(n, required, true)
+
+	case Or:
+		return q.renderAll(n, required && len(n) == 1, false)
+
+	case Not:
+		c, err := q.render(n.Node, false)
+		if err != nil {
+			return clause{}, err
+		}
+		return q.not(c), nil
+
+	case FieldPredicate:
+		return q.predicate(n, required)
+	}
+	return clause{}, fmt.Errorf("unhandled node type %T", node)
+}
+
+// renderAll renders the conjunction or disjunction of query nodes.
+func (q *queryBuilder) renderAll(nodes []QueryNode, required bool, all bool) (clause, error) {
+	var clauses []clause
+	for _, node := range nodes {
+		c, err := q.render(node, required)
+		if err != nil {
+			return clause{}, err
+		}
+		clauses = append(clauses, c)
+	}
+	if all {
+		return q.and(clauses), nil
+	}
+	return q.or(clauses), nil
+}
+
+// RenderQuery renders a query tree as a query in the language of the backend, which runs against the given indexes.
+func RenderQuery(backend Backend, indexes []string, node QueryNode) (string, error) {
+	query := &queryBuilder{backend: backend}
+	c, err := query.render(node, true)
+	if err != nil {
+		return "", err
+	}
+	return query.build(indexes, c), nil
+}
+
+// Render renders the search expression of every condition of the rule as a query in the language of the backend.
+// The conditions that the config requires of the logsource are part of every query, which runs against the indexes of the rule.
+// Aggregations aren't rendered: the queries find the events that are aggregated.
+func (rule RuleEvaluator) Render(ctx context.Context, backend Backend) (map[int]string, error) {
+	logsource, err := rule.compileLogsource(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	queries := make(map[int]string, len(rule.Detection.Conditions))
+	for conditionIndex, condition := range rule.Detection.Conditions {
+		node, err := rule.compileSearchExpression(ctx, condition.Search, nil)
+		if err != nil {
+			return nil, fmt.Errorf("error rendering condition %d: %w", conditionIndex, err)
+		}
+		queries[conditionIndex], err = RenderQuery(backend, rule.indexes, And{logsource, node})
+		if err != nil {
+			return nil, fmt.Errorf("error rendering condition %d: %w", conditionIndex, err)
+		}
+	}
+	return queries, nil
+}
+
+// globPart is either literal text or a wildcard of a Sigma wildcard pattern.
+type globPart struct {
+	literal  string // The literal text, empty for wildcards
+	wildcard byte   // The wildcard, * for any sequence of characters or ? for a single one, zero for literal text
+}
+
+// parseGlob splits a Sigma wildcard pattern into literal text and wildcards, so that backends can render each in their own syntax.
+// Wildcards are escaped with a backslash, as are backslashes that precede a wildcard.
+func parseGlob(pattern string) []globPart {
+	var parts []globPart
+	var literal strings.Builder
+	flush := func() {
+		if literal.Len() > 0 {
+			parts = append(parts, globPart{literal: literal.String()})
+			literal.Reset()
+		}
+	}
+
+	for i := 0; i < len(pattern); i++ {
+		switch char := pattern[i]; {
+		case char == '\\' && i+1 < len(pattern) && strings.IndexByte(`*?\`, pattern[i+1]) >= 0:
+			literal.WriteByte(pattern[i+1])
+			i++
+		case char == '*' || char == '?':
+			flush()
+			parts = append(parts, globPart{wildcard: char})
+		default:
+			literal.WriteByte(char)
+		}
+	}
+	flush()
+	return parts
+}
+
+// globLiteral returns the text of a pattern without wildcards, and whether it has none.
+func globLiteral(parts []globPart) (string, bool) {
+	var text strings.Builder
+	for _, part := range parts {
+		if part.wildcard != 0 {
+			return "", false
+		}
+		text.WriteString(part.literal)
+	}
+	return text.String(), true
+}
+
+// renderGlob renders a pattern with the given wildcards, escaping its literal text with the given function.
+func renderGlob(parts []globPart, escape func(string) string, many, one string) string {
+	var result strings.Builder
+	for _, part := range parts {
+		switch part.wildcard {
+		case '*':
+			result.WriteString(many)
+		case '?':
+			result.WriteString(one)
+		default:
+			result.WriteString(escape(part.literal))
+		}
+	}
+	return result.String()
+}
+
+// globRegexp renders a pattern as a regular expression in the RE2 or Java syntax that matches the whole value.
+func globRegexp(parts []globPart, caseSensitive bool) string {
+	flags := "(?is)^"
+	if caseSensitive {
+		flags = "(?s)^"
+	}
+	return flags + renderGlob(parts, regexp.QuoteMeta, ".*", ".") + "$"
+}
+
+// luceneRegexpEscaper escapes the reserved characters of Lucene regular expressions.
+var luceneRegexpEscaper = strings.NewReplacer(
+	`\`, `\\`, `.`, `\.`, `?`, `\?`, `+`, `\+`, `*`, `\*`, `|`, `\|`, `{`, `\{`, `}`, `\}`, `[`, `\[`, `]`, `\]`,
+	`(`, `\(`, `)`, `\)`, `"`, `\"`, `#`, `\#`, `@`, `\@`, `&`, `\&`, `<`, `\<`, `>`, `\>`, `~`, `\~`,
+)
+
+// globLuceneRegexp renders a pattern as a Lucene regular expression, which always matches the whole value.
+func globLuceneRegexp(parts []globPart) string {
+	return renderGlob(parts, luceneRegexpEscaper.Replace, ".*", ".")
+}
+
+// regexpFlags splits the leading flags, e.g. (?i), from a regular expression.
+func regexpFlags(pattern string) (string, string) {
+	if match := leadingFlags.FindStringSubmatch(pattern); match != nil {
+		return match[1], pattern[len(match[0]):]
+	}
+	return "", pattern
+}
+
+// leadingFlags matches the flags at the start of a regular expression.
+var leadingFlags = regexp.MustCompile(`^\(\?([a-zA-Z]+)\)`)
+
+// anchoredRegexp converts a Sigma regular expression, which matches anywhere in the value unless it's anchored,
+// to a Lucene regular expression, which always matches the whole value and has no anchors.
+// Flags aren't supported by Lucene, the case-insensitive flag is returned so that backends can handle it on their own.
+func anchoredRegexp(pattern string) (string, bool, error) {
+	flags, pattern := regexpFlags(pattern)
+	caseInsensitive := false
+	for _, flag := range flags {
+		switch flag {
+		case 'i':
+			caseInsensitive = true
+		case 's':
+			// Any character, including newlines, matches the . of Lucene regular expressions
+		default:
+			return "", false, unsupported("regular expression flags other than i and s")
+		}
+	}
+
+	start, end := ".*", ".*"
+	if strings.HasPrefix(pattern, "^") {
+		start, pattern = "", pattern[1:]
+	}
+	if strings.HasSuffix(pattern, "$") && !strings.HasSuffix(pattern, `\$`) {
+		end, pattern = "", pattern[:len(pattern)-1]
+	}
+	// Alternatives have to be grouped, or the wildcards would only apply to the first and the last of them
+	if (start != "" || end != "") && strings.Contains(pattern, "|") {
+		pattern = "(" + pattern + ")"
+	}
+	return start + pattern + end, caseInsensitive, nil
+}
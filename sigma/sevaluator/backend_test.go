@@ -0,0 +1,152 @@
+package sevaluator_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/mtnmunuklu/logen/sigma"
+	"github.com/mtnmunuklu/logen/sigma/sevaluator"
+)
+
+const backendTestRule = `
+title: Backend Test
+logsource:
+  category: network_connection
+  product: windows
+detection:
+  selection:
+    Image|endswith: '\powershell.exe'
+    CommandLine|contains|all:
+      - 'Invoke-'
+      - 'http://'
+  hash:
+    Hashes|contains:
+      - 'MD5=AB?D'
+      - 'quo"te'
+  network:
+    DestinationIp|cidr: '10.0.0.0/8'
+    DestinationPort|gte: 1024
+  filter:
+    User|startswith: 'NT AUTHORITY'
+    ParentImage: null
+  condition: selection and 1 of hash and network and not filter
+`
+
+const backendTestConfig = `
+title: Backend Test Config
+logsources:
+  network_connection:
+    category: network_connection
+    product: windows
+    index: winlogbeat-*
+    conditions:
+      EventID: 3
+fieldmappings:
+  User:
+    - user.name
+    - winlog.user.name
+`
+
+// TestRuleEvaluator_Render checks the queries of a rule in every query language, with the field mappings, indexes and logsource conditions of a config.
+func TestRuleEvaluator_Render(t *testing.T) {
+	rule, err := sigma.ParseRule([]byte(backendTestRule))
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := sigma.ParseConfig([]byte(backendTestConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"aql":    `SELECT * FROM events WHERE "EventID" = 3 AND (("Image" ILIKE '%\\powershell.exe' AND ("CommandLine" ILIKE '%Invoke-%' AND "CommandLine" ILIKE '%http://%')) AND ("Hashes" ILIKE '%MD5=AB_D%' OR "Hashes" ILIKE '%quo"te%') AND (INCIDR('10.0.0.0/8', "DestinationIp") AND "DestinationPort" >= 1024) AND NOT (("user.name" ILIKE 'NT AUTHORITY%' OR "winlog.user.name" ILIKE 'NT AUTHORITY%') AND "ParentImage" IS NULL))`,
+		"eql":    `any where EventID == 3 and ((Image like~ "*\\powershell.exe" and (CommandLine like~ "*Invoke-*" and CommandLine like~ "*http://*")) and (Hashes like~ "*MD5=AB?D*" or Hashes like~ "*quo\"te*") and (cidrMatch(DestinationIp, "10.0.0.0/8") and DestinationPort >= 1024) and not ((user.name like~ "NT AUTHORITY*" or winlog.user.name like~ "NT AUTHORITY*") and ParentImage == null))`,
+		"esql":   `FROM winlogbeat-* | WHERE EventID == 3 AND ((TO_LOWER(Image) LIKE "*\\\\powershell.exe" AND (TO_LOWER(CommandLine) LIKE "*invoke-*" AND TO_LOWER(CommandLine) LIKE "*http://*")) AND (TO_LOWER(Hashes) LIKE "*md5=ab?d*" OR TO_LOWER(Hashes) LIKE "*quo\"te*") AND (CIDR_MATCH(DestinationIp, "10.0.0.0/8") AND DestinationPort >= 1024) AND NOT ((TO_LOWER(user.name) LIKE "nt authority*" OR TO_LOWER(winlog.user.name) LIKE "nt authority*") AND ParentImage IS NULL))`,
+		"kql":    `EventID:3 and ((Image:*\\powershell.exe and (CommandLine:*Invoke-* and CommandLine:*http\://*)) and (Hashes:*MD5=AB*D* or Hashes:*quo\"te*) and (DestinationIp:"10.0.0.0/8" and DestinationPort >= 1024) and not ((user.name:NT AUTHORITY* or winlog.user.name:NT AUTHORITY*) and not ParentImage:*))`,
+		"kusto":  `union winlogbeat-* | where EventID == 3 and ((Image endswith "\\powershell.exe" and (CommandLine contains "Invoke-" and CommandLine contains "http://")) and (Hashes matches regex "(?is)^.*MD5=AB.D.*$" or Hashes contains "quo\"te") and (ipv4_is_in_range(DestinationIp, "10.0.0.0/8") and DestinationPort >= 1024) and not((['user.name'] startswith "NT AUTHORITY" or ['winlog.user.name'] startswith "NT AUTHORITY") and isempty(ParentImage)))`,
+		"lucene": `EventID:3 AND ((Image:*\\powershell.exe AND (CommandLine:*Invoke\-* AND CommandLine:*http\:\/\/*)) AND (Hashes:*MD5\=AB?D* OR Hashes:*quo\"te*) AND (DestinationIp:"10.0.0.0/8" AND DestinationPort:>=1024) AND NOT ((user.name:NT\ AUTHORITY* OR winlog.user.name:NT\ AUTHORITY*) AND NOT _exists_:ParentImage))`,
+		"splunk": `index="winlogbeat-*" EventID=3 AND ((Image="*\\powershell.exe" AND (CommandLine="*Invoke-*" AND CommandLine="*http://*")) AND (Hashes="*MD5=AB*D*" OR Hashes="*quo\"te*") AND (DestinationIp="10.0.0.0/8" AND DestinationPort>=1024) AND NOT ((user.name="NT AUTHORITY*" OR winlog.user.name="NT AUTHORITY*") AND NOT ParentImage=*))`,
+	}
+	for _, name := range sevaluator.BackendNames() {
+		queries, err := sevaluator.ForRule(rule, sevaluator.WithConfig(config)).Render(context.Background(), sevaluator.Backends[name])
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if queries[0] != expected[name] {
+			t.Errorf("%s: expected query:\n%s\ngot:\n%s", name, expected[name], queries[0])
+		}
+	}
+}
+
+const backendModifiersTestRule = `
+title: Backend Modifiers Test
+logsource:
+  category: process_creation
+  product: windows
+detection:
+  keywords:
+    - 'mimikatz'
+  selection:
+    CommandLine|re|i: 'sekurlsa'
+    TargetUser|fieldref: SourceUser
+  casing:
+    Image|cased|endswith: '\cmd.exe'
+  condition: %s
+`
+
+// TestRuleEvaluator_RenderModifiers checks the rendering of keywords, regular expressions, field references and case-sensitive values,
+// and that predicates a query language can't express are piped after the search, or fail with ErrUnsupported.
+func TestRuleEvaluator_RenderModifiers(t *testing.T) {
+	tests := []struct {
+		backend     string
+		condition   string
+		expected    string
+		unsupported bool
+	}{
+		{backend: "splunk", condition: "selection", expected: `* | regex CommandLine="(?i)sekurlsa" | where lower(TargetUser)==lower(SourceUser)`},
+		{backend: "splunk", condition: "keywords or selection", unsupported: true},
+		{backend: "splunk", condition: "casing", expected: `Image=CASE("*\\cmd.exe")`},
+		{backend: "kql", condition: "selection", unsupported: true},
+		{backend: "eql", condition: "selection", expected: `any where CommandLine regex~ ".*sekurlsa.*" and (startsWith~(TargetUser, SourceUser) and length(TargetUser) == length(SourceUser))`},
+		{backend: "esql", condition: "casing", expected: `FROM * | WHERE Image LIKE "*\\\\cmd.exe"`},
+		{backend: "kusto", condition: "keywords", expected: `union * | where message contains "mimikatz"`},
+		{backend: "lucene", condition: "keywords", expected: `message:*mimikatz*`},
+	}
+
+	for _, test := range tests {
+		rule, err := sigma.ParseRule([]byte(fmt.Sprintf(backendModifiersTestRule, test.condition)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		queries, err := sevaluator.ForRule(rule).Render(context.Background(), sevaluator.Backends[test.backend])
+		switch {
+		case test.unsupported && !errors.Is(err, sevaluator.ErrUnsupported):
+			t.Errorf("%s %s: expected ErrUnsupported, got %v", test.backend, test.condition, err)
+		case test.unsupported:
+		case err != nil:
+			t.Errorf("%s %s: %v", test.backend, test.condition, err)
+		case queries[0] != test.expected:
+			t.Errorf("%s %s: expected query:\n%s\ngot:\n%s", test.backend, test.condition, test.expected, queries[0])
+		}
+	}
+}
+
+// TestRuleEvaluator_WithBackend checks that the Queries of the Result are rendered in the query language of the backend.
+func TestRuleEvaluator_WithBackend(t *testing.T) {
+	rule, err := sigma.ParseRule([]byte(fmt.Sprintf(backendModifiersTestRule, "keywords and not casing")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := sevaluator.ForRule(rule, sevaluator.WithBackend(sevaluator.Backends["aql"])).Alters(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `SELECT * FROM events WHERE "message" ILIKE '%mimikatz%' AND NOT "Image" LIKE '%\\cmd.exe'`; result.Queries[0] != expected {
+		t.Errorf("expected query:\n%s\ngot:\n%s", expected, result.Queries[0])
+	}
+}
@@ -0,0 +1,709 @@
+package sevaluator
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// comparisonOperators maps the numeric comparators to the operators that most query languages use for them.
+var comparisonOperators = map[string]string{
+	"gt":  ">",
+	"gte": ">=",
+	"lt":  "<",
+	"lte": "<=",
+}
+
+// trimWildcards removes a leading and a trailing * from a pattern, and returns whether it had them.
+// Query languages that have contains, startswith and endswith operators use them for patterns whose wildcards are all at the ends.
+func trimWildcards(parts []globPart) ([]globPart, bool, bool) {
+	leading := len(parts) > 0 && parts[0].wildcard == '*'
+	if leading {
+		parts = parts[1:]
+	}
+	trailing := len(parts) > 0 && parts[len(parts)-1].wildcard == '*'
+	if trailing {
+		parts = parts[:len(parts)-1]
+	}
+	return parts, leading, trailing
+}
+
+// splunk renders queries in the Splunk Search Processing Language.
+// The search command matches values case-insensitively unless they are wrapped in CASE(). It has no single-character wildcard
+// and can't escape an asterisk, so both match any sequence of characters. Regular expressions and field references are piped
+// to the regex and where commands, as long as every matching event has to satisfy them.
+type splunk struct{}
+
+// splunkEscaper escapes the quoted strings of the search command.
+var splunkEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// splunkPlainField matches the field names that don't have to be quoted.
+var splunkPlainField = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.\-]*$`)
+
+// field renders a field name in the search command.
+func (splunk) field(name string) string {
+	if splunkPlainField.MatchString(name) {
+		return name
+	}
+	return `"` + splunkEscaper.Replace(name) + `"`
+}
+
+// evalField renders a field name in the expressions of the where command.
+func (splunk) evalField(name string) string {
+	if splunkPlainField.MatchString(name) {
+		return name
+	}
+	return "'" + strings.ReplaceAll(name, "'", `\'`) + "'"
+}
+
+// Predicate renders a predicate as a comparison of the search command.
+func (s splunk) Predicate(p FieldPredicate) (string, error) {
+	field := s.field(p.Field)
+	switch {
+	case p.Reference:
+		return "", unsupported("field references")
+	case p.Operator == "re":
+		return "", unsupported("regular expressions")
+	case p.Operator == "exists" && p.Value == "true":
+		return field + "=*", nil
+	case p.Operator == "exists":
+		return "NOT " + field + "=*", nil
+	case p.Operator == "cidr":
+		// The search command matches IP addresses against CIDR ranges
+		return field + `="` + splunkEscaper.Replace(p.Value) + `"`, nil
+	case p.Numeric:
+		return field + "=" + p.Value, nil
+	case comparisonOperators[p.Operator] != "":
+		return field + comparisonOperators[p.Operator] + p.Value, nil
+	}
+
+	value := `"` + renderGlob(parseGlob(p.Pattern()), splunkEscaper.Replace, "*", "*") + `"`
+	if p.CaseSensitive {
+		value = "CASE(" + value + ")"
+	}
+	return field + "=" + value, nil
+}
+
+// Filter renders a regular expression as a regex command, and a field reference as a where command.
+func (s splunk) Filter(p FieldPredicate) (string, error) {
+	if p.Operator == "re" && !p.Reference {
+		return "regex " + s.field(p.Field) + `="` + strings.ReplaceAll(p.Value, `"`, `\"`) + `"`, nil
+	}
+	if !p.Reference {
+		return "", unsupported(p.Operator + " filters")
+	}
+
+	field, reference := s.evalField(p.Field), s.evalField(p.Value)
+	if !p.CaseSensitive {
+		field, reference = "lower("+field+")", "lower("+reference+")"
+	}
+	switch p.Operator {
+	case "equal":
+		return "where " + field + "==" + reference, nil
+	case "contains":
+		// The value contains the referenced one if splitting the value at it gives several parts
+		return "where mvcount(split(" + field + ", " + reference + "))>1", nil
+	case "startswith":
+		return "where substr(" + field + ", 1, len(" + reference + "))==" + reference, nil
+	case "endswith":
+		return "where substr(" + field + ", -len(" + reference + "))==" + reference, nil
+	}
+	return "", unsupported(fmt.Sprintf("field references compared with %s", p.Operator))
+}
+
+// And renders operands that all have to match.
+func (splunk) And(operands []string) string {
+	return strings.Join(operands, " AND ")
+}
+
+// Or renders operands of which any has to match.
+func (splunk) Or(operands []string) string {
+	return strings.Join(operands, " OR ")
+}
+
+// Not renders an operand that must not match.
+func (splunk) Not(operand string) string {
+	return "NOT " + operand
+}
+
+// Query renders a search of the indexes. The implicit AND that follows the indexes binds looser than OR, so the expression isn't grouped.
+func (splunk) Query(indexes []string, expression string) string {
+	var terms []string
+	for _, index := range indexes {
+		terms = append(terms, `index="`+splunkEscaper.Replace(index)+`"`)
+	}
+	if len(terms) > 1 {
+		terms = []string{"(" + strings.Join(terms, " OR ") + ")"}
+	}
+	if expression != "" {
+		terms = append(terms, expression)
+	}
+	if len(terms) == 0 {
+		return "*"
+	}
+	return strings.Join(terms, " ")
+}
+
+// Pipe pipes the results of the search to the filters.
+func (splunk) Pipe(query string, filters []string) string {
+	return query + " | " + strings.Join(filters, " | ")
+}
+
+// lucene renders queries in the Lucene query string syntax of Elasticsearch.
+// Values are compared as keywords, whether that's case-insensitive depends on the normalizer of the field mapping.
+type lucene struct{}
+
+// luceneEscaper escapes the reserved characters and whitespace of the query string syntax.
+var luceneEscaper = strings.NewReplacer(
+	`\`, `\\`, `+`, `\+`, `-`, `\-`, `=`, `\=`, `&`, `\&`, `|`, `\|`, `>`, `\>`, `<`, `\<`, `!`, `\!`, `(`, `\(`, `)`, `\)`,
+	`{`, `\{`, `}`, `\}`, `[`, `\[`, `]`, `\]`, `^`, `\^`, `"`, `\"`, `~`, `\~`, `*`, `\*`, `?`, `\?`, `:`, `\:`, `/`, `\/`,
+	" ", `\ `, "\t", "\\\t", "\n", "\\\n", "\r", "\\\r",
+)
+
+// Predicate renders a predicate as a field query.
+func (lucene) Predicate(p FieldPredicate) (string, error) {
+	field := luceneEscaper.Replace(p.Field)
+	switch {
+	case p.Reference:
+		return "", unsupported("field references")
+	case p.Operator == "exists" && p.Value == "true":
+		return "_exists_:" + field, nil
+	case p.Operator == "exists":
+		return "NOT _exists_:" + field, nil
+	case p.Operator == "re":
+		pattern, caseInsensitive, err := anchoredRegexp(p.Value)
+		if err != nil {
+			return "", err
+		}
+		if caseInsensitive {
+			return "", unsupported("case-insensitive regular expressions")
+		}
+		return field + ":/" + strings.ReplaceAll(pattern, "/", `\/`) + "/", nil
+	case p.Operator == "cidr":
+		return field + `:"` + p.Value + `"`, nil
+	case p.Numeric:
+		return field + ":" + p.Value, nil
+	case comparisonOperators[p.Operator] != "":
+		return field + ":" + comparisonOperators[p.Operator] + p.Value, nil
+	}
+
+	value := renderGlob(parseGlob(p.Pattern()), luceneEscaper.Replace, "*", "?")
+	if value == "" {
+		value = `""`
+	}
+	return field + ":" + value, nil
+}
+
+// And renders operands that all have to match.
+func (lucene) And(operands []string) string {
+	return strings.Join(operands, " AND ")
+}
+
+// Or renders operands of which any has to match.
+func (lucene) Or(operands []string) string {
+	return strings.Join(operands, " OR ")
+}
+
+// Not renders an operand that must not match.
+func (lucene) Not(operand string) string {
+	return "NOT " + operand
+}
+
+// Query renders the expression, the indexes are selected by the search request rather than the query string.
+func (lucene) Query(indexes []string, expression string) string {
+	if expression == "" {
+		return "*"
+	}
+	return expression
+}
+
+// kibana renders queries in the Kibana Query Language.
+// Values are compared as keywords, whether that's case-insensitive depends on the normalizer of the field mapping.
+// KQL has no single-character wildcard, so it matches any sequence of characters, and no regular expressions.
+type kibana struct{}
+
+// kibanaEscaper escapes the special characters of unquoted values.
+var kibanaEscaper = strings.NewReplacer(
+	`\`, `\\`, `(`, `\(`, `)`, `\)`, `:`, `\:`, `<`, `\<`, `>`, `\>`, `"`, `\"`, `*`, `\*`, `{`, `\{`, `}`, `\}`,
+	"\t", `\t`, "\n", `\n`, "\r", `\r`,
+)
+
+// kibanaQuoter escapes the special characters of quoted values.
+var kibanaQuoter = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// kibanaKeyword matches the words that are operators of unquoted values.
+var kibanaKeyword = regexp.MustCompile(`(?i)\b(and|or|not)\b`)
+
+// escape escapes unquoted text, including the words that would be operators.
+func (kibana) escape(text string) string {
+	return kibanaKeyword.ReplaceAllString(kibanaEscaper.Replace(text), `\$1`)
+}
+
+// Predicate renders a predicate as a field query, values without wildcards are quoted.
+func (k kibana) Predicate(p FieldPredicate) (string, error) {
+	field := kibanaEscaper.Replace(p.Field)
+	switch {
+	case p.Reference:
+		return "", unsupported("field references")
+	case p.Operator == "re":
+		return "", unsupported("regular expressions")
+	case p.Operator == "exists" && p.Value == "true":
+		return field + ":*", nil
+	case p.Operator == "exists":
+		return "not " + field + ":*", nil
+	case p.Operator == "cidr":
+		return field + `:"` + p.Value + `"`, nil
+	case p.Numeric:
+		return field + ":" + p.Value, nil
+	case comparisonOperators[p.Operator] != "":
+		return field + " " + comparisonOperators[p.Operator] + " " + p.Value, nil
+	}
+
+	parts := parseGlob(p.Pattern())
+	if literal, ok := globLiteral(parts); ok {
+		return field + `:"` + kibanaQuoter.Replace(literal) + `"`, nil
+	}
+	return field + ":" + renderGlob(parts, k.escape, "*", "*"), nil
+}
+
+// And renders operands that all have to match.
+func (kibana) And(operands []string) string {
+	return strings.Join(operands, " and ")
+}
+
+// Or renders operands of which any has to match.
+func (kibana) Or(operands []string) string {
+	return strings.Join(operands, " or ")
+}
+
+// Not renders an operand that must not match.
+func (kibana) Not(operand string) string {
+	return "not " + operand
+}
+
+// Query renders the expression, the indexes are selected by the data view rather than the query.
+func (kibana) Query(indexes []string, expression string) string {
+	return expression
+}
+
+// eql renders queries in the Event Query Language of Elasticsearch, as a search for events of any category.
+// The : and like~ operators compare strings case-insensitively, == and like case-sensitively.
+type eql struct{}
+
+// eqlEscaper escapes the double-quoted strings of EQL and ES|QL.
+var eqlEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+
+// eqlPlainField matches the field names that don't have to be quoted in EQL.
+var eqlPlainField = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+// field renders a field name, quoted in backticks if needed.
+func (eql) field(name string) string {
+	if eqlPlainField.MatchString(name) {
+		return name
+	}
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// Predicate renders a predicate as a comparison or a function call.
+func (e eql) Predicate(p FieldPredicate) (string, error) {
+	field := e.field(p.Field)
+	insensitive := "~"
+	if p.CaseSensitive {
+		insensitive = ""
+	}
+
+	if p.Reference {
+		reference := e.field(p.Value)
+		switch p.Operator {
+		case "equal":
+			if p.CaseSensitive {
+				return field + " == " + reference, nil
+			}
+			// There is no case-insensitive equality of fields, but a value that starts with another one of the same length equals it
+			return "(startsWith~(" + field + ", " + reference + ") and length(" + field + ") == length(" + reference + "))", nil
+		case "contains":
+			return "stringContains" + insensitive + "(" + field + ", " + reference + ")", nil
+		case "startswith":
+			return "startsWith" + insensitive + "(" + field + ", " + reference + ")", nil
+		case "endswith":
+			return "endsWith" + insensitive + "(" + field + ", " + reference + ")", nil
+		}
+		return "", unsupported(fmt.Sprintf("field references compared with %s", p.Operator))
+	}
+
+	switch {
+	case p.Operator == "exists" && p.Value == "true":
+		return field + " != null", nil
+	case p.Operator == "exists":
+		return field + " == null", nil
+	case p.Operator == "re":
+		pattern, caseInsensitive, err := anchoredRegexp(p.Value)
+		if err != nil {
+			return "", err
+		}
+		operator := "regex"
+		if caseInsensitive {
+			operator = "regex~"
+		}
+		return field + " " + operator + ` "` + eqlEscaper.Replace(pattern) + `"`, nil
+	case p.Operator == "cidr":
+		return "cidrMatch(" + field + `, "` + eqlEscaper.Replace(p.Value) + `")`, nil
+	case p.Numeric:
+		return field + " == " + p.Value, nil
+	case comparisonOperators[p.Operator] != "":
+		return field + " " + comparisonOperators[p.Operator] + " " + p.Value, nil
+	}
+
+	parts := parseGlob(p.Pattern())
+	for _, part := range parts {
+		// The wildcards of EQL can't be escaped, so patterns with a literal * or ? are turned into regular expressions
+		if strings.ContainsAny(part.literal, "*?") {
+			return field + " regex" + insensitive + ` "` + eqlEscaper.Replace(globLuceneRegexp(parts)) + `"`, nil
+		}
+	}
+	if literal, ok := globLiteral(parts); ok {
+		if p.CaseSensitive {
+			return field + ` == "` + eqlEscaper.Replace(literal) + `"`, nil
+		}
+		return field + ` : "` + eqlEscaper.Replace(literal) + `"`, nil
+	}
+	return field + " like" + insensitive + ` "` + renderGlob(parts, eqlEscaper.Replace, "*", "?") + `"`, nil
+}
+
+// And renders operands that all have to match.
+func (eql) And(operands []string) string {
+	return strings.Join(operands, " and ")
+}
+
+// Or renders operands of which any has to match.
+func (eql) Or(operands []string) string {
+	return strings.Join(operands, " or ")
+}
+
+// Not renders an operand that must not match.
+func (eql) Not(operand string) string {
+	return "not " + operand
+}
+
+// Query renders a search for events of any category, the indexes are selected by the search request rather than the query.
+func (eql) Query(indexes []string, expression string) string {
+	if expression == "" {
+		return "any where true"
+	}
+	return "any where " + expression
+}
+
+// esql renders queries in the Elasticsearch Query Language.
+// String comparisons are case-sensitive, so the fields of case-insensitive ones are converted to lower case.
+type esql struct{}
+
+// esqlLikeEscaper escapes the wildcards of LIKE patterns.
+var esqlLikeEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`)
+
+// esqlPlainField matches the field names that don't have to be quoted in ES|QL.
+var esqlPlainField = regexp.MustCompile(`^[A-Za-z_@][A-Za-z0-9_.@]*$`)
+
+// field renders a field name, quoted in backticks if needed.
+func (esql) field(name string) string {
+	if esqlPlainField.MatchString(name) {
+		return name
+	}
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// Predicate renders a predicate as a comparison or a function call.
+func (e esql) Predicate(p FieldPredicate) (string, error) {
+	field := e.field(p.Field)
+	lower := func(text string) string { return text }
+	if !p.CaseSensitive {
+		lower = strings.ToLower
+	}
+
+	if p.Reference {
+		reference := e.field(p.Value)
+		if !p.CaseSensitive {
+			field, reference = "TO_LOWER("+field+")", "TO_LOWER("+reference+")"
+		}
+		switch p.Operator {
+		case "equal":
+			return field + " == " + reference, nil
+		case "contains":
+			return "LOCATE(" + field + ", " + reference + ") > 0", nil
+		case "startswith":
+			return "STARTS_WITH(" + field + ", " + reference + ")", nil
+		case "endswith":
+			return "ENDS_WITH(" + field + ", " + reference + ")", nil
+		}
+		return "", unsupported(fmt.Sprintf("field references compared with %s", p.Operator))
+	}
+
+	switch {
+	case p.Operator == "exists" && p.Value == "true":
+		return field + " IS NOT NULL", nil
+	case p.Operator == "exists":
+		return field + " IS NULL", nil
+	case p.Operator == "re":
+		pattern, caseInsensitive, err := anchoredRegexp(p.Value)
+		if err != nil {
+			return "", err
+		}
+		if caseInsensitive {
+			return "", unsupported("case-insensitive regular expressions")
+		}
+		return field + ` RLIKE "` + eqlEscaper.Replace(pattern) + `"`, nil
+	case p.Operator == "cidr":
+		return "CIDR_MATCH(" + field + `, "` + eqlEscaper.Replace(p.Value) + `")`, nil
+	case p.Numeric:
+		return field + " == " + p.Value, nil
+	case comparisonOperators[p.Operator] != "":
+		return field + " " + comparisonOperators[p.Operator] + " " + p.Value, nil
+	}
+
+	if !p.CaseSensitive {
+		field = "TO_LOWER(" + field + ")"
+	}
+	parts := parseGlob(p.Pattern())
+	if literal, ok := globLiteral(parts); ok {
+		return field + ` == "` + eqlEscaper.Replace(lower(literal)) + `"`, nil
+	}
+	escape := func(text string) string { return eqlEscaper.Replace(esqlLikeEscaper.Replace(lower(text))) }
+	return field + ` LIKE "` + renderGlob(parts, escape, "*", "?") + `"`, nil
+}
+
+// And renders operands that all have to match.
+func (esql) And(operands []string) string {
+	return strings.Join(operands, " AND ")
+}
+
+// Or renders operands of which any has to match.
+func (esql) Or(operands []string) string {
+	return strings.Join(operands, " OR ")
+}
+
+// Not renders an operand that must not match.
+func (esql) Not(operand string) string {
+	return "NOT " + operand
+}
+
+// Query renders a search of the indexes, or of all indexes if the rule has none.
+func (esql) Query(indexes []string, expression string) string {
+	query := "FROM *"
+	if len(indexes) > 0 {
+		query = "FROM " + strings.Join(indexes, ", ")
+	}
+	if expression == "" {
+		return query
+	}
+	return query + " | WHERE " + expression
+}
+
+// kusto renders queries in the Kusto Query Language of Microsoft Sentinel and Defender.
+// The string operators are case-insensitive, their _cs variants case-sensitive.
+type kusto struct{}
+
+// kustoEscaper escapes the double-quoted strings of Kusto.
+var kustoEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+
+// kustoPlainField matches the column names that don't have to be quoted.
+var kustoPlainField = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// field renders a column name, quoted in brackets if needed.
+func (kusto) field(name string) string {
+	if kustoPlainField.MatchString(name) {
+		return name
+	}
+	return "['" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(name) + "']"
+}
+
+// quote renders a string literal.
+func (kusto) quote(text string) string {
+	return `"` + kustoEscaper.Replace(text) + `"`
+}
+
+// Predicate renders a predicate as a string operator or a function call.
+func (k kusto) Predicate(p FieldPredicate) (string, error) {
+	field := k.field(p.Field)
+	suffix := ""
+	if p.CaseSensitive {
+		suffix = "_cs"
+	}
+	equal := "=~"
+	if p.CaseSensitive {
+		equal = "=="
+	}
+
+	if p.Reference {
+		reference := k.field(p.Value)
+		switch p.Operator {
+		case "equal":
+			return field + " " + equal + " " + reference, nil
+		case "contains", "startswith", "endswith":
+			return field + " " + p.Operator + suffix + " " + reference, nil
+		}
+		return "", unsupported(fmt.Sprintf("field references compared with %s", p.Operator))
+	}
+
+	switch {
+	case p.Operator == "exists" && p.Value == "true":
+		return "isnotempty(" + field + ")", nil
+	case p.Operator == "exists":
+		return "isempty(" + field + ")", nil
+	case p.Operator == "re":
+		// Kusto uses the RE2 syntax, which matches anywhere in the value and supports the flags of Sigma regular expressions
+		return field + " matches regex " + k.quote(p.Value), nil
+	case p.Operator == "cidr" && strings.Contains(p.Value, ":"):
+		return "ipv6_is_in_range(" + field + ", " + k.quote(p.Value) + ")", nil
+	case p.Operator == "cidr":
+		return "ipv4_is_in_range(" + field + ", " + k.quote(p.Value) + ")", nil
+	case p.Numeric:
+		return field + " == " + p.Value, nil
+	case comparisonOperators[p.Operator] != "":
+		return field + " " + comparisonOperators[p.Operator] + " " + p.Value, nil
+	}
+
+	parts := parseGlob(p.Pattern())
+	if literal, ok := globLiteral(parts); ok {
+		return field + " " + equal + " " + k.quote(literal), nil
+	}
+	// Patterns whose wildcards are all at the ends use the string operators, others regular expressions
+	inner, leading, trailing := trimWildcards(parts)
+	if literal, ok := globLiteral(inner); ok {
+		switch {
+		case leading && trailing:
+			return field + " contains" + suffix + " " + k.quote(literal), nil
+		case leading:
+			return field + " endswith" + suffix + " " + k.quote(literal), nil
+		default:
+			return field + " startswith" + suffix + " " + k.quote(literal), nil
+		}
+	}
+	return field + " matches regex " + k.quote(globRegexp(parts, p.CaseSensitive)), nil
+}
+
+// And renders operands that all have to match.
+func (kusto) And(operands []string) string {
+	return strings.Join(operands, " and ")
+}
+
+// Or renders operands of which any has to match.
+func (kusto) Or(operands []string) string {
+	return strings.Join(operands, " or ")
+}
+
+// Not renders an operand that must not match, not is a function in Kusto.
+func (kusto) Not(operand string) string {
+	if strings.HasPrefix(operand, "(") {
+		return "not" + operand
+	}
+	return "not(" + operand + ")"
+}
+
+// Query renders a search of the tables named by the indexes, or of all tables if the rule has none.
+// Indexes with wildcards are patterns of table names, which only a union can search.
+func (k kusto) Query(indexes []string, expression string) string {
+	tables := make([]string, len(indexes))
+	wildcards := false
+	for i, index := range indexes {
+		if strings.Contains(index, "*") {
+			tables[i], wildcards = index, true
+		} else {
+			tables[i] = k.field(index)
+		}
+	}
+
+	query := "union *"
+	if len(tables) == 1 && !wildcards {
+		query = tables[0]
+	} else if len(tables) > 0 {
+		query = "union " + strings.Join(tables, ", ")
+	}
+	if expression == "" {
+		return query
+	}
+	return query + " | where " + expression
+}
+
+// aql renders queries in the Ariel Query Language of IBM QRadar, as a search of the events table.
+// The = and LIKE operators compare strings case-sensitively, ILIKE case-insensitively.
+type aql struct{}
+
+// aqlEscaper escapes the single-quoted strings of AQL.
+var aqlEscaper = strings.NewReplacer(`\`, `\\`, "'", `\'`)
+
+// field renders a field name in double quotes.
+func (aql) field(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, "") + `"`
+}
+
+// quote renders a string literal.
+func (aql) quote(text string) string {
+	return "'" + aqlEscaper.Replace(text) + "'"
+}
+
+// Predicate renders a predicate as a comparison or a function call.
+func (a aql) Predicate(p FieldPredicate) (string, error) {
+	field := a.field(p.Field)
+	if p.Reference {
+		if p.Operator != "equal" {
+			return "", unsupported(fmt.Sprintf("field references compared with %s", p.Operator))
+		}
+		if p.CaseSensitive {
+			return field + " = " + a.field(p.Value), nil
+		}
+		return "LOWER(" + field + ") = LOWER(" + a.field(p.Value) + ")", nil
+	}
+
+	switch {
+	case p.Operator == "exists" && p.Value == "true":
+		return field + " IS NOT NULL", nil
+	case p.Operator == "exists":
+		return field + " IS NULL", nil
+	case p.Operator == "re":
+		return field + " MATCHES " + a.quote(p.Value), nil
+	case p.Operator == "cidr":
+		return "INCIDR(" + a.quote(p.Value) + ", " + field + ")", nil
+	case p.Numeric:
+		return field + " = " + p.Value, nil
+	case comparisonOperators[p.Operator] != "":
+		return field + " " + comparisonOperators[p.Operator] + " " + p.Value, nil
+	}
+
+	like := "ILIKE"
+	if p.CaseSensitive {
+		like = "LIKE"
+	}
+	parts := parseGlob(p.Pattern())
+	for _, part := range parts {
+		// The wildcards of LIKE can't be escaped, so patterns with a literal % or _ are turned into regular expressions
+		if strings.ContainsAny(part.literal, "%_") {
+			return field + " MATCHES " + a.quote(globRegexp(parts, p.CaseSensitive)), nil
+		}
+	}
+	if literal, ok := globLiteral(parts); ok && p.CaseSensitive {
+		return field + " = " + a.quote(literal), nil
+	}
+	return field + " " + like + " " + a.quote(renderGlob(parts, func(text string) string { return text }, "%", "_")), nil
+}
+
+// And renders operands that all have to match.
+func (aql) And(operands []string) string {
+	return strings.Join(operands, " AND ")
+}
+
+// Or renders operands of which any has to match.
+func (aql) Or(operands []string) string {
+	return strings.Join(operands, " OR ")
+}
+
+// Not renders an operand that must not match.
+func (aql) Not(operand string) string {
+	return "NOT " + operand
+}
+
+// Query renders a search of the events table, the indexes don't apply to QRadar.
+func (aql) Query(indexes []string, expression string) string {
+	if expression == "" {
+		return "SELECT * FROM events"
+	}
+	return "SELECT * FROM events WHERE " + expression
+}
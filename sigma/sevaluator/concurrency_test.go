@@ -0,0 +1,63 @@
+package sevaluator_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/mtnmunuklu/logen/sigma"
+	"github.com/mtnmunuklu/logen/sigma/sevaluator"
+	"github.com/mtnmunuklu/logen/sigma/sevaluator/modifiers"
+)
+
+// TestRuleEvaluator_Concurrent evaluates rules in parallel goroutines, like services that call Alters for many rules at once.
+// Run it with the race detector (go test -race) to check that the evaluators and the generators they share are safe for concurrent use.
+func TestRuleEvaluator_Concurrent(t *testing.T) {
+	config, err := sigma.ParseConfig([]byte(testConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var evaluators []*sevaluator.RuleEvaluator
+	seeded := modifiers.NewSeededSyntheticDataGenerator(42)
+	for _, ruleText := range []string{testRule, generateTestRule, numericTestRule, fmt.Sprintf(aggregateTestRule, "count(CommandLine) by User > 3"), nearTestRule, goldenTestRule} {
+		rule, err := sigma.ParseRule([]byte(ruleText))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Evaluators share the global generator, or a seeded one
+		evaluators = append(evaluators,
+			sevaluator.ForRule(rule, sevaluator.WithConfig(config), sevaluator.NegativeSamples),
+			sevaluator.ForRule(rule, sevaluator.WithConfig(config), sevaluator.NegativeSamples, sevaluator.WithGenerator(seeded)),
+		)
+	}
+
+	// Every evaluator is also called from several goroutines at once
+	var wg sync.WaitGroup
+	errs := make(chan error, 4*len(evaluators))
+	for i := 0; i < 4; i++ {
+		for _, evaluator := range evaluators {
+			wg.Add(1)
+			go func(evaluator *sevaluator.RuleEvaluator) {
+				defer wg.Done()
+				result, err := evaluator.Alters(context.Background())
+				if err != nil {
+					errs <- err
+					return
+				}
+				for conditionIndex, verification := range result.Verifications {
+					if !verification.Passed() {
+						errs <- fmt.Errorf("rule %s condition %d failed verification: %+v", evaluator.Title, conditionIndex, verification)
+					}
+				}
+			}(evaluator)
+		}
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,314 @@
+package sevaluator
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mtnmunuklu/logen/sigma"
+)
+
+// CorrelationEvaluator generates and matches the event sequences that trigger a Sigma correlation rule.
+// It holds a RuleEvaluator for each rule referenced by the correlation, in the order they are referenced.
+type CorrelationEvaluator struct {
+	sigma.CorrelationRule
+	rules []*RuleEvaluator // The evaluators of the referenced rules
+}
+
+// ForCorrelation constructs a new CorrelationEvaluator for a correlation rule, resolving the rules it references among the given ones.
+// The options are applied to the RuleEvaluator of every referenced rule.
+func ForCorrelation(correlation sigma.CorrelationRule, rules []sigma.Rule, options ...Option) (*CorrelationEvaluator, error) {
+	referenced, err := correlation.Resolve(rules)
+	if err != nil {
+		return nil, err
+	}
+	if len(referenced) == 0 {
+		return nil, fmt.Errorf("correlation %s doesn't reference any rules", correlation.Title)
+	}
+
+	// Check that the correlation has what its type needs
+	switch correlation.Correlation.Type {
+	case sigma.EventCount, sigma.ValueCount:
+		if correlation.Correlation.Condition == nil || len(correlation.Correlation.Condition.Thresholds) == 0 {
+			return nil, fmt.Errorf("%s correlation %s needs a condition", correlation.Correlation.Type, correlation.Title)
+		}
+		if correlation.Correlation.Type == sigma.ValueCount && correlation.Correlation.Condition.Field == "" {
+			return nil, fmt.Errorf("value_count correlation %s needs a condition field", correlation.Title)
+		}
+	case sigma.Temporal, sigma.TemporalOrdered:
+	default:
+		return nil, fmt.Errorf("unsupported correlation type %s", correlation.Correlation.Type)
+	}
+
+	e := &CorrelationEvaluator{CorrelationRule: correlation}
+	for _, rule := range referenced {
+		e.rules = append(e.rules, ForRule(rule, options...))
+	}
+	return e, nil
+}
+
+// Rules returns the evaluators of the rules referenced by the correlation, in the order they are referenced.
+func (c CorrelationEvaluator) Rules() []*RuleEvaluator {
+	return c.rules
+}
+
+// CorrelatedEvent is a synthetic event that is part of the sequence of a correlation.
+type CorrelatedEvent struct {
+	Event
+	Rule int // The index of the referenced rule that the event matches
+}
+
+// CorrelationResult represents the evaluation result of a Sigma correlation rule.
+type CorrelationResult struct {
+	Events      []CorrelatedEvent // The sequence of synthetic events that triggers the correlation, in the order they are logged
+	Queries     []string          // The query of the first condition of each referenced rule
+	SourceTypes []string          // The source type of each referenced rule
+	Verified    bool              // Whether the events were matched back against the correlation and trigger it
+}
+
+// timespan returns the time window of the correlation, or DefaultTimeframe if the correlation doesn't declare one.
+func (c CorrelationEvaluator) timespan() time.Duration {
+	if c.Correlation.Timespan > 0 {
+		return time.Duration(c.Correlation.Timespan)
+	}
+	return DefaultTimeframe
+}
+
+// Alters generates a sequence of synthetic events that triggers the correlation within its timespan.
+// Every event matches the first condition of one of the referenced rules, and all the events share the values of the group-by fields.
+// Event and value counts get the smallest number of events that satisfies the condition, spread over the rules in turn,
+// temporal correlations get an event for each rule, in the order the rules are referenced.
+// The sequence is matched back against the correlation and the outcome is stored in the Verified field.
+func (c CorrelationEvaluator) Alters(ctx context.Context) (CorrelationResult, error) {
+	result := CorrelationResult{}
+
+	// Every rule contributes events that match its first condition
+	builders := make([]*eventBuilder, len(c.rules))
+	for i, rule := range c.rules {
+		if len(rule.Detection.Conditions) == 0 {
+			return CorrelationResult{}, fmt.Errorf("rule %s has no condition", rule.Title)
+		}
+
+		// Only the first condition is compiled and rendered, the other conditions of the rule don't take part in the correlation
+		node, err := rule.compileSearchExpression(ctx, rule.Detection.Conditions[0].Search, nil)
+		if err != nil {
+			return CorrelationResult{}, fmt.Errorf("error evaluating rule %s: %w", rule.Title, err)
+		}
+		builders[i], err = rule.constrainQuery(ctx, node)
+		if err != nil {
+			return CorrelationResult{}, fmt.Errorf("error generating events for rule %s: %w", rule.Title, err)
+		}
+
+		logsource, err := rule.compileLogsource(ctx)
+		if err != nil {
+			return CorrelationResult{}, fmt.Errorf("error evaluating rule %s: %w", rule.Title, err)
+		}
+		query, err := rule.renderQuery(logsource, node)
+		if err != nil {
+			return CorrelationResult{}, fmt.Errorf("error rendering rule %s: %w", rule.Title, err)
+		}
+		result.Queries = append(result.Queries, query)
+		result.SourceTypes = append(result.SourceTypes, rule.sourceType())
+	}
+
+	// Decide which rule each event of the sequence matches
+	var sequence []int
+	switch c.Correlation.Type {
+	case sigma.EventCount, sigma.ValueCount:
+		count, err := c.correlationCount()
+		if err != nil {
+			return CorrelationResult{}, err
+		}
+		for i := 0; i < count; i++ {
+			sequence = append(sequence, i%len(c.rules))
+		}
+	default:
+		for i := range c.rules {
+			sequence = append(sequence, i)
+		}
+	}
+
+	events := make([]Event, len(sequence))
+	for i, ruleIndex := range sequence {
+		var err error
+		if events[i], err = builders[ruleIndex].build(); err != nil {
+			return CorrelationResult{}, err
+		}
+	}
+	c.shareGroupValues(sequence, events)
+
+	// Value counts need a different value of the counted field in every event
+	if c.Correlation.Type == sigma.ValueCount {
+		seen := map[string]bool{}
+		for i, ruleIndex := range sequence {
+			field := c.eventField(c.Correlation.Condition.Field, ruleIndex)
+			value, ok := events[i].Fields[field]
+			for attempt := 0; !ok || seen[fmt.Sprint(value)]; attempt++ {
+				if attempt == distinctAttempts {
+					return CorrelationResult{}, fmt.Errorf("unable to generate %d distinct values of %s", len(sequence), c.Correlation.Condition.Field)
+				}
+				var err error
+				if value, err = builders[ruleIndex].synthesize(builders[ruleIndex].constraints[field]...); err != nil {
+					return CorrelationResult{}, err
+				}
+				ok = true
+			}
+			events[i].Fields[field] = value
+			seen[fmt.Sprint(value)] = true
+		}
+	}
+
+	// The events are logged one after another within the timespan of the correlation
+	timeline := c.rules[0].timeline
+	interArrival, err := timeline.interArrival()
+	if err != nil {
+		return CorrelationResult{}, err
+	}
+	timeline.schedule(c.rules[0].generator.Rand(), interArrival, timeline.start(), events, c.timespan())
+
+	for i, ruleIndex := range sequence {
+		result.Events = append(result.Events, CorrelatedEvent{Event: events[i], Rule: ruleIndex})
+	}
+
+	result.Verified, err = c.Matches(ctx, events)
+	if err != nil {
+		return CorrelationResult{}, fmt.Errorf("error verifying events: %w", err)
+	}
+	return result, nil
+}
+
+// correlationCount returns the smallest number of events, or distinct values, that satisfies the condition of the correlation.
+func (c CorrelationEvaluator) correlationCount() (int, error) {
+	for count := 1; count <= MaxAggregationEvents; count++ {
+		if c.Correlation.Condition.Matches(float64(count)) {
+			return count, nil
+		}
+	}
+	return 0, fmt.Errorf("no number of events up to the maximum of %d satisfies the condition of correlation %s", MaxAggregationEvents, c.Title)
+}
+
+// shareGroupValues gives every event of a sequence the same value for each group-by field.
+// The value of the first event that has the field is used, or a random one if none of them has it.
+func (c CorrelationEvaluator) shareGroupValues(sequence []int, events []Event) {
+	for _, groupBy := range c.Correlation.GroupBy {
+		var value interface{}
+		found := false
+		for i, ruleIndex := range sequence {
+			if value, found = events[i].Fields[c.eventField(groupBy, ruleIndex)]; found {
+				break
+			}
+		}
+		if !found {
+			// Unconstrained values can always be generated
+			value, _ = c.rules[0].generator.GenerateConstrainedValue(nil)
+		}
+
+		for i, ruleIndex := range sequence {
+			events[i].Fields[c.eventField(groupBy, ruleIndex)] = value
+		}
+	}
+}
+
+// eventField returns the name of the event field that a correlation field is written to in the events of a referenced rule.
+// Aliases of the correlation are resolved first, then the field mappings of the rule.
+func (c CorrelationEvaluator) eventField(field string, ruleIndex int) string {
+	rule := c.rules[ruleIndex]
+	return rule.eventField(c.Field(field, rule.Rule))
+}
+
+// correlatedMatch is an event that matches one of the rules referenced by a correlation.
+type correlatedMatch struct {
+	event int    // The index of the event
+	rule  int    // The index of the referenced rule that the event matches
+	group string // The values of the group-by fields of the event
+}
+
+// Matches reports whether events trigger the correlation.
+// Events that match any condition of a referenced rule are grouped by the values of the group-by fields,
+// and the correlation is evaluated over every window of its timespan, using the Timestamp of the events.
+func (c CorrelationEvaluator) Matches(ctx context.Context, events []Event) (bool, error) {
+	var matches []correlatedMatch
+	for i, event := range events {
+		for ruleIndex, rule := range c.rules {
+			result, err := rule.Matches(ctx, event)
+			if err != nil {
+				return false, err
+			}
+			if !result.Match {
+				continue
+			}
+
+			values := make([]string, len(c.Correlation.GroupBy))
+			for j, groupBy := range c.Correlation.GroupBy {
+				values[j] = fmt.Sprint(event.Fields[c.eventField(groupBy, ruleIndex)])
+			}
+			matches = append(matches, correlatedMatch{event: i, rule: ruleIndex, group: strings.Join(values, "\x00")})
+		}
+	}
+
+	groups := map[string][]correlatedMatch{}
+	for _, match := range matches {
+		groups[match.group] = append(groups[match.group], match)
+	}
+
+	timespan := c.timespan()
+	for _, group := range groups {
+		sort.SliceStable(group, func(i, j int) bool {
+			return events[group[i].event].Timestamp.Before(events[group[j].event].Timestamp)
+		})
+
+		// Every match starts a window that holds the matches logged within the timespan after it
+		for start := range group {
+			end := start
+			for end < len(group) && events[group[end].event].Timestamp.Sub(events[group[start].event].Timestamp) < timespan {
+				end++
+			}
+			if c.matchWindow(events, group[start:end]) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// matchWindow reports whether the matches within a window of the timespan trigger the correlation.
+func (c CorrelationEvaluator) matchWindow(events []Event, window []correlatedMatch) bool {
+	switch c.Correlation.Type {
+	case sigma.EventCount:
+		// An event that matches several rules is only counted once
+		distinct := map[int]bool{}
+		for _, match := range window {
+			distinct[match.event] = true
+		}
+		return c.Correlation.Condition.Matches(float64(len(distinct)))
+
+	case sigma.ValueCount:
+		distinct := map[string]bool{}
+		for _, match := range window {
+			if value, ok := events[match.event].Fields[c.eventField(c.Correlation.Condition.Field, match.rule)]; ok && value != nil {
+				distinct[fmt.Sprint(value)] = true
+			}
+		}
+		return c.Correlation.Condition.Matches(float64(len(distinct)))
+
+	case sigma.Temporal:
+		seen := map[int]bool{}
+		for _, match := range window {
+			seen[match.rule] = true
+		}
+		return len(seen) == len(c.rules)
+
+	case sigma.TemporalOrdered:
+		// The rules have to match in order, other events may be logged in between
+		next := 0
+		for _, match := range window {
+			if next < len(c.rules) && match.rule == next {
+				next++
+			}
+		}
+		return next == len(c.rules)
+	}
+	return false
+}
@@ -0,0 +1,190 @@
+package sevaluator_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mtnmunuklu/logen/sigma"
+	"github.com/mtnmunuklu/logen/sigma/sevaluator"
+)
+
+const correlateTestRuleSet = `
+title: Recon
+name: recon
+logsource:
+  category: process_creation
+  product: windows
+detection:
+  selection:
+    Image|endswith: '\net.exe'
+  condition: selection
+---
+title: Discovery
+name: discovery
+logsource:
+  category: process_creation
+  product: windows
+detection:
+  selection:
+    Image|endswith: '\whoami.exe'
+  condition: selection
+---
+title: Correlation Test
+correlation:
+  type: %s
+  rules:
+    - recon
+    - discovery
+  group-by:
+    - user
+  timespan: 5m
+  aliases:
+    user:
+      recon: User
+      discovery: ParentUser
+%s
+`
+
+// TestCorrelationEvaluator checks that correlations get a sequence of events that triggers them within their timespan.
+func TestCorrelationEvaluator(t *testing.T) {
+	tt := []struct {
+		Type      sigma.CorrelationType
+		Condition string
+		Events    int
+	}{
+		{sigma.EventCount, "  condition:\n    gte: 5", 5},
+		{sigma.ValueCount, "  condition:\n    gt: 2\n    field: CommandLine", 3},
+		{sigma.Temporal, "", 2},
+		{sigma.TemporalOrdered, "", 2},
+	}
+
+	for _, tc := range tt {
+		t.Run(string(tc.Type), func(t *testing.T) {
+			set, err := sigma.ParseRuleSet([]byte(fmt.Sprintf(correlateTestRuleSet, tc.Type, tc.Condition)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			evaluator, err := sevaluator.ForCorrelation(set.Correlations[0], set.Rules)
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := evaluator.Alters(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(result.Events) != tc.Events {
+				t.Fatalf("expected %d events, got %d: %v", tc.Events, len(result.Events), result.Events)
+			}
+			if !result.Verified {
+				t.Errorf("expected the events to trigger the correlation")
+			}
+
+			// The group-by field is aliased to a different event field for each rule
+			userFields := []string{"User", "ParentUser"}
+			first := result.Events[0]
+			for i, event := range result.Events {
+				if offset := event.Timestamp.Sub(first.Timestamp); offset < 0 || offset >= 5*time.Minute {
+					t.Errorf("event %d logged outside the timespan at %v", i, offset)
+				}
+				if event.Rule != i%2 {
+					t.Errorf("expected event %d to match rule %d, got %d", i, i%2, event.Rule)
+				}
+				if event.Fields[userFields[event.Rule]] != first.Fields[userFields[first.Rule]] {
+					t.Errorf("expected event %d to share the group-by value, got %v", i, event.Fields)
+				}
+			}
+
+			// Spreading the events over a longer time than the timespan must not trigger the correlation
+			late := make([]sevaluator.Event, len(result.Events))
+			for i, event := range result.Events {
+				late[i] = sevaluator.Event{Fields: event.Fields, Timestamp: first.Timestamp.Add(time.Duration(i) * 10 * time.Minute)}
+			}
+			if match, err := evaluator.Matches(context.Background(), late); err != nil || match {
+				t.Errorf("expected events outside the timespan not to trigger the correlation, got %v (%v)", match, err)
+			}
+		})
+	}
+}
+
+// TestCorrelationEvaluator_Ordered checks that temporal_ordered correlations only match events of their rules in order.
+func TestCorrelationEvaluator_Ordered(t *testing.T) {
+	set, err := sigma.ParseRuleSet([]byte(fmt.Sprintf(correlateTestRuleSet, sigma.TemporalOrdered, "")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	evaluator, err := sevaluator.ForCorrelation(set.Correlations[0], set.Rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := evaluator.Alters(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events := []sevaluator.Event{result.Events[0].Event, result.Events[1].Event}
+	reversed := []sevaluator.Event{
+		{Fields: events[0].Fields, Timestamp: events[1].Timestamp},
+		{Fields: events[1].Fields, Timestamp: events[0].Timestamp},
+	}
+	if match, err := evaluator.Matches(context.Background(), events); err != nil || !match {
+		t.Errorf("expected the generated events to match, got %v (%v)", match, err)
+	}
+	if match, err := evaluator.Matches(context.Background(), reversed); err != nil || match {
+		t.Errorf("expected reversed events not to match, got %v (%v)", match, err)
+	}
+
+	// Temporal correlations don't care about the order
+	set.Correlations[0].Correlation.Type = sigma.Temporal
+	unordered, err := sevaluator.ForCorrelation(set.Correlations[0], set.Rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if match, err := unordered.Matches(context.Background(), reversed); err != nil || !match {
+		t.Errorf("expected reversed events to match a temporal correlation, got %v (%v)", match, err)
+	}
+}
+
+// TestForCorrelation_UnknownRule checks that correlations referencing unknown rules are rejected.
+func TestForCorrelation_UnknownRule(t *testing.T) {
+	set, err := sigma.ParseRuleSet([]byte(fmt.Sprintf(correlateTestRuleSet, sigma.Temporal, "")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sevaluator.ForCorrelation(set.Correlations[0], set.Rules[:1]); err == nil {
+		t.Errorf("expected an error for the unknown rule")
+	}
+}
+
+// TestCorrelationEvaluator_FirstCondition checks that only the first condition of the referenced rules takes part in a correlation,
+// so that other conditions that can't be generated don't fail it.
+func TestCorrelationEvaluator_FirstCondition(t *testing.T) {
+	set, err := sigma.ParseRuleSet([]byte(fmt.Sprintf(correlateTestRuleSet, sigma.Temporal, "")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	impossible, err := sigma.ParseCondition("selection | count() < 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	set.Rules[0].Detection.Conditions = append(set.Rules[0].Detection.Conditions, impossible)
+
+	evaluator, err := sevaluator.ForCorrelation(set.Correlations[0], set.Rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := evaluator.Alters(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Queries) != 2 || result.Queries[0] != `image endswith '\net.exe'` || result.SourceTypes[0] != "windows" {
+		t.Errorf("expected the query and sourcetype of the first condition of each rule, got %q and %q", result.Queries, result.SourceTypes)
+	}
+	if !result.Verified {
+		t.Errorf("expected the events to trigger the correlation")
+	}
+}
@@ -3,9 +3,9 @@ package sevaluator
 import (
 	"context"
 	"fmt"
-	"strings"
 
 	"github.com/mtnmunuklu/logen/sigma"
+	"github.com/mtnmunuklu/logen/sigma/sevaluator/modifiers"
 )
 
 // RuleEvaluator represents a rule evaluator that is capable of computing the search, condition, and query results of a Sigma rule.
@@ -16,15 +16,21 @@ type RuleEvaluator struct {
 	indexes         []string            // The list of indexes that this rule should be applied to. Computed from the Logsource field in the rule and any config that's supplied.
 	indexConditions []sigma.Search      // Any field-value conditions that need to match for this rule to apply to events from []indexes
 	fieldmappings   map[string][]string // A compiled mapping from rule fieldnames to possible event fieldnames
+	keywordFields   []string            // The free-text event fields that keywords are searched in. Computed from the config or set with WithKeywordFields.
 
 	expandPlaceholder func(ctx context.Context, placeholderName string) ([]string, error) // A function to expand placeholders in the Sigma rule template
 	caseSensitive     bool
+	negativeSamples   bool                              // Whether near-miss events that must not satisfy the conditions are generated as well
+	timeline          Timeline                          // How the generated events are spread over time
+	generator         *modifiers.SyntheticDataGenerator // The generator that synthetic values and the time between events are drawn from
+	backend           Backend                           // The query language that the queries are rendered in, Sigma-like pseudo-queries if nil
+	maxBranches       int                               // The maximum number of events generated for the branches of a condition, DefaultMaxBranches if zero
 }
 
 // ForRule constructs a new RuleEvaluator with the given Sigma rule and evaluation options.
 // It applies any provided options to the new RuleEvaluator and returns it.
 func ForRule(rule sigma.Rule, options ...Option) *RuleEvaluator {
-	e := &RuleEvaluator{Rule: rule}
+	e := &RuleEvaluator{Rule: rule, generator: modifiers.DefaultGenerator()}
 	for _, option := range options {
 		option(e)
 	}
@@ -34,67 +40,136 @@ func ForRule(rule sigma.Rule, options ...Option) *RuleEvaluator {
 // Result represents the evaluation result of a Sigma rule.
 // It contains the search, condition, aggregation, and query results of the rule evaluation.
 type Result struct {
-	Searches    map[string][]string // The map of search identifiers to their result values
-	Conditions  map[int][]string    // The map of condition indices to their result values
-	SourceTypes map[int]string      // The map of sourcetype indices to their result values
-	Queries     map[int]string      // The map of query indices to their result values
+	// Searches is the map of search identifiers to the pseudo-queries of their operands, which are joined with "and".
+	//
+	// Deprecated: Use SearchTrees, the pseudo-queries are the String of the operands of the trees.
+	Searches map[string][]string
+
+	// Conditions is the map of condition indices to the tokens of their search expressions, i.e. search identifiers, operators and parentheses.
+	//
+	// Deprecated: Use ConditionTrees, which has the trees of the searches in place of their identifiers.
+	Conditions map[int][]string
+
+	SearchTrees    map[string]QueryNode // The map of search identifiers to their query trees
+	ConditionTrees map[int]QueryNode    // The map of condition indices to their query trees, in which the searches are replaced by their trees
+	SourceTypes    map[int]string       // The map of sourcetype indices to their result values
+	Queries        map[int]string       // The map of condition indices to the queries rendered from their trees
+	Events         map[int][]Event      // The map of condition indices to the synthetic events that satisfy them
+	Negatives      map[int][]Event      // The map of condition indices to the near-miss events that must not satisfy them
+
+	Verifications map[int]Verification // The map of condition indices to the outcome of matching their events back against the rule
 }
 
 // This function returns a Result object containing the evaluation results for the rule's Detection field.
-// It uses the evaluateSearch, evaluateSearchExpression and evaluateAggregationExpression functions to compute the results.
+// It compiles the searches and conditions to query trees with compileSearch and compileSearchExpression, renders the queries from them,
+// and uses generateEvents to build synthetic events that satisfy each condition without the need for an external service.
+// Every generated event is matched back against the rule and the outcome is stored in the Verifications map.
 func (rule RuleEvaluator) Alters(ctx context.Context) (Result, error) {
 	result := Result{
-		Searches:    make(map[string][]string),
-		Conditions:  make(map[int][]string),
-		SourceTypes: make(map[int]string),
-		Queries:     make(map[int]string),
+		Searches:       make(map[string][]string),
+		Conditions:     make(map[int][]string),
+		SearchTrees:    make(map[string]QueryNode),
+		ConditionTrees: make(map[int]QueryNode),
+		SourceTypes:    make(map[int]string),
+		Queries:        make(map[int]string),
+		Events:         make(map[int][]Event),
+		Negatives:      make(map[int][]Event),
+
+		Verifications: make(map[int]Verification),
 	}
 
-	// Evaluate all the search expressions in the Detection field and store the results in the SearchResults map of the result object.
-	for identifier, search := range rule.Detection.Searches {
-		var err error
-		result.Searches[identifier], err = rule.evaluateSearch(ctx, search)
+	// Compile all the searches in the Detection field and store their trees in the SearchTrees map of the result object.
+	// Searches are compiled in the order they are declared in, so that a seeded generator produces the same synthetic values every time
+	for _, identifier := range rule.searchNames("*") {
+		node, err := rule.compileSearch(ctx, rule.Detection.Searches[identifier])
 		if err != nil {
 			return Result{}, fmt.Errorf("error evaluating search %s: %w", identifier, err)
 		}
+		if result.SearchTrees[identifier], err = rule.synthesize(node); err != nil {
+			return Result{}, fmt.Errorf("error evaluating search %s: %w", identifier, err)
+		}
+		result.Searches[identifier] = searchQueries(result.SearchTrees[identifier])
 	}
 
-	// Evaluate all the search expressions in the Detection field's Conditions array and store the results in the ConditionResults map of the result object.
-	// If a condition has an Aggregation field, also evaluate it and store the result in the AggregationResults map of the result object.
+	// Compile the search expression of every condition, in which the searches are replaced by their trees, and render the queries from them.
+	// The pseudo-queries are the String of the trees, unless a backend renders them in the language of a SIEM.
+	logsource, err := rule.compileLogsource(ctx)
+	if err != nil {
+		return Result{}, err
+	}
 	for conditionIndex, condition := range rule.Detection.Conditions {
-		result.Conditions[conditionIndex] = rule.evaluateSearchExpression(condition.Search, []string{}, true)
+		result.ConditionTrees[conditionIndex], err = rule.compileSearchExpression(ctx, condition.Search, result.SearchTrees)
+		if err != nil {
+			return Result{}, fmt.Errorf("error evaluating condition %d: %w", conditionIndex, err)
+		}
+		result.Conditions[conditionIndex] = rule.conditionTokens(condition.Search, nil, true)
+
+		if result.Queries[conditionIndex], err = rule.renderQuery(logsource, result.ConditionTrees[conditionIndex]); err != nil {
+			return Result{}, fmt.Errorf("error rendering condition %d: %w", conditionIndex, err)
+		}
+		// The expression of a near condition follows the pseudo-query of its search, like the other operators of the condition.
+		// Query languages of backends have no near operator, so like aggregations it isn't part of their queries.
+		if near, ok := condition.Aggregation.(sigma.Near); ok && rule.backend == nil {
+			if result.Queries[conditionIndex], err = rule.renderNear(ctx, result.Queries[conditionIndex], near, result.SearchTrees); err != nil {
+				return Result{}, fmt.Errorf("error rendering condition %d: %w", conditionIndex, err)
+			}
+		}
+
+		// Add the sourcetype of the condition, if applicable
+		if sourceType := rule.sourceType(); sourceType != "" {
+			result.SourceTypes[conditionIndex] = sourceType
+		}
+	}
+
+	// The events of the conditions are logged one after another along the timeline
+	interArrival, err := rule.timeline.interArrival()
+	if err != nil {
+		return Result{}, err
 	}
+	now := rule.timeline.start()
+
+	// Generate the synthetic events that satisfy each condition and store them in the Events map of the result object.
+	// Conditions with an aggregation get a burst of events that meets its threshold within the timeframe of the rule.
+	for conditionIndex, condition := range rule.Detection.Conditions {
+		result.Events[conditionIndex], err = rule.generateEvents(ctx, condition, result.ConditionTrees[conditionIndex])
+		if err != nil {
+			return Result{}, fmt.Errorf("error generating events for condition %d: %w", conditionIndex, err)
+		}
 
-	// Combine the search results and condition results to form the final query strings for each condition.
-	// The query strings are stored in the QueryResults map of the result object.
-	for i, conditionResult := range result.Conditions {
-		conditionList := make([]string, 0, len(conditionResult))
-		for _, condition := range conditionResult {
-			// If the condition matches any search identifier, replace it with the corresponding search results
-			if value, ok := result.Searches[condition]; ok {
-				if len(conditionResult) > 1 {
-					conditionList = append(conditionList, "("+strings.Join(value, " and ")+")")
-				} else if len(value) > 1 {
-					conditionList = append(conditionList, strings.Join(value, " and "))
-				} else {
-					conditionList = append(conditionList, strings.Join(value, ""))
-				}
-			} else {
-				// If the condition doesn't match any search identifier, add it as is to the conditionList
-				conditionList = append(conditionList, condition)
+		if rule.negativeSamples {
+			result.Negatives[conditionIndex], err = rule.generateNegativeEvents(ctx, conditionIndex, result.ConditionTrees[conditionIndex])
+			if err != nil {
+				return Result{}, fmt.Errorf("error generating negative events for condition %d: %w", conditionIndex, err)
 			}
 		}
 
-		// add the conditionList to the final query string
-		result.Queries[i] = strings.Join(conditionList, "")
+		now = rule.scheduleCondition(interArrival, now, condition, result.Events[conditionIndex], result.Negatives[conditionIndex])
 
-		// Add the sourcetype condition to the final query string, if applicable
-		if rule.Logsource.Product != "" && rule.Logsource.Service != "" {
-			result.SourceTypes[i] = rule.Logsource.Product + " " + rule.Logsource.Service
-		} else if rule.Logsource.Product != "" && rule.Logsource.Service == "" {
-			result.SourceTypes[i] = rule.Logsource.Product
+		// Check that the events actually trigger the condition, and that the negative ones don't
+		result.Verifications[conditionIndex], err = rule.verify(ctx, conditionIndex, result.Events[conditionIndex], result.Negatives[conditionIndex])
+		if err != nil {
+			return Result{}, fmt.Errorf("error verifying events for condition %d: %w", conditionIndex, err)
 		}
 	}
 
 	return result, nil
 }
+
+// renderQuery renders the query of a condition tree: its String, or the query in the language of the backend of the rule if it has one.
+// Backend queries are restricted to the events of the logsource of the rule, given as the compiled logsource conditions.
+func (rule RuleEvaluator) renderQuery(logsource QueryNode, node QueryNode) (string, error) {
+	if rule.backend == nil {
+		return node.String(), nil
+	}
+	return RenderQuery(rule.backend, rule.indexes, And{logsource, node})
+}
+
+// sourceType returns the sourcetype of the events of the rule: the product and service of its logsource, or empty if it has no product.
+func (rule RuleEvaluator) sourceType() string {
+	switch {
+	case rule.Logsource.Product != "" && rule.Logsource.Service != "":
+		return rule.Logsource.Product + " " + rule.Logsource.Service
+	default:
+		return rule.Logsource.Product
+	}
+}
@@ -3,255 +3,321 @@ package sevaluator
 import (
 	"context"
 	"fmt"
-	"path"
+	"net"
+	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/mtnmunuklu/logen/sigma"
 	"github.com/mtnmunuklu/logen/sigma/sevaluator/modifiers"
 )
 
-// evaluateSearchExpression evaluates a Sigma search expression recursively and returns a string representation of the search condition.
-func (rule RuleEvaluator) evaluateSearchExpression(search sigma.SearchExpr, conditionResult []string, isTopLevel bool) []string {
-	// evaluate search expressions using a switch statement
+// compileSearchExpression compiles a Sigma search expression recursively to a query tree.
+// The search identifiers are replaced by the trees of the given searches, searches that aren't given are compiled as they are found.
+func (rule RuleEvaluator) compileSearchExpression(ctx context.Context, search sigma.SearchExpr, searches map[string]QueryNode) (QueryNode, error) {
 	switch s := search.(type) {
-	// if the search is an 'and' operation
 	case sigma.And:
-		// if not top level and more than 1 condition, add '(' to conditionResult
-		if !isTopLevel && len(s) > 1 {
-			conditionResult = append(conditionResult, "(")
-		}
-		// iterate through the conditions and add 'and' between them
-		for i, node := range s {
-			if i > 0 {
-				conditionResult = append(conditionResult, " and ")
-			}
-			// evaluate the nested search expression
-			conditionResult = rule.evaluateSearchExpression(node, conditionResult, false)
-		}
-		// if not top level and more than 1 condition, add ')' to conditionResult
-		if !isTopLevel && len(s) > 1 {
-			conditionResult = append(conditionResult, ")")
-		}
-		return conditionResult
+		return rule.compileAll(ctx, s, searches, true)
 
-	// if the search is an 'or' operation
 	case sigma.Or:
-		// if not top level and more than 1 condition, add '(' to conditionResult
-		if !isTopLevel && len(s) > 1 {
-			conditionResult = append(conditionResult, "(")
-		}
-		// iterate through the conditions and add 'or' between them
-		for i, node := range s {
-			if i > 0 {
-				conditionResult = append(conditionResult, " or ")
-			}
-			// evaluate the nested search expression
-			conditionResult = rule.evaluateSearchExpression(node, conditionResult, false)
-		}
-		// if not top level and more than 1 condition, add ')' to conditionResult
-		if !isTopLevel && len(s) > 1 {
-			conditionResult = append(conditionResult, ")")
-		}
-		return conditionResult
+		return rule.compileAll(ctx, s, searches, false)
 
-	// if the search is a 'not' operation
 	case sigma.Not:
-		// add 'not' to conditionResult
-		conditionResult = append(conditionResult, " not ")
-		// evaluate the nested search expression
-		conditionResult = rule.evaluateSearchExpression(s.Expr, conditionResult, false)
-		return conditionResult
+		node, err := rule.compileSearchExpression(ctx, s.Expr, searches)
+		if err != nil {
+			return nil, err
+		}
+		return Not{Node: node}, nil
 
-	// if the search is an identifier
 	case sigma.SearchIdentifier:
-		// add the identifier name to conditionResult
-		conditionResult = append(conditionResult, s.Name)
-		return conditionResult
+		return rule.compileSearchIdentifier(ctx, s.Name, searches)
+
+	case sigma.OneOfIdentifier:
+		return rule.compileSearchIdentifier(ctx, s.Ident.Name, searches)
 
-	// if the search is 'one of them'
+	case sigma.AllOfIdentifier:
+		return rule.compileSearchIdentifier(ctx, s.Ident.Name, searches)
+
+	// 'one of' expressions match if any of the searches does
 	case sigma.OneOfThem:
-		// if the number of searches is greater than 1, add "("
-		if !isTopLevel && len(rule.Detection.Searches) > 1 {
-			conditionResult = append(conditionResult, "(")
-		}
-		// iterate through all the search expressions and add 'or' between them
-		for name := range rule.Detection.Searches {
-			// If the search expression name matches the pattern, and it's not the first one, and the last element is not "and", "or", or "(", then add " or " to the condition result
-			if len(conditionResult) > 0 {
-				lastElement := conditionResult[len(conditionResult)-1]
-				if lastElement != "and" && lastElement != "or" && lastElement != "(" {
-					conditionResult = append(conditionResult, " or ")
-				}
-			}
-			// evaluate the nested search expression
-			conditionResult = rule.evaluateSearchExpression(sigma.SearchIdentifier{Name: name}, conditionResult, false)
-		}
-		// if the number of searches is greater than 1, add ")"
-		if !isTopLevel && len(rule.Detection.Searches) > 1 {
-			conditionResult = append(conditionResult, ")")
-		}
-		return conditionResult
+		return rule.compileSearchNames(ctx, rule.searchNames("*"), searches, false)
 
 	case sigma.OneOfPattern:
-		var matchingSearches []string
-		// Iterate over the search expressions in the rule's searches
-		for name := range rule.Detection.Searches {
-			// Check if the search expression name matches the pattern
-			matchesPattern, _ := path.Match(s.Pattern, name)
-			if matchesPattern {
-				// If the search expression name matches the pattern, add it to the matchingSearches slice
-				matchingSearches = append(matchingSearches, name)
-			}
-		}
+		return rule.compileSearchNames(ctx, rule.searchNames(s.Pattern), searches, false)
 
-		numMatchingSearches := len(matchingSearches)
-		// If there are more than one matching search expressions, add an opening parenthesis to the condition result
-		if !isTopLevel && numMatchingSearches > 1 {
-			conditionResult = append(conditionResult, "(")
-		}
+	// 'all of' expressions match if every search does
+	case sigma.AllOfThem:
+		return rule.compileSearchNames(ctx, rule.searchNames("*"), searches, true)
 
-		// Iterate over the matching search expressions
-		for i, name := range matchingSearches {
-			if i > 0 {
-				lastElement := conditionResult[len(conditionResult)-1]
-				// If the last element in the condition result is not "and", "or", or "(", add " or " to the condition result
-				if lastElement != "and" && lastElement != "or" && lastElement != "(" {
-					conditionResult = append(conditionResult, " or ")
-				}
-			}
-			// Evaluate the search expression and append its result to the condition result
-			conditionResult = rule.evaluateSearchExpression(sigma.SearchIdentifier{Name: name}, conditionResult, false)
-		}
+	case sigma.AllOfPattern:
+		return rule.compileSearchNames(ctx, rule.searchNames(s.Pattern), searches, true)
+	}
+	return nil, fmt.Errorf("unhandled node type %T", search)
+}
 
-		// If there are more than one matching search expressions, add a closing parenthesis to the condition result
-		if !isTopLevel && numMatchingSearches > 1 {
-			conditionResult = append(conditionResult, ")")
-		}
+// conditionTokens appends the tokens of a search expression to the given ones: the search identifiers, the operators surrounded by spaces,
+// and the parentheses around nested expressions with several operands. The searches of 'of' expressions are listed one by one.
+func (rule RuleEvaluator) conditionTokens(search sigma.SearchExpr, tokens []string, isTopLevel bool) []string {
+	switch s := search.(type) {
+	case sigma.And:
+		return rule.joinTokens(s, " and ", tokens, isTopLevel)
 
-		return conditionResult
+	case sigma.Or:
+		return rule.joinTokens(s, " or ", tokens, isTopLevel)
+
+	case sigma.Not:
+		return rule.conditionTokens(s.Expr, append(tokens, " not "), false)
+
+	case sigma.SearchIdentifier:
+		return append(tokens, s.Name)
+
+	case sigma.OneOfIdentifier:
+		return append(tokens, s.Ident.Name)
+
+	case sigma.AllOfIdentifier:
+		return append(tokens, s.Ident.Name)
+
+	case sigma.OneOfThem:
+		return rule.joinTokens(searchIdentifiers(rule.searchNames("*")), " or ", tokens, isTopLevel)
+
+	case sigma.OneOfPattern:
+		return rule.joinTokens(searchIdentifiers(rule.searchNames(s.Pattern)), " or ", tokens, isTopLevel)
 
 	case sigma.AllOfThem:
-		// if the number of searches is greater than 1, add "("
-		if !isTopLevel && len(rule.Detection.Searches) > 1 {
-			conditionResult = append(conditionResult, "(")
-		}
-		// iterate over all search expressions in the rule's searches
-		for name := range rule.Detection.Searches {
-			// If the search expression name matches the pattern, and it's not the first one, and the last element is not "and", "or", or "(", then add " or " to the condition result
-			if len(conditionResult) > 0 {
-				lastElement := conditionResult[len(conditionResult)-1]
-				if lastElement != "and" && lastElement != "or" && lastElement != "(" {
-					conditionResult = append(conditionResult, " and ")
-				}
-			}
-			// recursively evaluate the search expression and append its result to the condition result
-			conditionResult = rule.evaluateSearchExpression(sigma.SearchIdentifier{Name: name}, conditionResult, false)
-		}
-		// if the number of searches is greater than 1, add ")"
-		if !isTopLevel && len(rule.Detection.Searches) > 1 {
-			conditionResult = append(conditionResult, ")")
-		}
-		return conditionResult
+		return rule.joinTokens(searchIdentifiers(rule.searchNames("*")), " and ", tokens, isTopLevel)
 
 	case sigma.AllOfPattern:
-		var matchingSearches []string
-		// Iterate over the search expressions in the rule's searches
-		for name := range rule.Detection.Searches {
-			// Check if the search expression name matches the pattern
-			matchesPattern, _ := path.Match(s.Pattern, name)
-			if matchesPattern {
-				// If the search expression name matches the pattern, add it to the matchingSearches slice
-				matchingSearches = append(matchingSearches, name)
-			}
-		}
+		return rule.joinTokens(searchIdentifiers(rule.searchNames(s.Pattern)), " and ", tokens, isTopLevel)
+	}
+	return tokens
+}
 
-		numMatchingSearches := len(matchingSearches)
-		// If there are more than one matching search expressions, add an opening parenthesis to the condition result
-		if !isTopLevel && numMatchingSearches > 1 {
-			conditionResult = append(conditionResult, "(")
+// joinTokens appends the tokens of the search expressions, separated by the operator, in parentheses unless there is only one or they are the top level.
+func (rule RuleEvaluator) joinTokens(nodes []sigma.SearchExpr, operator string, tokens []string, isTopLevel bool) []string {
+	grouped := !isTopLevel && len(nodes) > 1
+	if grouped {
+		tokens = append(tokens, "(")
+	}
+	for i, node := range nodes {
+		if i > 0 {
+			tokens = append(tokens, operator)
 		}
+		tokens = rule.conditionTokens(node, tokens, false)
+	}
+	if grouped {
+		tokens = append(tokens, ")")
+	}
+	return tokens
+}
 
-		// Iterate over the matching search expressions
-		for i, name := range matchingSearches {
-			if i > 0 {
-				lastElement := conditionResult[len(conditionResult)-1]
-				// If the last element in the condition result is not "and", "or", or "(", add " or " to the condition result
-				if lastElement != "and" && lastElement != "or" && lastElement != "(" {
-					conditionResult = append(conditionResult, " and ")
-				}
-			}
-			// Evaluate the search expression and append its result to the condition result
-			conditionResult = rule.evaluateSearchExpression(sigma.SearchIdentifier{Name: name}, conditionResult, false)
+// searchIdentifiers returns the search expressions that refer to the named searches.
+func searchIdentifiers(names []string) []sigma.SearchExpr {
+	identifiers := make([]sigma.SearchExpr, len(names))
+	for i, name := range names {
+		identifiers[i] = sigma.SearchIdentifier{Name: name}
+	}
+	return identifiers
+}
+
+// compileAll compiles the conjunction or disjunction of search expressions.
+func (rule RuleEvaluator) compileAll(ctx context.Context, nodes []sigma.SearchExpr, searches map[string]QueryNode, all bool) (QueryNode, error) {
+	var operands []QueryNode
+	for _, node := range nodes {
+		operand, err := rule.compileSearchExpression(ctx, node, searches)
+		if err != nil {
+			return nil, err
 		}
+		operands = append(operands, operand)
+	}
+	if all {
+		return conjunction(operands), nil
+	}
+	return disjunction(operands), nil
+}
 
-		// If there are more than one matching search expressions, add a closing parenthesis to the condition result
-		if !isTopLevel && numMatchingSearches > 1 {
-			conditionResult = append(conditionResult, ")")
+// compileSearchNames compiles the conjunction or disjunction of the named searches.
+func (rule RuleEvaluator) compileSearchNames(ctx context.Context, names []string, searches map[string]QueryNode, all bool) (QueryNode, error) {
+	var operands []QueryNode
+	for _, name := range names {
+		operand, err := rule.compileSearchIdentifier(ctx, name, searches)
+		if err != nil {
+			return nil, err
 		}
+		operands = append(operands, operand)
+	}
+	if all {
+		return conjunction(operands), nil
+	}
+	return disjunction(operands), nil
+}
 
-		return conditionResult
+// compileSearchIdentifier returns the query tree of the search with the given identifier, compiling it if it isn't one of the given searches.
+func (rule RuleEvaluator) compileSearchIdentifier(ctx context.Context, name string, searches map[string]QueryNode) (QueryNode, error) {
+	if node, ok := searches[name]; ok {
+		return node, nil
 	}
-	panic(fmt.Sprintf("unhandled node type %T", search))
+	search, ok := rule.Detection.Searches[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown search identifier %s", name)
+	}
+	node, err := rule.compileSearch(ctx, search)
+	if err != nil {
+		return nil, fmt.Errorf("error compiling search %s: %w", name, err)
+	}
+	return node, nil
 }
 
-// The evaluateSearch function takes a sigma.Search object and evaluates it, returning a slice of filter strings or an error.
-func (rule RuleEvaluator) evaluateSearch(ctx context.Context, search sigma.Search) ([]string, error) {
-	var filters []string
+// compileLogsource compiles the conditions that the config requires of the logsource of the rule, which every event of the rule has to match.
+func (rule RuleEvaluator) compileLogsource(ctx context.Context) (QueryNode, error) {
+	var operands []QueryNode
+	for _, indexCondition := range rule.indexConditions {
+		operand, err := rule.compileSearch(ctx, indexCondition)
+		if err != nil {
+			return nil, fmt.Errorf("error compiling logsource conditions: %w", err)
+		}
+		operands = append(operands, operand)
+	}
+	return conjunction(operands), nil
+}
 
+// compileSearch compiles a single search to a query tree.
+func (rule RuleEvaluator) compileSearch(ctx context.Context, search sigma.Search) (QueryNode, error) {
+	// Keywords are searched in the free-text fields of the event
 	if len(search.Keywords) > 0 {
-		return filters, fmt.Errorf("keywords unsupported")
+		return rule.compileKeywords(search.Keywords), nil
 	}
 
 	if len(search.EventMatchers) == 0 {
-		// degenerate case (but common for logsource conditionResults)
-		return filters, nil
+		// degenerate case (but common for logsource conditions), the search matches every event
+		return And{}, nil
 	}
 
 	// A Search is a series of EventMatchers (usually one)
-	// Each EventMatchers is a series of "does this field match this value" conditionResults
+	// Each EventMatchers is a series of "does this field match this value" conditions
 	// all fields need to match for an EventMatcher to match, but only one EventMatcher needs to match for the Search to evaluate to true
+	var eventMatchers []QueryNode
 	for _, eventMatcher := range search.EventMatchers {
+		var fieldMatchers []QueryNode
 		for _, fieldMatcher := range eventMatcher {
-			// A field matcher can specify multiple values to match against
-			// either the field should match all of these values or it should match any of them
-			allValuesMustMatch := false
-			fieldModifiers := fieldMatcher.Modifiers
-			if len(fieldMatcher.Modifiers) > 0 && fieldModifiers[len(fieldModifiers)-1] == "all" {
-				allValuesMustMatch = true
-				fieldModifiers = fieldModifiers[:len(fieldModifiers)-1]
+			node, err := rule.compileFieldMatcher(ctx, fieldMatcher)
+			if err != nil {
+				return nil, err
 			}
+			fieldMatchers = append(fieldMatchers, node)
+		}
+		eventMatchers = append(eventMatchers, conjunction(fieldMatchers))
+	}
+	return disjunction(eventMatchers), nil
+}
 
-			var comparator modifiers.ComparatorFunc
-			var err error
-			if rule.caseSensitive {
-				comparator, err = modifiers.GetComparatorCaseSensitive(fieldModifiers...)
-			} else {
-				comparator, err = modifiers.GetComparator(fieldModifiers...)
-			}
+// compileFieldMatcher compiles a field matcher: any of the event fields that the rule field is mapped to matches any of its values,
+// or all of them with the all modifier. Values that expand into several variants match if any variant does.
+func (rule RuleEvaluator) compileFieldMatcher(ctx context.Context, fieldMatcher sigma.FieldMatcher) (QueryNode, error) {
+	fieldModifiers, allValuesMustMatch := removeModifier(fieldMatcher.Modifiers, "all")
+	caseSensitive := rule.caseSensitive
+	for _, modifier := range fieldModifiers {
+		// The case of base64 characters is part of the encoded data, so encoded values are rendered and compared as the modifier produces them
+		caseSensitive = caseSensitive || modifier == "cased" || modifier == "base64" || modifier == "base64offset"
+	}
 
-			matcherValues, err := rule.getMatcherValues(ctx, fieldMatcher)
-			if err != nil {
-				return filters, err
-			}
+	operator, toValues, err := modifiers.GetValues(fieldModifiers...)
+	if err != nil {
+		return nil, err
+	}
+	matcherValues, err := rule.getMatcherValues(ctx, fieldMatcher)
+	if err != nil {
+		return nil, err
+	}
+
+	// If there are field mappings defined, any of the mapped fields has to match, otherwise only the specified field is checked
+	fields := rule.fieldmappings[fieldMatcher.Field]
+	if len(fields) == 0 {
+		fields = []string{fieldMatcher.Field}
+	}
 
-			var filter string
-			if len(rule.fieldmappings[fieldMatcher.Field]) == 0 {
-				// If there are no field mappings defined, only the specified field is checked
-				filter, err = rule.matcherMatchesValues(matcherValues, []string{fieldMatcher.Field}, comparator, allValuesMustMatch)
-			} else {
-				// If there are field mappings defined, they are used to check multiple fields
-				filter, err = rule.matcherMatchesValues(matcherValues, rule.fieldmappings[fieldMatcher.Field], comparator, allValuesMustMatch)
+	// Numbers in the rule are compared as numbers, unless value modifiers turn them into strings
+	numbers := map[string]bool{}
+	for _, value := range fieldMatcher.Values {
+		switch value.(type) {
+		case int:
+			numbers[fmt.Sprint(value)] = len(fieldModifiers) == 0
+		case float32, float64:
+			// Floats keep a decimal point, as in getMatcherValues
+			text := fmt.Sprint(value)
+			if !strings.ContainsAny(text, ".eEnN") {
+				text += ".0"
 			}
+			numbers[text] = len(fieldModifiers) == 0
+		}
+	}
 
+	var fieldNodes []QueryNode
+	for _, field := range fields {
+		var valueNodes []QueryNode
+		for _, value := range matcherValues {
+			variants, err := toValues(value)
 			if err != nil {
-				return filters, err
+				return nil, err
+			}
+
+			var variantNodes []QueryNode
+			for _, variant := range variants {
+				predicate, err := rule.predicate(field, operator, variant, caseSensitive)
+				if err != nil {
+					return nil, fmt.Errorf("%s|%s: %w", field, operator, err)
+				}
+				predicate.Numeric = operator == "equal" && numbers[predicate.Value]
+				variantNodes = append(variantNodes, predicate)
 			}
+			valueNodes = append(valueNodes, disjunction(variantNodes))
+		}
 
-			filters = append(filters, filter)
+		if allValuesMustMatch {
+			fieldNodes = append(fieldNodes, conjunction(valueNodes))
+		} else {
+			fieldNodes = append(fieldNodes, disjunction(valueNodes))
 		}
 	}
+	return disjunction(fieldNodes), nil
+}
 
-	return filters, nil
+// predicate returns the FieldPredicate that compares an event field with a variant of an expected value.
+// The value null is turned into a check that the field doesn't exist, and the values of numeric, exists, cidr and re comparisons are validated,
+// so that invalid rules fail when they are compiled rather than when their events are generated or matched.
+func (rule RuleEvaluator) predicate(field string, operator string, value any, caseSensitive bool) (FieldPredicate, error) {
+	predicate := FieldPredicate{Field: field, Operator: operator, CaseSensitive: caseSensitive}
+	// References are resolved against the event fields that the referenced rule fields are written to
+	if reference, ok := value.(modifiers.FieldRef); ok {
+		predicate.Value, predicate.Reference = rule.eventField(reference.Field), true
+		return predicate, nil
+	}
+	predicate.Value = fmt.Sprint(value)
+
+	switch operator {
+	case "equal":
+		if predicate.Value == "null" {
+			predicate.Operator, predicate.Value = "exists", "false"
+		}
+	case "exists":
+		exists, err := strconv.ParseBool(predicate.Value)
+		if err != nil {
+			return FieldPredicate{}, fmt.Errorf("expected true or false for the exists modifier, got %v", value)
+		}
+		predicate.Value = strconv.FormatBool(exists)
+	case "gt", "gte", "lt", "lte":
+		if _, err := strconv.ParseFloat(predicate.Value, 64); err != nil {
+			return FieldPredicate{}, fmt.Errorf("expected a numeric value, got %v", value)
+		}
+	case "cidr":
+		if _, _, err := net.ParseCIDR(predicate.Value); err != nil {
+			return FieldPredicate{}, fmt.Errorf("invalid CIDR block %v: %w", value, err)
+		}
+	case "re":
+		if _, err := regexp.Compile(predicate.Value); err != nil {
+			return FieldPredicate{}, fmt.Errorf("invalid regex %v: %w", value, err)
+		}
+	}
+	return predicate, nil
 }
 
 // getMatcherValues function retrieves the matching values for a field matcher.
@@ -259,6 +325,9 @@ func (rule *RuleEvaluator) getMatcherValues(ctx context.Context, matcher sigma.F
 	// Initialize an empty array for the matching values.
 	matcherValues := []string{}
 
+	// The 'expand' modifier means that placeholders can appear anywhere in the values, not only as whole values
+	_, expand := removeModifier(matcher.Modifiers, "expand")
+
 	// Loop through all abstract values for the matcher.
 	for _, abstractValue := range matcher.Values {
 		value := ""
@@ -267,8 +336,14 @@ func (rule *RuleEvaluator) getMatcherValues(ctx context.Context, matcher sigma.F
 		switch abstractValue := abstractValue.(type) {
 		case string:
 			value = abstractValue
-		case int, float32, float64, bool:
+		case int, bool:
+			value = fmt.Sprintf("%v", abstractValue)
+		case float32, float64:
+			// Floats keep a decimal point, so that numbers generated for them are floats as well
 			value = fmt.Sprintf("%v", abstractValue)
+			if !strings.ContainsAny(value, ".eEnN") {
+				value += ".0"
+			}
 		case nil:
 			value = "null"
 		default:
@@ -286,6 +361,12 @@ func (rule *RuleEvaluator) getMatcherValues(ctx context.Context, matcher sigma.F
 			}
 			// Append the placeholderValues to the matcherValues array.
 			matcherValues = append(matcherValues, placeholderValues...)
+		} else if expand && placeholderPattern.MatchString(value) {
+			expandedValues, err := rule.expandPlaceholders(ctx, value)
+			if err != nil {
+				return nil, err
+			}
+			matcherValues = append(matcherValues, expandedValues...)
 		} else {
 			// Append the scalar value to the matcherValues array.
 			matcherValues = append(matcherValues, value)
@@ -295,47 +376,36 @@ func (rule *RuleEvaluator) getMatcherValues(ctx context.Context, matcher sigma.F
 	return matcherValues, nil
 }
 
-// matcherMatchesValues takes a list of values to match against a list of fields,
-// a comparator function to compare values and fields, and a boolean indicating whether all values must match or any of them.
-// It returns a string representing a filter that can be used to match events with the specified fields and values.
-func (rule *RuleEvaluator) matcherMatchesValues(matcherValues []string, fields []string, comparator modifiers.ComparatorFunc, allValuesMustMatch bool) (string, error) {
-	var filters []string
-	for i, field := range fields {
-		var subFilters []string
-		for j, value := range matcherValues {
-			// compare field and value using the provided comparator function
-			filter, err := comparator(field, value)
-			if err != nil {
-				return "", err
-			}
-			if j == 0 {
-				// first match value should be added directly to subFilters
-				subFilters = append(subFilters, filter)
-			} else if allValuesMustMatch {
-				// if all values must match, add " and " between subfilters
-				subFilters = append(subFilters, " and ", filter)
-			} else {
-				// if any value can match, add " or " between subfilters
-				subFilters = append(subFilters, " or ", filter)
-			}
-		}
-		if len(matcherValues) > 1 {
-			// if there are multiple matcher values, wrap subFilters in parentheses to keep operator precedence
-			filters = append(filters, "("+strings.Join(subFilters, "")+")")
-		} else {
-			// if there's only one matcher value, subFilters can be added directly to filters
-			filters = append(filters, subFilters...)
+// placeholderPattern matches the placeholders within a value, e.g. %ProgramFiles% in %ProgramFiles%\app.exe.
+var placeholderPattern = regexp.MustCompile(`%[^%\s]+%`)
+
+// expandPlaceholders replaces every placeholder within a value with each of the values it expands to.
+// A value with several placeholders expands into every combination of their values.
+func (rule *RuleEvaluator) expandPlaceholders(ctx context.Context, value string) ([]string, error) {
+	if rule.expandPlaceholder == nil {
+		return nil, fmt.Errorf("can't expand %s, no placeholder expander function defined", value)
+	}
+
+	expanded := []string{""}
+	previous := 0
+	for _, match := range placeholderPattern.FindAllStringIndex(value, -1) {
+		placeholderValues, err := rule.expandPlaceholder(ctx, value[match[0]:match[1]])
+		if err != nil {
+			return nil, fmt.Errorf("failed to expand placeholder: %w", err)
 		}
-		if i < len(fields)-1 {
-			// if there are multiple fields, add " or " between them
-			filters = append(filters, " or ")
+
+		var combined []string
+		for _, prefix := range expanded {
+			for _, placeholderValue := range placeholderValues {
+				combined = append(combined, prefix+value[previous:match[0]]+placeholderValue)
+			}
 		}
+		expanded = combined
+		previous = match[1]
 	}
-	if len(fields) > 1 {
-		// if there are multiple fields, wrap filters in parentheses to keep operator precedence
-		return "(" + strings.Join(filters, "") + ")", nil
-	} else {
-		// if there's only one field, filters can be added directly
-		return strings.Join(filters, ""), nil
+
+	for i := range expanded {
+		expanded[i] += value[previous:]
 	}
+	return expanded, nil
 }
@@ -0,0 +1,119 @@
+package formatters
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Vendor and version reported in the headers of CEF and LEEF events
+const (
+	DeviceVendor  = "Logen"
+	DeviceVersion = "1.0"
+)
+
+// CEFSeverities maps Sigma rule levels to CEF and LEEF severities, from 0 to 10.
+var CEFSeverities = map[string]int{
+	"informational": 1,
+	"low":           3,
+	"medium":        5,
+	"high":          8,
+	"critical":      10,
+}
+
+// Escaping rules of the CEF and LEEF formats
+var (
+	cefHeaderEscaper    = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\n", " ", "\r", " ")
+	cefExtensionEscaper = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\n", `\n`, "\r", `\r`)
+	leefEscaper         = strings.NewReplacer("\t", " ", "\n", " ", "\r", " ")
+)
+
+// WriteCEF writes the records as ArcSight Common Event Format (CEF) events, one event per line.
+// Fields become the extension of the event, alongside the receipt time of the record.
+func WriteCEF(w io.Writer, records []Record) error {
+	for _, record := range records {
+		header := []string{
+			"CEF:0",
+			cefHeaderEscaper.Replace(DeviceVendor),
+			cefHeaderEscaper.Replace(deviceProduct(record)),
+			cefHeaderEscaper.Replace(DeviceVersion),
+			cefHeaderEscaper.Replace(signatureID(record)),
+			cefHeaderEscaper.Replace(record.Title),
+			fmt.Sprint(severity(record)),
+		}
+
+		extension := []string{"rt=" + formatTimestamp("cef", record)}
+		for _, name := range sortedFields(record) {
+			extension = append(extension, cefKey(name)+"="+cefExtensionEscaper.Replace(coerceString(record.Fields[name])))
+		}
+
+		if _, err := fmt.Fprintf(w, "%s|%s\n", strings.Join(header, "|"), strings.Join(extension, " ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteLEEF writes the records as IBM QRadar Log Event Extended Format (LEEF) 1.0 events, one event per line.
+// Fields become tab separated attributes of the event, alongside the device time and severity of the record.
+func WriteLEEF(w io.Writer, records []Record) error {
+	for _, record := range records {
+		header := []string{
+			"LEEF:1.0",
+			cefHeaderEscaper.Replace(DeviceVendor),
+			cefHeaderEscaper.Replace(deviceProduct(record)),
+			cefHeaderEscaper.Replace(DeviceVersion),
+			cefHeaderEscaper.Replace(signatureID(record)),
+		}
+
+		attributes := []string{
+			"devTime=" + formatTimestamp("leef", record),
+			"devTimeFormat=MMM dd yyyy HH:mm:ss.SSS z",
+			fmt.Sprintf("sev=%d", severity(record)),
+		}
+		for _, name := range sortedFields(record) {
+			attributes = append(attributes, cefKey(name)+"="+leefEscaper.Replace(coerceString(record.Fields[name])))
+		}
+
+		if _, err := fmt.Fprintf(w, "%s|%s\n", strings.Join(header, "|"), strings.Join(attributes, "\t")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deviceProduct returns the product reported in the header of a record, taken from its logsource.
+func deviceProduct(record Record) string {
+	for _, product := range []string{record.Logsource.Product, record.Logsource.Service, record.Logsource.Category} {
+		if product != "" {
+			return product
+		}
+	}
+	return "sigma"
+}
+
+// signatureID returns the identifier of the event class of a record, the ID of its rule if it has one.
+func signatureID(record Record) string {
+	if record.RuleID != "" {
+		return record.RuleID
+	}
+	return fmt.Sprint(record.Condition)
+}
+
+// severity returns the CEF severity of a record, computed from the level of its rule.
+func severity(record Record) int {
+	if severity, ok := CEFSeverities[strings.ToLower(record.Level)]; ok {
+		return severity
+	}
+	return CEFSeverities["medium"]
+}
+
+// cefKey converts a field name to an extension key, which can't contain spaces or equal signs.
+func cefKey(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '=' || r == '\t' {
+			return '_'
+		}
+		return r
+	}, name)
+}
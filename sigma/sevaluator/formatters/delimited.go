@@ -0,0 +1,125 @@
+package formatters
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+	"time"
+)
+
+// zeekEscaper escapes the separators of the Zeek TSV format.
+var zeekEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\x09`, "\n", `\x0a`, ",", `\x2c`)
+
+// WriteZeek writes the records as a Zeek TSV log, with a column per field and a row per record.
+// The log is named after the service of the first record, and the "ts" column holds the time of each record.
+func WriteZeek(w io.Writer, records []Record) error {
+	path := "logen"
+	if len(records) > 0 && records[0].Logsource.Service != "" {
+		path = records[0].Logsource.Service
+	}
+
+	var names []string
+	for _, name := range fieldNames(records) {
+		if name != "ts" {
+			names = append(names, name)
+		}
+	}
+	columns := append([]string{"ts"}, names...)
+	types := []string{"time"}
+	for _, name := range names {
+		types = append(types, zeekType(records, name))
+	}
+
+	opened := time.Now()
+	if len(records) > 0 {
+		opened = timestamp(records[0])
+	}
+	header := []string{
+		`#separator \x09`,
+		"#set_separator\t,",
+		"#empty_field\t(empty)",
+		"#unset_field\t-",
+		"#path\t" + path,
+		"#open\t" + opened.UTC().Format("2006-01-02-15-04-05"),
+		"#fields\t" + strings.Join(columns, "\t"),
+		"#types\t" + strings.Join(types, "\t"),
+	}
+	if _, err := io.WriteString(w, strings.Join(header, "\n")+"\n"); err != nil {
+		return err
+	}
+
+	closed := opened
+	for _, record := range records {
+		if t := timestamp(record); t.After(closed) {
+			closed = t
+		}
+
+		row := []string{formatTimestamp("zeek", record)}
+		for _, name := range names {
+			value, ok := record.Fields[name]
+			switch {
+			case !ok || value == nil:
+				row = append(row, "-")
+			case coerceString(value) == "":
+				row = append(row, "(empty)")
+			default:
+				row = append(row, zeekEscaper.Replace(coerceString(value)))
+			}
+		}
+		if _, err := io.WriteString(w, strings.Join(row, "\t")+"\n"); err != nil {
+			return err
+		}
+	}
+
+	_, err := io.WriteString(w, "#close\t"+closed.UTC().Format("2006-01-02-15-04-05")+"\n")
+	return err
+}
+
+// zeekType returns the Zeek type of a column, computed from the values of the field in the records.
+func zeekType(records []Record, name string) string {
+	columnType := ""
+	for _, record := range records {
+		var valueType string
+		switch record.Fields[name].(type) {
+		case nil:
+			continue
+		case int, int64, uint16, uint64:
+			valueType = "count"
+		case float32, float64:
+			valueType = "double"
+		case bool:
+			valueType = "bool"
+		default:
+			valueType = "string"
+		}
+		if columnType != "" && columnType != valueType {
+			return "string"
+		}
+		columnType = valueType
+	}
+	if columnType == "" {
+		return "string"
+	}
+	return columnType
+}
+
+// WriteCSV writes the records as CSV, with a header row naming the columns and a row per record.
+// The first columns hold the time and rule of each record, followed by a column per field.
+func WriteCSV(w io.Writer, records []Record) error {
+	names := fieldNames(records)
+	writer := csv.NewWriter(w)
+	if err := writer.Write(append([]string{"timestamp", "rule_id", "title"}, names...)); err != nil {
+		return err
+	}
+	for _, record := range records {
+		row := []string{formatTimestamp("csv", record), record.RuleID, record.Title}
+		for _, name := range names {
+			row = append(row, coerceString(record.Fields[name]))
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,100 @@
+package formatters
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+)
+
+// ECSVersion is the version of the Elastic Common Schema that records are rendered in.
+const ECSVersion = "8.11.0"
+
+// ECSFields maps Sigma field names to their Elastic Common Schema counterparts.
+// Fields without a mapping are kept under their original name.
+var ECSFields = map[string]string{
+	"EventID":             "event.code",
+	"Computer":            "host.name",
+	"User":                "user.name",
+	"CommandLine":         "process.command_line",
+	"Image":               "process.executable",
+	"ProcessId":           "process.pid",
+	"CurrentDirectory":    "process.working_directory",
+	"OriginalFileName":    "process.pe.original_file_name",
+	"ParentCommandLine":   "process.parent.command_line",
+	"ParentImage":         "process.parent.executable",
+	"ParentProcessId":     "process.parent.pid",
+	"TargetFilename":      "file.path",
+	"ImageLoaded":         "dll.path",
+	"TargetObject":        "registry.path",
+	"Details":             "registry.data.strings",
+	"QueryName":           "dns.question.name",
+	"SourceIp":            "source.ip",
+	"SourcePort":          "source.port",
+	"SourceHostname":      "source.domain",
+	"DestinationIp":       "destination.ip",
+	"DestinationPort":     "destination.port",
+	"DestinationHostname": "destination.domain",
+	"c-ip":                "source.ip",
+	"c-uri":               "url.original",
+	"c-useragent":         "user_agent.original",
+	"cs-method":           "http.request.method",
+	"cs-host":             "url.domain",
+	"r-dns":               "destination.domain",
+	"sc-status":           "http.response.status_code",
+}
+
+// WriteECS writes the records as Elastic Common Schema documents, one JSON document per line.
+func WriteECS(w io.Writer, records []Record) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	for _, record := range records {
+		document := map[string]interface{}{}
+		setECSField(document, "@timestamp", formatTimestamp("ecs", record))
+		setECSField(document, "ecs.version", ECSVersion)
+		setECSField(document, "event.kind", "event")
+		if record.Logsource.Product != "" {
+			setECSField(document, "event.module", strings.ToLower(record.Logsource.Product))
+		}
+		if record.Logsource.Category != "" {
+			setECSField(document, "event.category", strings.ToLower(record.Logsource.Category))
+		}
+		if record.RuleID != "" {
+			setECSField(document, "rule.id", record.RuleID)
+		}
+		if record.Title != "" {
+			setECSField(document, "rule.name", record.Title)
+		}
+
+		for _, name := range sortedFields(record) {
+			field := name
+			if mapped, ok := ECSFields[name]; ok {
+				field = mapped
+			}
+			setECSField(document, field, record.Fields[name])
+		}
+
+		if err := encoder.Encode(document); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setECSField sets a dotted ECS field name in a nested JSON document.
+// If an intermediate member already holds a value, the dotted name is kept as is.
+func setECSField(document map[string]interface{}, field string, value interface{}) {
+	parts := strings.Split(field, ".")
+	for i, part := range parts[:len(parts)-1] {
+		child, ok := document[part].(map[string]interface{})
+		if !ok {
+			if _, exists := document[part]; exists {
+				document[strings.Join(parts[i:], ".")] = value
+				return
+			}
+			child = map[string]interface{}{}
+			document[part] = child
+		}
+		document = child
+	}
+	document[parts[len(parts)-1]] = value
+}
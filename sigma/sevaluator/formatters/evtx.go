@@ -0,0 +1,262 @@
+package formatters
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mtnmunuklu/logen/sigma"
+)
+
+// WindowsChannel describes the provider and channel that a Windows event is logged to.
+type WindowsChannel struct {
+	Provider string // The name of the event provider
+	GUID     string // The GUID of the event provider
+	Channel  string // The name of the event log channel
+	EventID  int    // The default event ID
+	Version  int    // The version of the event
+	Task     int    // The task category of the event
+}
+
+var (
+	sysmonProvider   = WindowsChannel{Provider: "Microsoft-Windows-Sysmon", GUID: "{5770385F-C22A-43E0-BF4C-06F5698FFBD9}", Channel: "Microsoft-Windows-Sysmon/Operational"}
+	securityProvider = WindowsChannel{Provider: "Microsoft-Windows-Security-Auditing", GUID: "{54849625-5478-4994-A5BA-3E3B0328C30D}", Channel: "Security"}
+	systemProvider   = WindowsChannel{Provider: "Service Control Manager", GUID: "{555908D1-A6D7-4695-8E1E-26931D2012F4}", Channel: "System"}
+	psProvider       = WindowsChannel{Provider: "Microsoft-Windows-PowerShell", GUID: "{A0C1853B-5C40-4B15-8766-3CF1C58F985A}", Channel: "Microsoft-Windows-PowerShell/Operational"}
+	psClassic        = WindowsChannel{Provider: "PowerShell", Channel: "Windows PowerShell"}
+)
+
+// withEvent returns a copy of the channel with the given event ID, version and task.
+func (c WindowsChannel) withEvent(eventID, version, task int) WindowsChannel {
+	c.EventID, c.Version, c.Task = eventID, version, task
+	return c
+}
+
+// SysmonChannels maps Sigma logsource categories to the Sysmon events that log them.
+var SysmonChannels = map[string]WindowsChannel{
+	"process_creation":      sysmonProvider.withEvent(1, 5, 1),
+	"file_change":           sysmonProvider.withEvent(2, 5, 2),
+	"network_connection":    sysmonProvider.withEvent(3, 5, 3),
+	"sysmon_status":         sysmonProvider.withEvent(4, 3, 4),
+	"process_termination":   sysmonProvider.withEvent(5, 3, 5),
+	"driver_load":           sysmonProvider.withEvent(6, 4, 6),
+	"image_load":            sysmonProvider.withEvent(7, 3, 7),
+	"create_remote_thread":  sysmonProvider.withEvent(8, 2, 8),
+	"raw_access_thread":     sysmonProvider.withEvent(9, 2, 9),
+	"process_access":        sysmonProvider.withEvent(10, 3, 10),
+	"file_event":            sysmonProvider.withEvent(11, 2, 11),
+	"registry_add":          sysmonProvider.withEvent(12, 2, 12),
+	"registry_delete":       sysmonProvider.withEvent(12, 2, 12),
+	"registry_event":        sysmonProvider.withEvent(13, 2, 13),
+	"registry_set":          sysmonProvider.withEvent(13, 2, 13),
+	"registry_rename":       sysmonProvider.withEvent(14, 2, 14),
+	"create_stream_hash":    sysmonProvider.withEvent(15, 2, 15),
+	"pipe_created":          sysmonProvider.withEvent(17, 1, 17),
+	"wmi_event":             sysmonProvider.withEvent(19, 3, 19),
+	"dns_query":             sysmonProvider.withEvent(22, 5, 22),
+	"file_delete":           sysmonProvider.withEvent(23, 5, 23),
+	"clipboard_capture":     sysmonProvider.withEvent(24, 5, 24),
+	"process_tampering":     sysmonProvider.withEvent(25, 5, 25),
+	"file_delete_detected":  sysmonProvider.withEvent(26, 5, 26),
+	"file_block_executable": sysmonProvider.withEvent(27, 5, 27),
+}
+
+// SecurityChannels maps Sigma logsource categories to the Security auditing events that log them.
+var SecurityChannels = map[string]WindowsChannel{
+	"process_creation":    securityProvider.withEvent(4688, 2, 13312),
+	"process_termination": securityProvider.withEvent(4689, 0, 13313),
+	"network_connection":  securityProvider.withEvent(5156, 1, 12810),
+	"registry_set":        securityProvider.withEvent(4657, 0, 12801),
+	"registry_event":      securityProvider.withEvent(4657, 0, 12801),
+	"file_event":          securityProvider.withEvent(4663, 1, 12800),
+	"":                    securityProvider.withEvent(4624, 2, 12544),
+}
+
+// ServiceChannels maps Sigma logsource services to the channels of the events they log.
+var ServiceChannels = map[string]WindowsChannel{
+	"system":             systemProvider.withEvent(7045, 0, 0),
+	"powershell":         psProvider.withEvent(4104, 1, 2),
+	"powershell-classic": psClassic.withEvent(400, 0, 4),
+}
+
+// PowerShellChannels maps the PowerShell logsource categories to the events that log them.
+var PowerShellChannels = map[string]WindowsChannel{
+	"ps_script":        psProvider.withEvent(4104, 1, 2),
+	"ps_module":        psProvider.withEvent(4103, 1, 106),
+	"ps_classic_start": psClassic.withEvent(400, 0, 4),
+}
+
+// LookupWindowsChannel returns the provider, channel and default event ID of the Windows events that a logsource describes.
+// Rules for the security service use the Security auditing events, all other categories default to Sysmon.
+func LookupWindowsChannel(logsource sigma.Logsource) (WindowsChannel, error) {
+	category := strings.ToLower(logsource.Category)
+	service := strings.ToLower(logsource.Service)
+
+	if channel, ok := PowerShellChannels[category]; ok {
+		return channel, nil
+	}
+	if service == "security" {
+		if channel, ok := SecurityChannels[category]; ok {
+			return channel, nil
+		}
+		return SecurityChannels[""], nil
+	}
+	if service == "" || service == "sysmon" {
+		if channel, ok := SysmonChannels[category]; ok {
+			return channel, nil
+		}
+	}
+	if channel, ok := ServiceChannels[service]; ok {
+		return channel, nil
+	}
+
+	return WindowsChannel{}, fmt.Errorf("no windows channel known for logsource %s/%s", logsource.Service, logsource.Category)
+}
+
+// IsWindows reports whether the logsource describes Windows events.
+func IsWindows(logsource sigma.Logsource) bool {
+	return strings.EqualFold(logsource.Product, "windows")
+}
+
+// WriteEventXML writes the records as Windows Event XML, one <Event> element per record.
+func WriteEventXML(w io.Writer, records []Record) error {
+	for i, record := range records {
+		event, err := newWindowsEvent(record, i+1, timestamp(record))
+		if err != nil {
+			return err
+		}
+		if err := event.writeXML(w, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newWindowsEvent builds the Windows Event XML document of a record.
+// The EventID and Computer fields of the record override the defaults of its channel, all other fields become EventData.
+func newWindowsEvent(record Record, recordID int, created time.Time) (*element, error) {
+	if !IsWindows(record.Logsource) {
+		return nil, fmt.Errorf("windows event XML requires a windows logsource, got %q", record.Logsource.Product)
+	}
+	channel, err := LookupWindowsChannel(record.Logsource)
+	if err != nil {
+		return nil, err
+	}
+
+	eventID := strconv.Itoa(channel.EventID)
+	computer := "DESKTOP-LOGEN"
+	var names []string
+	for name, value := range record.Fields {
+		switch name {
+		case "EventID":
+			eventID = coerceString(value)
+		case "Computer":
+			computer = coerceString(value)
+		default:
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	eventData := &element{name: "EventData"}
+	for _, name := range names {
+		eventData.children = append(eventData.children, &element{
+			name:  "Data",
+			attrs: []attribute{{"Name", name}},
+			text:  coerceString(record.Fields[name]),
+		})
+	}
+
+	provider := &element{name: "Provider", attrs: []attribute{{"Name", channel.Provider}}}
+	if channel.GUID != "" {
+		provider.attrs = append(provider.attrs, attribute{"Guid", channel.GUID})
+	}
+
+	system := &element{name: "System", children: []*element{
+		provider,
+		{name: "EventID", text: eventID},
+		{name: "Version", text: strconv.Itoa(channel.Version)},
+		{name: "Level", text: "4"},
+		{name: "Task", text: strconv.Itoa(channel.Task)},
+		{name: "Opcode", text: "0"},
+		{name: "Keywords", text: "0x8000000000000000"},
+		{name: "TimeCreated", attrs: []attribute{{"SystemTime", TimestampFormats["xml"](created)}}},
+		{name: "EventRecordID", text: strconv.Itoa(recordID)},
+		{name: "Correlation"},
+		{name: "Execution", attrs: []attribute{{"ProcessID", "4"}, {"ThreadID", "8"}}},
+		{name: "Channel", text: channel.Channel},
+		{name: "Computer", text: computer},
+		{name: "Security"},
+	}}
+
+	return &element{
+		name:     "Event",
+		attrs:    []attribute{{"xmlns", "http://schemas.microsoft.com/win/2004/08/events/event"}},
+		children: []*element{system, eventData},
+	}, nil
+}
+
+// element is a node of an XML document that can be rendered either as text or as binary XML.
+type element struct {
+	name     string
+	attrs    []attribute
+	text     string
+	children []*element
+}
+
+// attribute is a name-value pair of an XML element.
+type attribute struct {
+	name  string
+	value string
+}
+
+// writeXML writes the element as indented XML text.
+func (e *element) writeXML(w io.Writer, indent string) error {
+	var builder strings.Builder
+	builder.WriteString(indent + "<" + e.name)
+	for _, attr := range e.attrs {
+		builder.WriteString(" " + attr.name + "=\"")
+		xml.EscapeText(&builder, []byte(attr.value))
+		builder.WriteString("\"")
+	}
+
+	switch {
+	case len(e.children) > 0:
+		builder.WriteString(">\n")
+		if _, err := io.WriteString(w, builder.String()); err != nil {
+			return err
+		}
+		for _, child := range e.children {
+			if err := child.writeXML(w, indent+"  "); err != nil {
+				return err
+			}
+		}
+		_, err := io.WriteString(w, indent+"</"+e.name+">\n")
+		return err
+	case e.text != "":
+		builder.WriteString(">")
+		xml.EscapeText(&builder, []byte(e.text))
+		builder.WriteString("</" + e.name + ">\n")
+	default:
+		builder.WriteString(" />\n")
+	}
+
+	_, err := io.WriteString(w, builder.String())
+	return err
+}
+
+// coerceString converts a field value to its string representation.
+func coerceString(v interface{}) string {
+	switch vv := v.(type) {
+	case string:
+		return vv
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(vv)
+	}
+}
@@ -0,0 +1,259 @@
+package formatters
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"time"
+	"unicode/utf16"
+)
+
+// Sizes of the structures of an EVTX file
+const (
+	evtxFileHeaderSize  = 4096  // The size of the file header block
+	evtxChunkSize       = 65536 // The size of a chunk
+	evtxChunkHeaderSize = 512   // The size of the chunk header, including the string and template tables
+	evtxRecordOverhead  = 28    // The size of an event record without its binary XML
+)
+
+// Binary XML tokens used to encode events
+const (
+	binXMLEndOfStream      = 0x00
+	binXMLOpenStartElement = 0x01
+	binXMLCloseStartTag    = 0x02
+	binXMLCloseEmptyTag    = 0x03
+	binXMLEndElement       = 0x04
+	binXMLValue            = 0x05
+	binXMLAttribute        = 0x06
+	binXMLFragmentHeader   = 0x0f
+	binXMLHasMoreData      = 0x40
+	binXMLStringType       = 0x01
+)
+
+// WriteEVTX writes the records as a binary Windows XML Event Log (EVTX) file that can be opened by Windows tooling.
+// Events are encoded as plain binary XML, without templates, and split across as many chunks as needed.
+func WriteEVTX(w io.Writer, records []Record) error {
+	var chunks [][]byte
+	chunk := newEVTXChunk()
+	for i, record := range records {
+		created := timestamp(record)
+		event, err := newWindowsEvent(record, i+1, created)
+		if err != nil {
+			return err
+		}
+
+		// Start a new chunk if the event doesn't fit into the current one
+		recordID := uint64(i + 1)
+		if !chunk.add(recordID, created, event) {
+			chunks = append(chunks, chunk.bytes())
+			chunk = newEVTXChunk()
+			if !chunk.add(recordID, created, event) {
+				return fmt.Errorf("event %d is too large for an EVTX chunk", recordID)
+			}
+		}
+	}
+	if chunk.count > 0 || len(chunks) == 0 {
+		chunks = append(chunks, chunk.bytes())
+	}
+
+	// Write the file header followed by the chunks
+	header := make([]byte, evtxFileHeaderSize)
+	copy(header, "ElfFile\x00")
+	binary.LittleEndian.PutUint64(header[8:], 0)                       // first chunk number
+	binary.LittleEndian.PutUint64(header[16:], uint64(len(chunks)-1))  // last chunk number
+	binary.LittleEndian.PutUint64(header[24:], uint64(len(records)+1)) // next record identifier
+	binary.LittleEndian.PutUint32(header[32:], 128)                    // header size
+	binary.LittleEndian.PutUint16(header[36:], 1)                      // minor version
+	binary.LittleEndian.PutUint16(header[38:], 3)                      // major version
+	binary.LittleEndian.PutUint16(header[40:], evtxFileHeaderSize)     // header block size
+	binary.LittleEndian.PutUint16(header[42:], uint16(len(chunks)))    // number of chunks
+	binary.LittleEndian.PutUint32(header[124:], crc32.ChecksumIEEE(header[:120]))
+
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	for _, chunk := range chunks {
+		if _, err := w.Write(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// evtxChunk accumulates the event records of a single EVTX chunk.
+type evtxChunk struct {
+	data    []byte // The chunk, including its header
+	free    int    // The offset of the free space in the chunk
+	last    int    // The offset of the last event record in the chunk
+	firstID uint64 // The identifier of the first event record
+	lastID  uint64 // The identifier of the last event record
+	count   int    // The number of event records in the chunk
+}
+
+// newEVTXChunk creates an empty chunk.
+func newEVTXChunk() *evtxChunk {
+	return &evtxChunk{data: make([]byte, evtxChunkSize), free: evtxChunkHeaderSize}
+}
+
+// add appends an event record to the chunk and reports whether it fit.
+func (c *evtxChunk) add(recordID uint64, written time.Time, event *element) bool {
+	// Names are referenced by their offset in the chunk, so the event is encoded at its final position
+	encoder := &binXMLEncoder{base: c.free + 24}
+	encoder.fragment(event)
+	data := encoder.buf.Bytes()
+
+	size := evtxRecordOverhead + len(data)
+	if c.free+size > evtxChunkSize {
+		return false
+	}
+
+	record := c.data[c.free : c.free+size]
+	copy(record, "\x2a\x2a\x00\x00")
+	binary.LittleEndian.PutUint32(record[4:], uint32(size))
+	binary.LittleEndian.PutUint64(record[8:], recordID)
+	binary.LittleEndian.PutUint64(record[16:], filetime(written))
+	copy(record[24:], data)
+	binary.LittleEndian.PutUint32(record[size-4:], uint32(size))
+
+	if c.count == 0 {
+		c.firstID = recordID
+	}
+	c.lastID = recordID
+	c.last = c.free
+	c.free += size
+	c.count++
+	return true
+}
+
+// bytes fills in the chunk header and returns the complete chunk.
+func (c *evtxChunk) bytes() []byte {
+	header := c.data[:evtxChunkHeaderSize]
+	copy(header, "ElfChnk\x00")
+	binary.LittleEndian.PutUint64(header[8:], c.firstID)  // first event record number
+	binary.LittleEndian.PutUint64(header[16:], c.lastID)  // last event record number
+	binary.LittleEndian.PutUint64(header[24:], c.firstID) // first event record identifier
+	binary.LittleEndian.PutUint64(header[32:], c.lastID)  // last event record identifier
+	binary.LittleEndian.PutUint32(header[40:], 128)       // header size
+	binary.LittleEndian.PutUint32(header[44:], uint32(c.last))
+	binary.LittleEndian.PutUint32(header[48:], uint32(c.free))
+	binary.LittleEndian.PutUint32(header[52:], crc32.ChecksumIEEE(c.data[evtxChunkHeaderSize:c.free]))
+
+	// The header checksum covers the header without the checksum fields, and the string and template tables
+	checksum := crc32.NewIEEE()
+	checksum.Write(header[:120])
+	checksum.Write(header[128:evtxChunkHeaderSize])
+	binary.LittleEndian.PutUint32(header[124:], checksum.Sum32())
+
+	return c.data
+}
+
+// binXMLEncoder encodes elements as binary XML.
+// Element and attribute names are always stored inline, right after the offset that references them.
+type binXMLEncoder struct {
+	buf  bytes.Buffer
+	base int // The offset of the start of the buffer in the chunk
+}
+
+// fragment encodes the element as a complete binary XML fragment.
+func (e *binXMLEncoder) fragment(root *element) {
+	e.buf.Write([]byte{binXMLFragmentHeader, 1, 1, 0})
+	e.element(root)
+	e.buf.WriteByte(binXMLEndOfStream)
+}
+
+// element encodes an element, its attributes and its content.
+func (e *binXMLEncoder) element(el *element) {
+	token := byte(binXMLOpenStartElement)
+	if len(el.attrs) > 0 {
+		token |= binXMLHasMoreData
+	}
+	e.buf.WriteByte(token)
+	e.uint16(0xffff) // dependency identifier
+	sizeOffset := e.buf.Len()
+	e.uint32(0) // data size, filled in below
+	e.name(el.name)
+
+	if len(el.attrs) > 0 {
+		attrsOffset := e.buf.Len()
+		e.uint32(0) // attribute list size, filled in below
+		for i, attr := range el.attrs {
+			token := byte(binXMLAttribute)
+			if i < len(el.attrs)-1 {
+				token |= binXMLHasMoreData
+			}
+			e.buf.WriteByte(token)
+			e.name(attr.name)
+			e.value(attr.value)
+		}
+		e.patch(attrsOffset, e.buf.Len()-attrsOffset-4)
+	}
+
+	if len(el.children) == 0 && el.text == "" {
+		e.buf.WriteByte(binXMLCloseEmptyTag)
+	} else {
+		e.buf.WriteByte(binXMLCloseStartTag)
+		for _, child := range el.children {
+			e.element(child)
+		}
+		if el.text != "" {
+			e.value(el.text)
+		}
+		e.buf.WriteByte(binXMLEndElement)
+	}
+
+	e.patch(sizeOffset, e.buf.Len()-sizeOffset-4)
+}
+
+// name encodes the offset of a name followed by the name itself.
+func (e *binXMLEncoder) name(name string) {
+	chars := utf16.Encode([]rune(name))
+	e.uint32(uint32(e.base + e.buf.Len() + 4))
+	e.uint32(0) // offset of the next name with the same hash
+	e.uint16(nameHash(chars))
+	e.uint16(uint16(len(chars)))
+	for _, char := range chars {
+		e.uint16(char)
+	}
+	e.uint16(0)
+}
+
+// value encodes a string value.
+func (e *binXMLEncoder) value(value string) {
+	chars := utf16.Encode([]rune(value))
+	e.buf.Write([]byte{binXMLValue, binXMLStringType})
+	e.uint16(uint16(len(chars)))
+	for _, char := range chars {
+		e.uint16(char)
+	}
+}
+
+// uint16 appends a little-endian 16-bit integer.
+func (e *binXMLEncoder) uint16(v uint16) {
+	e.buf.Write([]byte{byte(v), byte(v >> 8)})
+}
+
+// uint32 appends a little-endian 32-bit integer.
+func (e *binXMLEncoder) uint32(v uint32) {
+	e.buf.Write([]byte{byte(v), byte(v >> 8), byte(v >> 16), byte(v >> 24)})
+}
+
+// patch overwrites the 32-bit integer at the given position in the buffer.
+func (e *binXMLEncoder) patch(position int, v int) {
+	binary.LittleEndian.PutUint32(e.buf.Bytes()[position:], uint32(v))
+}
+
+// nameHash computes the hash stored alongside binary XML names.
+func nameHash(chars []uint16) uint16 {
+	var hash uint32
+	for _, char := range chars {
+		hash = hash*65599 + uint32(char)
+	}
+	return uint16(hash)
+}
+
+// filetime converts a time to a Windows FILETIME, the number of 100ns intervals since January 1, 1601.
+func filetime(t time.Time) uint64 {
+	return uint64(t.UnixNano()/100) + 116444736000000000
+}
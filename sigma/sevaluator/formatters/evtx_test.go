@@ -0,0 +1,174 @@
+package formatters
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"hash/crc32"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf16"
+
+	"github.com/mtnmunuklu/logen/sigma"
+)
+
+var windowsRecords = []Record{
+	{
+		Logsource: sigma.Logsource{Product: "windows", Category: "process_creation"},
+		Fields:    map[string]interface{}{"CommandLine": `cmd.exe /c "whoami" & exit`, "Image": `C:\Windows\System32\cmd.exe`},
+	},
+	{
+		Logsource: sigma.Logsource{Product: "windows", Category: "process_creation", Service: "security"},
+		Fields:    map[string]interface{}{"NewProcessName": `C:\Windows\System32\cmd.exe`},
+	},
+}
+
+// TestWriteEventXML checks that the channel and event ID are derived from the logsource.
+func TestWriteEventXML(t *testing.T) {
+	var output strings.Builder
+	if err := WriteEventXML(&output, windowsRecords); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{
+		"<EventID>1</EventID>",
+		"<Channel>Microsoft-Windows-Sysmon/Operational</Channel>",
+		`<Data Name="CommandLine">cmd.exe /c &#34;whoami&#34; &amp; exit</Data>`,
+		"<EventID>4688</EventID>",
+		"<Channel>Security</Channel>",
+	} {
+		if !strings.Contains(output.String(), expected) {
+			t.Errorf("expected %s in output:\n%s", expected, output.String())
+		}
+	}
+
+	if err := WriteEventXML(&output, []Record{{Logsource: sigma.Logsource{Product: "linux"}}}); err == nil {
+		t.Errorf("expected an error for a linux logsource")
+	}
+}
+
+// TestWriteEVTX checks the checksums of the binary event log and that every event decodes back to its XML.
+func TestWriteEVTX(t *testing.T) {
+	var output bytes.Buffer
+	if err := WriteEVTX(&output, windowsRecords); err != nil {
+		t.Fatal(err)
+	}
+	data := output.Bytes()
+
+	if len(data) != evtxFileHeaderSize+evtxChunkSize {
+		t.Fatalf("expected a single chunk, got %d bytes", len(data))
+	}
+	if !bytes.HasPrefix(data, []byte("ElfFile\x00")) || binary.LittleEndian.Uint32(data[124:]) != crc32.ChecksumIEEE(data[:120]) {
+		t.Fatalf("invalid file header")
+	}
+
+	chunk := data[evtxFileHeaderSize:]
+	free := binary.LittleEndian.Uint32(chunk[48:])
+	if !bytes.HasPrefix(chunk, []byte("ElfChnk\x00")) || binary.LittleEndian.Uint32(chunk[52:]) != crc32.ChecksumIEEE(chunk[evtxChunkHeaderSize:free]) {
+		t.Fatalf("invalid chunk header")
+	}
+	checksum := crc32.NewIEEE()
+	checksum.Write(chunk[:120])
+	checksum.Write(chunk[128:evtxChunkHeaderSize])
+	if binary.LittleEndian.Uint32(chunk[124:]) != checksum.Sum32() {
+		t.Fatalf("invalid chunk header checksum")
+	}
+
+	// Decode every record and compare it with the XML rendering of the same record
+	offset := uint32(evtxChunkHeaderSize)
+	for i, record := range windowsRecords {
+		if !bytes.HasPrefix(chunk[offset:], []byte("\x2a\x2a\x00\x00")) {
+			t.Fatalf("invalid signature of record %d", i)
+		}
+		size := binary.LittleEndian.Uint32(chunk[offset+4:])
+		created := binary.LittleEndian.Uint64(chunk[offset+16:])
+
+		decoder := &binXMLDecoder{chunk: chunk, position: int(offset) + 28}
+		var decoded strings.Builder
+		decoder.element(&decoded, "")
+
+		event, err := newWindowsEvent(record, i+1, filetimeToTime(created))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var expected strings.Builder
+		event.writeXML(&expected, "")
+		if decoded.String() != expected.String() {
+			t.Errorf("decoded record %d:\n%s\nnot equal to:\n%s", i, decoded.String(), expected.String())
+		}
+		offset += size
+	}
+	if offset != free {
+		t.Errorf("expected free space at %d, got %d", offset, free)
+	}
+}
+
+// binXMLDecoder decodes the subset of binary XML written by binXMLEncoder back to indented XML text.
+type binXMLDecoder struct {
+	chunk    []byte
+	position int
+}
+
+func (d *binXMLDecoder) element(w *strings.Builder, indent string) {
+	token := d.chunk[d.position]
+	d.position += 7 // token, dependency identifier and data size
+	name := d.name()
+	w.WriteString(indent + "<" + name)
+	if token&binXMLHasMoreData != 0 {
+		d.position += 4 // attribute list size
+		for more := true; more; {
+			more = d.chunk[d.position]&binXMLHasMoreData != 0
+			d.position++
+			w.WriteString(" " + d.name() + "=\"")
+			xml.EscapeText(w, []byte(d.value()))
+			w.WriteString("\"")
+		}
+	}
+
+	if d.chunk[d.position] == binXMLCloseEmptyTag {
+		d.position++
+		w.WriteString(" />\n")
+		return
+	}
+	d.position++ // close start tag
+	if d.chunk[d.position] == binXMLValue {
+		w.WriteString(">")
+		xml.EscapeText(w, []byte(d.value()))
+	} else {
+		w.WriteString(">\n")
+		for d.chunk[d.position] != binXMLEndElement {
+			d.element(w, indent+"  ")
+		}
+		w.WriteString(indent)
+	}
+	d.position++ // end element
+	w.WriteString("</" + name + ">\n")
+}
+
+func (d *binXMLDecoder) name() string {
+	offset := int(binary.LittleEndian.Uint32(d.chunk[d.position:]))
+	length := int(binary.LittleEndian.Uint16(d.chunk[offset+6:]))
+	d.position = offset + 8 + 2*length + 2
+	return d.utf16(offset+8, length)
+}
+
+func (d *binXMLDecoder) value() string {
+	length := int(binary.LittleEndian.Uint16(d.chunk[d.position+2:]))
+	value := d.utf16(d.position+4, length)
+	d.position += 4 + 2*length
+	return value
+}
+
+func (d *binXMLDecoder) utf16(offset, length int) string {
+	chars := make([]uint16, length)
+	for i := range chars {
+		chars[i] = binary.LittleEndian.Uint16(d.chunk[offset+2*i:])
+	}
+	return string(utf16.Decode(chars))
+}
+
+// filetimeToTime converts a Windows FILETIME back to a time.
+func filetimeToTime(filetime uint64) time.Time {
+	return time.Unix(0, int64(filetime-116444736000000000)*100)
+}
@@ -0,0 +1,146 @@
+package formatters
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/mtnmunuklu/logen/sigma"
+)
+
+// Formatter renders records in the native format of a log source.
+type Formatter interface {
+	Format(w io.Writer, records []Record) error // Format writes the records to w
+	Extension() string                          // Extension returns the extension of the files the records are written to
+	Description() string                        // Description returns a human readable name of the format
+}
+
+// formatter implements Formatter with a write function, a file extension and a description.
+type formatter struct {
+	write       func(w io.Writer, records []Record) error
+	extension   string
+	description string
+}
+
+// Format writes the records to w.
+func (f formatter) Format(w io.Writer, records []Record) error {
+	return f.write(w, records)
+}
+
+// Extension returns the extension of the files the records are written to.
+func (f formatter) Extension() string {
+	return f.extension
+}
+
+// Description returns a human readable name of the format.
+func (f formatter) Description() string {
+	return f.description
+}
+
+// Formatters maps the names of the supported output formats to their Formatter.
+var Formatters = map[string]Formatter{
+	"jsonl":      formatter{WriteJSONLines, ".jsonl", "JSON Lines"},
+	"json":       formatter{WriteJSON, ".json", "JSON"},
+	"xml":        formatter{WriteEventXML, ".xml", "Windows Event XML"},
+	"evtx":       formatter{WriteEVTX, ".evtx", "binary Windows XML Event Log (EVTX)"},
+	"ecs":        formatter{WriteECS, ".ndjson", "Elastic Common Schema (ECS) JSON"},
+	"cef":        formatter{WriteCEF, ".cef", "ArcSight Common Event Format (CEF)"},
+	"leef":       formatter{WriteLEEF, ".leef", "IBM QRadar Log Event Extended Format (LEEF)"},
+	"syslog":     formatter{WriteSyslog, ".log", "RFC 5424 syslog"},
+	"syslog3164": formatter{WriteSyslog3164, ".log", "RFC 3164 (BSD) syslog"},
+	"auditd":     formatter{WriteAuditd, ".log", "Linux auditd"},
+	"zeek":       formatter{WriteZeek, ".log", "Zeek TSV"},
+	"csv":        formatter{WriteCSV, ".csv", "CSV"},
+}
+
+// BinaryFormats lists the output formats that aren't text, which can only be written to files.
+var BinaryFormats = map[string]bool{
+	"evtx": true,
+}
+
+// FormatMapping selects an output format for the rules whose logsource matches it.
+// Empty fields of the mapping match any value.
+type FormatMapping struct {
+	Product  string // The product the logsource must have
+	Service  string // The service the logsource must have
+	Category string // The category the logsource must have
+	Format   string // The name of the output format
+}
+
+// LogsourceFormats lists the native output format of each logsource, the first matching mapping is used.
+var LogsourceFormats = []FormatMapping{
+	{Product: "windows", Format: "xml"},
+	{Product: "linux", Service: "auditd", Format: "auditd"},
+	{Product: "linux", Format: "syslog"},
+	{Product: "zeek", Format: "zeek"},
+	{Category: "firewall", Format: "cef"},
+	{Category: "proxy", Format: "leef"},
+	{Category: "webserver", Format: "csv"},
+}
+
+// DefaultFormat is the output format of logsources that don't match any of LogsourceFormats.
+const DefaultFormat = "ecs"
+
+// Lookup returns the Formatter registered under the given name.
+func Lookup(name string) (Formatter, error) {
+	formatter, ok := Formatters[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown output format %s", name)
+	}
+	return formatter, nil
+}
+
+// Describe returns the description of the output format registered under the given name, or the name itself if there is none.
+func Describe(name string) string {
+	if formatter, ok := Formatters[name]; ok {
+		return formatter.Description()
+	}
+	return name
+}
+
+// FormatForLogsource returns the name of the native output format of a logsource.
+func FormatForLogsource(logsource sigma.Logsource) string {
+	for _, mapping := range LogsourceFormats {
+		switch {
+		case mapping.Product != "" && !strings.EqualFold(mapping.Product, logsource.Product):
+			continue
+		case mapping.Service != "" && !strings.EqualFold(mapping.Service, logsource.Service):
+			continue
+		case mapping.Category != "" && !strings.EqualFold(mapping.Category, logsource.Category):
+			continue
+		}
+		return mapping.Format
+	}
+	return DefaultFormat
+}
+
+// FormatForRule returns the name of the output format of a rule.
+// Rules can select a format with the custom "format" attribute, otherwise the native format of their logsource is used.
+func FormatForRule(rule sigma.Rule) string {
+	if format, ok := rule.AdditionalFields["format"].(string); ok && format != "" {
+		return format
+	}
+	return FormatForLogsource(rule.Logsource)
+}
+
+// fieldNames returns the sorted names of the fields of all records.
+func fieldNames(records []Record) []string {
+	seen := map[string]bool{}
+	var names []string
+	for _, record := range records {
+		for name := range record.Fields {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// sortedFields returns the sorted names of the fields of a record.
+func sortedFields(record Record) []string {
+	return fieldNames([]Record{record})
+}
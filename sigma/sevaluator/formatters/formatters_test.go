@@ -0,0 +1,121 @@
+package formatters
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mtnmunuklu/logen/sigma"
+)
+
+var testTime = time.Date(2023, time.March, 4, 5, 6, 7, 0, time.UTC)
+
+// TestFormatForRule checks that rules are rendered in the native format of their logsource unless they select one.
+func TestFormatForRule(t *testing.T) {
+	tt := []struct {
+		Rule   sigma.Rule
+		Format string
+	}{
+		{sigma.Rule{Logsource: sigma.Logsource{Product: "Windows", Category: "process_creation"}}, "xml"},
+		{sigma.Rule{Logsource: sigma.Logsource{Product: "linux", Service: "auditd"}}, "auditd"},
+		{sigma.Rule{Logsource: sigma.Logsource{Product: "linux", Category: "process_creation"}}, "syslog"},
+		{sigma.Rule{Logsource: sigma.Logsource{Product: "zeek", Service: "dns"}}, "zeek"},
+		{sigma.Rule{Logsource: sigma.Logsource{Category: "proxy"}}, "leef"},
+		{sigma.Rule{Logsource: sigma.Logsource{Product: "aws", Service: "cloudtrail"}}, DefaultFormat},
+		{sigma.Rule{Logsource: sigma.Logsource{Product: "windows"}, AdditionalFields: map[string]interface{}{"format": "cef"}}, "cef"},
+	}
+
+	for _, tc := range tt {
+		if format := FormatForRule(tc.Rule); format != tc.Format {
+			t.Errorf("expected format %s for %+v, got %s", tc.Format, tc.Rule.Logsource, format)
+		}
+		if _, err := Lookup(tc.Format); err != nil {
+			t.Error(err)
+		}
+	}
+
+	if _, err := Lookup("evtx2"); err == nil {
+		t.Errorf("expected an error for an unknown format")
+	}
+
+	// Every native format is registered with a description
+	for _, mapping := range append(LogsourceFormats, FormatMapping{Format: DefaultFormat}) {
+		if Describe(mapping.Format) == mapping.Format {
+			t.Errorf("expected a description of format %s", mapping.Format)
+		}
+	}
+}
+
+// TestFormatters checks the rendering of a record in each of the text formats.
+func TestFormatters(t *testing.T) {
+	records := []Record{{
+		RuleID:    "5f1abf38",
+		Title:     "Suspicious | Command",
+		Level:     "high",
+		Timestamp: testTime,
+		Logsource: sigma.Logsource{Product: "linux", Service: "auditd"},
+		Fields: map[string]interface{}{
+			"type":            "EXECVE",
+			"a0":              "sh",
+			"a1":              `-c "id"`,
+			"CommandLine":     "a=b\\c",
+			"DestinationPort": 443,
+		},
+	}}
+
+	tt := []struct {
+		Format   string
+		Expected []string
+	}{
+		{"ecs", []string{`"@timestamp":"2023-03-04T05:06:07Z"`, `"process":{"command_line":"a=b\\c"}`, `"destination":{"port":443}`, `"rule":{"id":"5f1abf38"`}},
+		{"cef", []string{`CEF:0|Logen|linux|1.0|5f1abf38|Suspicious \| Command|8|rt=1677906367000`, `CommandLine=a\=b\\c`, `a1=-c "id"`}},
+		{"leef", []string{"LEEF:1.0|Logen|linux|1.0|5f1abf38|devTime=Mar 04 2023 05:06:07", "\tsev=8\t", "\ta1=-c \"id\"\t"}},
+		{"syslog", []string{`<11>1 2023-03-04T05:06:07.000000Z logen linux - 5f1abf38 [logen@32473 CommandLine="a=b\\c" DestinationPort="443" a0="sh" a1="-c \"id\"" type="EXECVE"]`}},
+		{"syslog3164", []string{`<11>Mar  4 05:06:07 logen linux: CommandLine="a=b\\c" DestinationPort=443 a0=sh a1="-c \"id\"" type=EXECVE`}},
+		{"auditd", []string{`type=EXECVE msg=audit(1677906367.000:1): CommandLine="a=b\\c" DestinationPort=443 a0=sh a1="-c \"id\""`}},
+		{"zeek", []string{"#path\tauditd", "#fields\tts\tCommandLine\tDestinationPort\ta0\ta1\ttype", "#types\ttime\tstring\tcount\tstring\tstring\tstring", "1677906367.000000\ta=b\\\\c\t443\tsh\t-c \"id\"\tEXECVE"}},
+		{"csv", []string{"timestamp,rule_id,title,CommandLine,DestinationPort,a0,a1,type\n", `2023-03-04T05:06:07Z,5f1abf38,Suspicious | Command,a=b\c,443,sh,"-c ""id""",EXECVE`}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Format, func(t *testing.T) {
+			formatter, err := Lookup(tc.Format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var output strings.Builder
+			if err := formatter.Format(&output, records); err != nil {
+				t.Fatal(err)
+			}
+			for _, expected := range tc.Expected {
+				if !strings.Contains(output.String(), expected) {
+					t.Errorf("expected %s in output:\n%s", expected, output.String())
+				}
+			}
+		})
+	}
+}
+
+// TestTimestampFormats checks that textual timestamps keep the time zone of the record, unless the format requires UTC.
+func TestTimestampFormats(t *testing.T) {
+	record := Record{Timestamp: testTime.In(time.FixedZone("+03", 3*60*60))}
+
+	tt := []struct {
+		Format    string
+		Timestamp string
+	}{
+		{"ecs", "2023-03-04T08:06:07+03:00"},
+		{"syslog", "2023-03-04T08:06:07.000000+03:00"},
+		{"syslog3164", "Mar  4 08:06:07"},
+		{"leef", "Mar 04 2023 08:06:07.000 +03"},
+		{"cef", "1677906367000"},
+		{"zeek", "1677906367.000000"},
+		{"xml", "2023-03-04T05:06:07.0000000Z"},
+	}
+
+	for _, tc := range tt {
+		if timestamp := formatTimestamp(tc.Format, record); timestamp != tc.Timestamp {
+			t.Errorf("expected %s timestamp %s, got %s", tc.Format, tc.Timestamp, timestamp)
+		}
+	}
+}
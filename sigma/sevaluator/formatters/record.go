@@ -0,0 +1,177 @@
+package formatters
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mtnmunuklu/logen/sigma"
+	"github.com/mtnmunuklu/logen/sigma/sevaluator"
+)
+
+// Record is a structured synthetic log generated for a condition of a Sigma rule.
+type Record struct {
+	RuleID     string                 `json:"rule_id"`            // The ID of the rule the record was generated for
+	Title      string                 `json:"title"`              // The title of the rule the record was generated for
+	Level      string                 `json:"level,omitempty"`    // The level of the rule the record was generated for
+	Condition  int                    `json:"condition"`          // The index of the condition that the record satisfies
+	SourceType string                 `json:"source_type"`        // The source type of the record, computed from the rule's logsource
+	Query      string                 `json:"query"`              // The query that the record satisfies, or only just misses if the record is negative
+	Negative   bool                   `json:"negative,omitempty"` // Whether the record is a near-miss sample that must not trigger the rule
+	Timestamp  time.Time              `json:"timestamp"`          // The time at which the record was logged
+	Fields     map[string]interface{} `json:"fields"`             // The map of event field names to their values
+
+	Logsource sigma.Logsource `json:"-"` // The logsource of the rule, used to choose how the record is rendered
+}
+
+// NewRecords creates a Record for each synthetic event in the result, ordered by condition index.
+// The negative samples of each condition follow its positive ones.
+// The rule should be the one held by the RuleEvaluator, so that logsource rewrites from the config are taken into account.
+func NewRecords(rule sigma.Rule, result sevaluator.Result) []Record {
+	conditions := make([]int, 0, len(result.Events))
+	for condition := range result.Events {
+		conditions = append(conditions, condition)
+	}
+	sort.Ints(conditions)
+
+	var records []Record
+	for _, condition := range conditions {
+		for _, negative := range []bool{false, true} {
+			events := result.Events[condition]
+			if negative {
+				events = result.Negatives[condition]
+			}
+			for _, event := range events {
+				records = append(records, Record{
+					RuleID:     rule.ID,
+					Title:      rule.Title,
+					Level:      rule.Level,
+					Condition:  condition,
+					SourceType: result.SourceTypes[condition],
+					Query:      result.Queries[condition],
+					Negative:   negative,
+					Timestamp:  event.Timestamp,
+					Fields:     event.Fields,
+					Logsource:  rule.Logsource,
+				})
+			}
+		}
+	}
+	return records
+}
+
+// NewCorrelationRecords creates a Record for each event of the sequence that triggers a correlation, in the order they are logged.
+// Every record is attributed to the referenced rule that its event matches, so that it is rendered in the format of that rule's logsource.
+func NewCorrelationRecords(evaluator *sevaluator.CorrelationEvaluator, result sevaluator.CorrelationResult) []Record {
+	rules := evaluator.Rules()
+	records := make([]Record, 0, len(result.Events))
+	for _, event := range result.Events {
+		rule := rules[event.Rule].Rule
+		records = append(records, Record{
+			RuleID:     rule.ID,
+			Title:      rule.Title,
+			Level:      rule.Level,
+			SourceType: result.SourceTypes[event.Rule],
+			Query:      result.Queries[event.Rule],
+			Timestamp:  event.Timestamp,
+			Fields:     event.Fields,
+			Logsource:  rule.Logsource,
+		})
+	}
+	return records
+}
+
+// timestamp returns the time at which a record was logged, records without a timestamp are logged now.
+func timestamp(record Record) time.Time {
+	if record.Timestamp.IsZero() {
+		return time.Now()
+	}
+	return record.Timestamp
+}
+
+// TimestampFormats maps the names of the output formats to the functions that render the timestamps of their records.
+// Textual timestamps are rendered in the time zone of the record, unless the format requires UTC.
+var TimestampFormats = map[string]func(t time.Time) string{
+	"ecs":        func(t time.Time) string { return t.Format(time.RFC3339Nano) },
+	"csv":        func(t time.Time) string { return t.Format(time.RFC3339Nano) },
+	"syslog":     func(t time.Time) string { return t.Format("2006-01-02T15:04:05.000000Z07:00") },
+	"syslog3164": func(t time.Time) string { return t.Format(time.Stamp) },
+	"cef":        func(t time.Time) string { return strconv.FormatInt(t.UnixMilli(), 10) },
+	"leef":       func(t time.Time) string { return t.Format("Jan 02 2006 15:04:05.000 MST") },
+	"auditd":     func(t time.Time) string { return fmt.Sprintf("%d.%03d", t.Unix(), t.Nanosecond()/1e6) },
+	"zeek":       func(t time.Time) string { return fmt.Sprintf("%d.%06d", t.Unix(), t.Nanosecond()/1e3) },
+	"xml":        func(t time.Time) string { return t.UTC().Format("2006-01-02T15:04:05.0000000Z") },
+}
+
+// formatTimestamp renders the time at which a record was logged as required by the given output format.
+func formatTimestamp(format string, record Record) string {
+	return TimestampFormats[format](timestamp(record))
+}
+
+// WriteJSONLines writes the records as JSON Lines, one record per line.
+func WriteJSONLines(w io.Writer, records []Record) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteJSON writes the records as a single indented JSON array.
+func WriteJSON(w io.Writer, records []Record) error {
+	if records == nil {
+		records = []Record{}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(records)
+}
+
+// ParseFields coerces a free-text LLM response into a map of event field names to values.
+// The response must contain a JSON object, optionally wrapped in prose or a markdown code block.
+// If the object has a "fields" member holding an object, that member is used instead.
+// Nested objects are flattened into dotted field names, e.g. {"process": {"pid": 1}} becomes {"process.pid": 1}.
+func ParseFields(response string) (map[string]interface{}, error) {
+	// Extract the outermost JSON object from the response
+	start := strings.Index(response, "{")
+	end := strings.LastIndex(response, "}")
+	if start == -1 || end < start {
+		return nil, fmt.Errorf("response doesn't contain a JSON object")
+	}
+
+	var object map[string]interface{}
+	if err := json.Unmarshal([]byte(response[start:end+1]), &object); err != nil {
+		return nil, fmt.Errorf("response doesn't contain a valid JSON object: %w", err)
+	}
+
+	// Unwrap responses that mirror the structure of a Record
+	if fields, ok := object["fields"].(map[string]interface{}); ok {
+		object = fields
+	}
+
+	fields := map[string]interface{}{}
+	flattenFields("", object, fields)
+	return fields, nil
+}
+
+// flattenFields copies the members of object into fields, flattening nested objects into dotted field names.
+func flattenFields(prefix string, object map[string]interface{}, fields map[string]interface{}) {
+	for name, value := range object {
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+		if nested, ok := value.(map[string]interface{}); ok {
+			flattenFields(name, nested, fields)
+		} else {
+			fields[name] = value
+		}
+	}
+}
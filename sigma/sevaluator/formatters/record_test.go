@@ -0,0 +1,33 @@
+package formatters
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestParseFields checks that LLM responses are coerced into flat event fields.
+func TestParseFields(t *testing.T) {
+	tt := []struct {
+		response string
+		fields   map[string]interface{}
+	}{
+		{`{"Image": "C:\\a.exe", "EventID": 1}`, map[string]interface{}{"Image": `C:\a.exe`, "EventID": float64(1)}},
+		{"Here is the log:\n```json\n{\"Image\": \"a.exe\"}\n```", map[string]interface{}{"Image": "a.exe"}},
+		{`{"title": "x", "fields": {"Image": "a.exe"}}`, map[string]interface{}{"Image": "a.exe"}},
+		{`{"process": {"pid": 4, "name": "a.exe"}}`, map[string]interface{}{"process.pid": float64(4), "process.name": "a.exe"}},
+	}
+
+	for _, tc := range tt {
+		fields, err := ParseFields(tc.response)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(fields, tc.fields) {
+			t.Errorf("%+v not equal %+v", fields, tc.fields)
+		}
+	}
+
+	if _, err := ParseFields("<Event></Event>"); err == nil {
+		t.Errorf("expected an error for a response without a JSON object")
+	}
+}
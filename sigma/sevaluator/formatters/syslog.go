@@ -0,0 +1,185 @@
+package formatters
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// SyslogFacility is the facility of the syslog messages, user-level messages.
+const SyslogFacility = 1
+
+// SyslogSeverities maps Sigma rule levels to syslog severities.
+var SyslogSeverities = map[string]int{
+	"informational": 6,
+	"low":           5,
+	"medium":        4,
+	"high":          3,
+	"critical":      2,
+}
+
+// SyslogEnterpriseID is the private enterprise number of the structured data element holding the fields of a record.
+const SyslogEnterpriseID = "32473"
+
+// Escaping rules of the syslog and auditd formats
+var (
+	syslogParamEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+	syslogLineEscaper  = strings.NewReplacer("\n", " ", "\r", " ")
+)
+
+// WriteSyslog writes the records as RFC 5424 syslog messages, one message per line.
+// Fields become parameters of a structured data element, a "message" field becomes the free-form message.
+func WriteSyslog(w io.Writer, records []Record) error {
+	for _, record := range records {
+		var data strings.Builder
+		data.WriteString("[logen@" + SyslogEnterpriseID)
+		for _, name := range sortedFields(record) {
+			data.WriteString(" " + syslogParamName(name) + "=\"" + syslogParamEscaper.Replace(coerceString(record.Fields[name])) + "\"")
+		}
+		data.WriteString("]")
+
+		message := syslogMessage(record)
+		if message != "" {
+			message = " " + message
+		}
+
+		if _, err := fmt.Fprintf(w, "<%d>1 %s %s %s - %s %s%s\n",
+			syslogPriority(record),
+			formatTimestamp("syslog", record),
+			syslogHostname(record),
+			syslogAppName(record),
+			syslogHeaderField(signatureID(record), 32),
+			data.String(),
+			message,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteSyslog3164 writes the records as BSD (RFC 3164) syslog messages, one message per line.
+// A "message" field becomes the content of the message, otherwise the fields are written as key=value pairs.
+func WriteSyslog3164(w io.Writer, records []Record) error {
+	for _, record := range records {
+		content := syslogMessage(record)
+		if content == "" {
+			content = keyValues(record, nil)
+		}
+
+		if _, err := fmt.Fprintf(w, "<%d>%s %s %s: %s\n",
+			syslogPriority(record),
+			formatTimestamp("syslog3164", record),
+			syslogHostname(record),
+			syslogAppName(record),
+			content,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteAuditd writes the records as Linux audit daemon log lines, one line per record.
+// The "type" field selects the record type, SYSCALL by default, and all other fields are written as key=value pairs.
+func WriteAuditd(w io.Writer, records []Record) error {
+	for i, record := range records {
+		recordType := "SYSCALL"
+		if value, ok := record.Fields["type"]; ok {
+			recordType = coerceString(value)
+		}
+
+		line := fmt.Sprintf("type=%s msg=audit(%s:%d):", recordType, formatTimestamp("auditd", record), i+1)
+		if fields := keyValues(record, map[string]bool{"type": true}); fields != "" {
+			line += " " + fields
+		}
+
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// keyValues renders the fields of a record as space separated key=value pairs, quoting values that contain spaces.
+func keyValues(record Record, skip map[string]bool) string {
+	var pairs []string
+	for _, name := range sortedFields(record) {
+		if skip[name] {
+			continue
+		}
+		value := syslogLineEscaper.Replace(coerceString(record.Fields[name]))
+		if _, ok := record.Fields[name].(string); ok && (value == "" || strings.ContainsAny(value, " \"=")) {
+			value = strconv.Quote(value)
+		}
+		pairs = append(pairs, cefKey(name)+"="+value)
+	}
+	return strings.Join(pairs, " ")
+}
+
+// syslogPriority returns the priority of the syslog message of a record, computed from the level of its rule.
+func syslogPriority(record Record) int {
+	severity, ok := SyslogSeverities[strings.ToLower(record.Level)]
+	if !ok {
+		severity = SyslogSeverities["medium"]
+	}
+	return SyslogFacility*8 + severity
+}
+
+// syslogHostname returns the host that logged a record, taken from its host name fields.
+func syslogHostname(record Record) string {
+	for _, name := range []string{"hostname", "host", "Computer", "host.name"} {
+		if value, ok := record.Fields[name]; ok && coerceString(value) != "" {
+			return syslogHeaderField(coerceString(value), 255)
+		}
+	}
+	return "logen"
+}
+
+// syslogAppName returns the application that logged a record, taken from its program fields or logsource.
+func syslogAppName(record Record) string {
+	for _, name := range []string{"program", "app", "process.name"} {
+		if value, ok := record.Fields[name]; ok && coerceString(value) != "" {
+			return syslogHeaderField(coerceString(value), 48)
+		}
+	}
+	return syslogHeaderField(deviceProduct(record), 48)
+}
+
+// syslogMessage returns the free-form message of a record, taken from its message field.
+func syslogMessage(record Record) string {
+	for _, name := range []string{"message", "Message", "msg"} {
+		if value, ok := record.Fields[name]; ok {
+			return syslogLineEscaper.Replace(coerceString(value))
+		}
+	}
+	return ""
+}
+
+// syslogHeaderField converts a value to a syslog header field, which is printable ASCII without spaces and limited in length.
+func syslogHeaderField(value string, length int) string {
+	value = strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' {
+			return '_'
+		}
+		return r
+	}, value)
+	if value == "" {
+		return "-"
+	}
+	if len(value) > length {
+		value = value[:length]
+	}
+	return value
+}
+
+// syslogParamName converts a field name to a structured data parameter name, which can't contain spaces, '=', ']' or '"'.
+func syslogParamName(name string) string {
+	return syslogHeaderField(strings.Map(func(r rune) rune {
+		if r == '=' || r == ']' || r == '"' {
+			return '_'
+		}
+		return r
+	}, name), 32)
+}
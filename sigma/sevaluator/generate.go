@@ -0,0 +1,323 @@
+package sevaluator
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/mtnmunuklu/logen/sigma"
+	"github.com/mtnmunuklu/logen/sigma/sevaluator/modifiers"
+)
+
+// Event represents a synthetic log event generated from a Sigma rule.
+type Event struct {
+	Fields    map[string]interface{} // The map of event field names to their synthetic values
+	Timestamp time.Time              // The time at which the event was logged, assigned by the Timeline of the RuleEvaluator
+}
+
+// eventBuilder collects the constraints that a synthetic event has to satisfy.
+// Fields are kept in the order in which they were first constrained so that values are generated in a stable order.
+type eventBuilder struct {
+	fields      []string                          // The event fields in the order they were first constrained
+	constraints map[string][]modifiers.Constraint // The constraints collected for each event field
+	generator   *modifiers.SyntheticDataGenerator // The generator that the values of the fields are drawn from
+}
+
+// newEventBuilder creates an empty eventBuilder that draws values from the given generator.
+func newEventBuilder(generator *modifiers.SyntheticDataGenerator) *eventBuilder {
+	return &eventBuilder{constraints: map[string][]modifiers.Constraint{}, generator: generator}
+}
+
+// synthesize generates a value that satisfies all the given constraints, or a random value if there are none.
+func (b *eventBuilder) synthesize(constraints ...modifiers.Constraint) (any, error) {
+	return b.generator.GenerateConstrainedValue(constraints)
+}
+
+// add records a constraint on the given event field.
+func (b *eventBuilder) add(field string, constraint modifiers.Constraint) {
+	if _, ok := b.constraints[field]; !ok {
+		b.fields = append(b.fields, field)
+	}
+	b.constraints[field] = append(b.constraints[field], constraint)
+}
+
+// build generates a value for every constrained field and returns the resulting event.
+// Fields that must be absent are left out, and fields that reference other fields are generated after them.
+// An error is returned if no value can be generated for one of the fields.
+func (b *eventBuilder) build() (Event, error) {
+	event := Event{Fields: make(map[string]interface{}, len(b.fields))}
+	var referencing []string
+	for _, field := range b.fields {
+		if b.references(field) {
+			referencing = append(referencing, field)
+			continue
+		}
+		value, err := b.synthesize(b.constraints[field]...)
+		if err != nil {
+			return Event{}, fmt.Errorf("error generating %s: %w", field, err)
+		}
+		if value != nil {
+			event.Fields[field] = value
+		}
+	}
+
+	for _, field := range referencing {
+		constraints := make([]modifiers.Constraint, len(b.constraints[field]))
+		for i, constraint := range b.constraints[field] {
+			// Replace the reference with the value of the referenced field, which gets a random value if it's unconstrained
+			if reference, ok := constraint.Value.(modifiers.FieldRef); ok {
+				value, ok := event.Fields[reference.Field]
+				if !ok {
+					// Unconstrained values can always be generated
+					value, _ = b.synthesize()
+					event.Fields[reference.Field] = value
+				}
+				constraint.Value = value
+			}
+			constraints[i] = constraint
+		}
+		value, err := b.synthesize(constraints...)
+		if err != nil {
+			return Event{}, fmt.Errorf("error generating %s: %w", field, err)
+		}
+		if value != nil {
+			event.Fields[field] = value
+		}
+	}
+	return event, nil
+}
+
+// references returns whether any constraint of a field references another field.
+func (b *eventBuilder) references(field string) bool {
+	for _, constraint := range b.constraints[field] {
+		if _, ok := constraint.Value.(modifiers.FieldRef); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// generateEvent builds a synthetic event that satisfies the given search expression.
+// The conditions of the logsource mappings in the config are applied to the event as well.
+func (rule RuleEvaluator) generateEvent(ctx context.Context, search sigma.SearchExpr) (Event, error) {
+	builder, err := rule.constrainEvent(ctx, search)
+	if err != nil {
+		return Event{}, err
+	}
+	return builder.build()
+}
+
+// constrainEvent records the constraints that an event has to satisfy to match the given search expression.
+// The returned builder can be built several times to generate different events that all match the expression.
+func (rule RuleEvaluator) constrainEvent(ctx context.Context, search sigma.SearchExpr) (*eventBuilder, error) {
+	node, err := rule.compileSearchExpression(ctx, search, nil)
+	if err != nil {
+		return nil, err
+	}
+	return rule.constrainQuery(ctx, node)
+}
+
+// constrainQuery records the constraints that an event has to satisfy to match the given query tree.
+// The conditions of the logsource mappings in the config are applied to the event as well.
+func (rule RuleEvaluator) constrainQuery(ctx context.Context, node QueryNode) (*eventBuilder, error) {
+	logsource, err := rule.compileLogsource(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	builder := newEventBuilder(rule.generator)
+	// Events must come from the logsource that the rule applies to
+	builder.constrain(logsource)
+	builder.constrain(node)
+	return builder, nil
+}
+
+// DefaultMaxBranches is the maximum number of events generated for the branches of a condition if WithMaxBranches isn't used.
+const DefaultMaxBranches = 16
+
+// generateBranches builds an event for every branch of a query tree, the conjuncts of its disjunctive normal form,
+// so that each way to satisfy a condition like '1 of selection*' is exercised, up to the maximum number of branches of the rule.
+// A tree without any satisfiable branch still gets the single event that constrainQuery builds for it.
+func (rule RuleEvaluator) generateBranches(ctx context.Context, node QueryNode) ([]Event, error) {
+	maxBranches := rule.maxBranches
+	if maxBranches <= 0 {
+		maxBranches = DefaultMaxBranches
+	}
+
+	branches := []QueryNode{node}
+	if conjuncts := disjunctiveNormalForm(node, maxBranches); len(conjuncts) > 0 {
+		branches = branches[:0]
+		for _, conjunct := range conjuncts {
+			branches = append(branches, conjunct)
+		}
+	}
+
+	events := make([]Event, 0, len(branches))
+	for _, branch := range branches {
+		builder, err := rule.constrainQuery(ctx, branch)
+		if err != nil {
+			return nil, err
+		}
+		event, err := builder.build()
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// generateNegativeEvents builds near-miss events that must not satisfy the given query tree.
+// Each event violates the tree in a different way while satisfying as much of the rest of it as possible,
+// e.g. an event that matches every field of a search but one, or one that matches the negated branch of a 'not'.
+// Violations that no event can achieve, e.g. of a substring that another substring of the same field contains, are left out:
+// the events that still satisfy the condition with the given index are dropped.
+func (rule RuleEvaluator) generateNegativeEvents(ctx context.Context, conditionIndex int, node QueryNode) ([]Event, error) {
+	logsource, err := rule.compileLogsource(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []Event
+	for _, violate := range violateQuery(node) {
+		builder := newEventBuilder(rule.generator)
+		// Negative events still come from the logsource that the rule applies to
+		builder.constrain(logsource)
+		violate(builder)
+
+		// Near-misses that can't be generated, e.g. of a CIDR block that covers every address, leave the sample out
+		event, err := builder.build()
+		if err != nil {
+			continue
+		}
+		match, err := rule.Matches(ctx, event)
+		if err != nil {
+			return nil, err
+		}
+		if !match.Conditions[conditionIndex] {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+// violation records the constraints of one way for an event to violate a query tree.
+type violation func(builder *eventBuilder)
+
+// violateQuery walks a query tree and returns the ways in which an event can violate it.
+// An empty list means that the tree can't be violated, e.g. a search without any field matchers.
+func violateQuery(node QueryNode) []violation {
+	switch n := node.(type) {
+	// violating any operand of a conjunction is enough, while the other operands are satisfied
+	case And:
+		return violateAll(n)
+
+	// every operand of a disjunction has to be violated
+	case Or:
+		return violateAny(n)
+
+	// negated nodes are violated by satisfying them
+	case Not:
+		return []violation{func(builder *eventBuilder) {
+			builder.constrain(n.Node)
+		}}
+
+	// a predicate is violated by a near-miss of its value
+	case FieldPredicate:
+		return []violation{func(builder *eventBuilder) {
+			builder.add(n.Field, n.constraint().Negate())
+		}}
+	}
+	return nil
+}
+
+// violateAll returns the ways to violate a conjunction: one of the operands is violated and the others are satisfied.
+func violateAll(nodes []QueryNode) []violation {
+	var violations []violation
+	for i, node := range nodes {
+		for _, violate := range violateQuery(node) {
+			violated, violate := i, violate
+			violations = append(violations, func(builder *eventBuilder) {
+				for j, other := range nodes {
+					if j != violated {
+						builder.constrain(other)
+					}
+				}
+				violate(builder)
+			})
+		}
+	}
+	return violations
+}
+
+// violateAny returns the ways to violate a disjunction, which requires violating every one of its operands.
+// Only the first way to violate each operand is used to avoid a combinatorial explosion.
+func violateAny(nodes []QueryNode) []violation {
+	var violates []violation
+	for _, node := range nodes {
+		nodeViolations := violateQuery(node)
+		if len(nodeViolations) == 0 {
+			// one of the operands always matches, so the disjunction can't be violated
+			return nil
+		}
+		violates = append(violates, nodeViolations[0])
+	}
+	if len(violates) == 0 {
+		return nil
+	}
+
+	return []violation{func(builder *eventBuilder) {
+		for _, violate := range violates {
+			violate(builder)
+		}
+	}}
+}
+
+// constrain walks a query tree and records the constraints that a matching event has to satisfy.
+func (b *eventBuilder) constrain(node QueryNode) {
+	switch n := node.(type) {
+	// every operand of a conjunction has to be satisfied
+	case And:
+		for _, operand := range n {
+			b.constrain(operand)
+		}
+
+	// satisfying the first operand of a disjunction is enough
+	case Or:
+		if len(n) > 0 {
+			b.constrain(n[0])
+		}
+
+	// a matching event must not satisfy negated predicates, other negations are normalised with De Morgan's laws first
+	case Not:
+		if predicate, ok := n.Node.(FieldPredicate); ok {
+			b.add(predicate.Field, predicate.constraint().Negate())
+		} else {
+			b.constrain(negationNormalForm(n))
+		}
+
+	case FieldPredicate:
+		b.add(n.Field, n.constraint())
+	}
+}
+
+// eventField returns the name of the event field that a rule field is written to.
+// If field mappings are defined for the rule field, the first target name is used.
+func (rule RuleEvaluator) eventField(field string) string {
+	if mappings := rule.fieldmappings[field]; len(mappings) > 0 {
+		return mappings[0]
+	}
+	return field
+}
+
+// searchNames returns the names of the searches that match the given pattern, in the order they are declared in the rule.
+func (rule RuleEvaluator) searchNames(pattern string) []string {
+	var names []string
+	for _, name := range rule.Detection.SearchNames() {
+		if matchesPattern, _ := path.Match(pattern, name); matchesPattern {
+			names = append(names, name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,505 @@
+package sevaluator_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/mtnmunuklu/logen/sigma"
+	"github.com/mtnmunuklu/logen/sigma/sevaluator"
+)
+
+const generateTestRule = `
+title: Generate Test
+logsource:
+  category: process_creation
+  product: windows
+detection:
+  selection:
+    CommandLine|contains|all:
+      - '\nslookup.exe'
+      - '-q=TXT'
+    CommandLine|startswith: 'C:\'
+    Image|endswith: '.exe'
+  filter:
+    User: SYSTEM
+  condition: selection and not filter
+`
+
+// TestRuleEvaluator_Events checks that the generated events satisfy the constraints of the rule.
+func TestRuleEvaluator_Events(t *testing.T) {
+	rule, err := sigma.ParseRule([]byte(generateTestRule))
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := sigma.ParseConfig([]byte(testConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := sevaluator.ForRule(rule, sevaluator.WithConfig(config)).Alters(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Events[0]) != 1 {
+		t.Fatalf("expected a single event, got %d", len(result.Events[0]))
+	}
+	event := result.Events[0][0]
+
+	// CommandLine is mapped to the command field by the config
+	command, _ := event.Fields["command"].(string)
+	if !strings.HasPrefix(command, `C:\`) || !strings.Contains(command, `\nslookup.exe`) || !strings.Contains(command, "-q=TXT") {
+		t.Errorf("command doesn't satisfy the rule: %q", command)
+	}
+
+	// Image is mapped to the sproc field by the config
+	image, _ := event.Fields["sproc"].(string)
+	if !strings.HasSuffix(image, ".exe") {
+		t.Errorf("sproc doesn't satisfy the rule: %q", image)
+	}
+
+	// Negated searches are violated by a near-miss value
+	if user, ok := event.Fields["User"].(string); !ok || strings.EqualFold(user, "SYSTEM") {
+		t.Errorf("User doesn't violate the filter: %v", event.Fields)
+	}
+}
+
+const wildcardEqualTestRule = `
+title: Wildcard Equal Test
+logsource:
+  category: process_creation
+  product: windows
+detection:
+  selection:
+    Image: '*cmd.exe'
+    OriginalFileName: 'cmd?.exe'
+    ParentImage: 'C:\Windows\?ystem32\*'
+  condition: selection
+`
+
+// TestRuleEvaluator_WildcardEqual checks that the wildcards of values without modifiers are expanded, so that the events match the rule.
+// Escaped wildcards are literal characters of the value.
+func TestRuleEvaluator_WildcardEqual(t *testing.T) {
+	rule, err := sigma.ParseRule([]byte(wildcardEqualTestRule))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 20; i++ {
+		evaluator := sevaluator.ForRule(rule)
+		result, err := evaluator.Alters(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		event := result.Events[0][0]
+		for _, field := range []string{"Image", "OriginalFileName"} {
+			if value, _ := event.Fields[field].(string); strings.ContainsAny(value, "*?") {
+				t.Errorf("expected the wildcards of %s to be expanded, got %q", field, value)
+			}
+		}
+		if parent := event.Fields["ParentImage"]; parent != `C:\Windows?ystem32*` {
+			t.Errorf("expected the escaped wildcards of ParentImage to be literal, got %q", parent)
+		}
+		match, err := evaluator.Matches(context.Background(), event)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !match.Match {
+			t.Errorf("expected the event to match the rule, got %v", event.Fields)
+		}
+	}
+}
+
+// TestRuleEvaluator_Negatives checks that each negative event only just misses the rule.
+func TestRuleEvaluator_Negatives(t *testing.T) {
+	rule, err := sigma.ParseRule([]byte(generateTestRule))
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := sigma.ParseConfig([]byte(testConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := sevaluator.ForRule(rule, sevaluator.WithConfig(config), sevaluator.NegativeSamples).Alters(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// One event per value of CommandLine|contains|all and per other field matcher of the selection, and one that satisfies the filter
+	negatives := result.Negatives[0]
+	if len(negatives) != 5 {
+		t.Fatalf("expected 5 negative events, got %d: %v", len(negatives), negatives)
+	}
+
+	// The selection misses each of the values of CommandLine|contains|all in turn
+	command, _ := negatives[0].Fields["command"].(string)
+	if strings.Contains(strings.ToLower(command), `\nslookup.exe`) || !strings.Contains(command, "-q=TXT") {
+		t.Errorf("command doesn't miss the first value: %q", command)
+	}
+	command, _ = negatives[1].Fields["command"].(string)
+	if !strings.Contains(command, `\nslookup.exe`) || strings.Contains(strings.ToLower(command), "-q=txt") {
+		t.Errorf("command doesn't miss the second value: %q", command)
+	}
+
+	// The selection misses the prefix of CommandLine|startswith
+	command, _ = negatives[2].Fields["command"].(string)
+	if strings.HasPrefix(command, `C:\`) || !strings.HasPrefix(command, `C:`) {
+		t.Errorf("command doesn't miss the prefix: %q", command)
+	}
+
+	// The selection misses the suffix of Image|endswith
+	image, _ := negatives[3].Fields["sproc"].(string)
+	if strings.HasSuffix(strings.ToLower(image), ".exe") {
+		t.Errorf("sproc doesn't miss the suffix: %q", image)
+	}
+
+	// The selection matches, but so does the filter
+	command, _ = negatives[4].Fields["command"].(string)
+	if !strings.Contains(command, "-q=TXT") || negatives[4].Fields["User"] != "SYSTEM" {
+		t.Errorf("event doesn't satisfy both the selection and the filter: %v", negatives[4].Fields)
+	}
+}
+
+const eventMatchersTestRule = `
+title: Event Matchers Test
+logsource:
+  category: process_creation
+  product: windows
+detection:
+  selection:
+    - Image|endswith: '\wmic.exe'
+    - OriginalFileName: 'wmic.exe'
+    - CommandLine|contains: 'shadowcopy'
+  condition: selection
+`
+
+// TestRuleEvaluator_NegativesEventMatchers checks that the negative events of a search with several event matchers violate all of them,
+// since any one of them is enough to match the search.
+func TestRuleEvaluator_NegativesEventMatchers(t *testing.T) {
+	rule, err := sigma.ParseRule([]byte(eventMatchersTestRule))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 20; i++ {
+		result, err := sevaluator.ForRule(rule, sevaluator.NegativeSamples).Alters(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		negatives := result.Negatives[0]
+		if len(negatives) != 1 {
+			t.Fatalf("expected a single negative event, got %d: %v", len(negatives), negatives)
+		}
+		image, _ := negatives[0].Fields["Image"].(string)
+		command, _ := negatives[0].Fields["CommandLine"].(string)
+		if strings.HasSuffix(strings.ToLower(image), `\wmic.exe`) || strings.EqualFold(fmt.Sprint(negatives[0].Fields["OriginalFileName"]), "wmic.exe") || strings.Contains(strings.ToLower(command), "shadowcopy") {
+			t.Errorf("expected the negative event to violate every event matcher, got %v", negatives[0].Fields)
+		}
+		if !result.Verifications[0].Passed() {
+			t.Errorf("expected the events to pass verification, got %+v", result.Verifications[0])
+		}
+	}
+}
+
+const impliedViolationTestRule = `
+title: Implied Violation Test
+logsource:
+  product: zeek
+  service: smb_files
+detection:
+  selection:
+    path|contains|all:
+      - '\\'
+      - '\IPC$'
+    name|endswith: '-stdin'
+  condition: selection
+`
+
+// TestRuleEvaluator_NegativesImpliedViolation checks that violations that no event can achieve are left out of the negative events:
+// a path that contains \IPC$ always contains a backslash.
+func TestRuleEvaluator_NegativesImpliedViolation(t *testing.T) {
+	rule, err := sigma.ParseRule([]byte(impliedViolationTestRule))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 20; i++ {
+		result, err := sevaluator.ForRule(rule, sevaluator.NegativeSamples).Alters(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The path misses \IPC$, or the name misses its suffix
+		if negatives := result.Negatives[0]; len(negatives) != 2 {
+			t.Fatalf("expected 2 negative events, got %d: %v", len(negatives), negatives)
+		}
+		if !result.Verifications[0].Passed() {
+			t.Errorf("expected the events to pass verification, got %+v", result.Verifications[0])
+		}
+	}
+}
+
+const impossibleNearMissTestRule = `
+title: Impossible Near Miss Test
+logsource:
+  category: network_connection
+  product: windows
+detection:
+  selection:
+    DestinationIp|cidr: '0.0.0.0/0'
+    Image|endswith: '.exe'
+  condition: selection
+`
+
+// TestRuleEvaluator_NegativesImpossibleNearMiss checks that the negative samples whose near-miss can't be generated are left out,
+// rather than given an empty value: no address is outside of 0.0.0.0/0.
+func TestRuleEvaluator_NegativesImpossibleNearMiss(t *testing.T) {
+	rule, err := sigma.ParseRule([]byte(impossibleNearMissTestRule))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := sevaluator.ForRule(rule, sevaluator.NegativeSamples).Alters(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the image misses its suffix
+	negatives := result.Negatives[0]
+	if len(negatives) != 1 {
+		t.Fatalf("expected a single negative event, got %d: %v", len(negatives), negatives)
+	}
+	if image, _ := negatives[0].Fields["Image"].(string); strings.HasSuffix(strings.ToLower(image), ".exe") || negatives[0].Fields["DestinationIp"] == "" {
+		t.Errorf("expected the image to miss its suffix, got %v", negatives[0].Fields)
+	}
+}
+
+const polarityTestRule = `
+title: Polarity Test
+logsource:
+  category: process_creation
+  product: windows
+detection:
+  selection:
+    Image|endswith: '\rundll32.exe'
+  filter_parent:
+    ParentImage: null
+  filter_user:
+    User|contains: 'svc'
+  condition:
+    - selection and not filter_parent
+    - not not selection
+    - selection and not (filter_parent and not filter_user)
+    - selection and not 1 of filter_*
+`
+
+// TestRuleEvaluator_Polarity checks that positive events violate the searches that are negated in the condition,
+// including negations of negations and of several searches at once.
+func TestRuleEvaluator_Polarity(t *testing.T) {
+	rule, err := sigma.ParseRule([]byte(polarityTestRule))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 20; i++ {
+		result, err := sevaluator.ForRule(rule).Alters(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for condition, verification := range result.Verifications {
+			if !verification.Passed() {
+				t.Fatalf("expected the events of condition %d to pass verification, got %+v", condition, verification)
+			}
+		}
+
+		// A null filter is violated by the presence of the field
+		for _, condition := range []int{0, 2, 3} {
+			if _, ok := result.Events[condition][0].Fields["ParentImage"]; !ok {
+				t.Errorf("expected ParentImage in the event of condition %d, got %v", condition, result.Events[condition][0].Fields)
+			}
+		}
+		if image, _ := result.Events[1][0].Fields["Image"].(string); !strings.HasSuffix(image, `\rundll32.exe`) {
+			t.Errorf("expected a double negation to cancel out, got %v", result.Events[1][0].Fields)
+		}
+		if user, ok := result.Events[3][0].Fields["User"].(string); !ok || strings.Contains(strings.ToLower(user), "svc") {
+			t.Errorf("expected User to violate filter_user, got %v", result.Events[3][0].Fields)
+		}
+	}
+}
+
+const branchesTestRule = `
+title: Branches Test
+logsource:
+  category: process_creation
+  product: windows
+detection:
+  selection_image:
+    Image|endswith: '\certutil.exe'
+  selection_command:
+    CommandLine|contains:
+      - 'urlcache'
+      - 'verifyctfs'
+  filter:
+    Image|endswith: '\certutil.exe'
+    CommandLine|contains: 'urlcache'
+  condition:
+    - 1 of selection_*
+    - 1 of selection_* and not filter
+    - selection_image and not selection_image
+`
+
+// TestRuleEvaluator_Branches checks that a condition gets an event for every branch of its disjunctive normal form,
+// that contradictory branches are skipped and that the number of events is capped.
+func TestRuleEvaluator_Branches(t *testing.T) {
+	rule, err := sigma.ParseRule([]byte(branchesTestRule))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := sevaluator.ForRule(rule).Alters(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// One event per search and per value of CommandLine|contains, each only satisfying its own branch
+	events := result.Events[0]
+	if len(events) != 3 {
+		t.Fatalf("expected 3 events, got %d: %v", len(events), events)
+	}
+	image, _ := events[0].Fields["Image"].(string)
+	if !strings.HasSuffix(image, `\certutil.exe`) || events[0].Fields["CommandLine"] != nil {
+		t.Errorf("expected the first event to only match selection_image, got %v", events[0].Fields)
+	}
+	for i, value := range []string{"urlcache", "verifyctfs"} {
+		command, _ := events[i+1].Fields["CommandLine"].(string)
+		if !strings.Contains(command, value) || events[i+1].Fields["Image"] != nil {
+			t.Errorf("expected event %d to only contain %s, got %v", i+1, value, events[i+1].Fields)
+		}
+	}
+
+	// The branches of the selection are combined with the ways to violate the filter, except for the one that contradicts it
+	if len(result.Events[1]) != 4 {
+		t.Errorf("expected 4 events for the filtered condition, got %d: %v", len(result.Events[1]), result.Events[1])
+	}
+
+	// A contradictory condition has no satisfiable branch, so it falls back to a single event
+	if len(result.Events[2]) != 1 {
+		t.Errorf("expected a single event for the contradictory condition, got %d", len(result.Events[2]))
+	}
+
+	for _, condition := range []int{0, 1} {
+		if !result.Verifications[condition].Passed() {
+			t.Errorf("expected the events of condition %d to pass verification, got %+v", condition, result.Verifications[condition])
+		}
+	}
+
+	// The number of events is capped
+	result, err = sevaluator.ForRule(rule, sevaluator.WithMaxBranches(2)).Alters(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Events[0]) != 2 {
+		t.Errorf("expected the events to be capped at 2, got %d", len(result.Events[0]))
+	}
+}
+
+const modifiersTestRule = `
+title: Modifiers Test
+logsource:
+  category: process_creation
+  product: windows
+detection:
+  selection:
+    CommandLine|windash|contains: ' -enc '
+    ParentCommandLine|base64offset|contains: 'IEX'
+    Image|expand: '%System%\powershell.exe'
+    User|cased: 'Admin'
+    TargetUser|fieldref: User
+    OriginalFileName|exists: true
+    IntegrityLevel|exists: false
+  condition: selection
+`
+
+// TestRuleEvaluator_Modifiers checks that the generated events satisfy rules that use the modifiers of the Sigma specification.
+func TestRuleEvaluator_Modifiers(t *testing.T) {
+	rule, err := sigma.ParseRule([]byte(modifiersTestRule))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expander := func(ctx context.Context, placeholder string) ([]string, error) {
+		return []string{`C:\Windows\System32`}, nil
+	}
+	result, err := sevaluator.ForRule(rule, sevaluator.WithPlaceholderExpander(expander), sevaluator.NegativeSamples).Alters(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event := result.Events[0][0]
+	if image := event.Fields["Image"]; image != `C:\Windows\System32\powershell.exe` {
+		t.Errorf("expected the placeholder to be expanded, got %v", image)
+	}
+	if event.Fields["TargetUser"] != event.Fields["User"] || event.Fields["User"] != "Admin" {
+		t.Errorf("expected TargetUser to reference User, got %v", event.Fields)
+	}
+	if _, ok := event.Fields["OriginalFileName"]; !ok {
+		t.Errorf("expected OriginalFileName to exist, got %v", event.Fields)
+	}
+	if _, ok := event.Fields["IntegrityLevel"]; ok {
+		t.Errorf("expected IntegrityLevel to be absent, got %v", event.Fields)
+	}
+
+	if !result.Verifications[0].Passed() {
+		t.Errorf("expected the events to pass verification, got %+v", result.Verifications[0])
+	}
+	for _, query := range []string{"commandline contains '", "parentcommandline contains '", "targetuser equal user", "not integritylevel exists"} {
+		if !strings.Contains(result.Queries[0], query) {
+			t.Errorf("expected query %s to contain %s", result.Queries[0], query)
+		}
+	}
+}
+
+const numericTestRule = `
+title: Numeric Test
+logsource:
+  category: network_connection
+  product: windows
+detection:
+  selection:
+    DestinationPort|gt: 5
+    DestinationPort|lt: 10
+    Duration|gte: 1.5
+  condition: selection
+`
+
+// TestRuleEvaluator_Numeric checks that numeric comparisons on the same field are combined and keep the type of their thresholds.
+func TestRuleEvaluator_Numeric(t *testing.T) {
+	rule, err := sigma.ParseRule([]byte(numericTestRule))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 20; i++ {
+		result, err := sevaluator.ForRule(rule, sevaluator.NegativeSamples).Alters(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		event := result.Events[0][0]
+		if port, ok := event.Fields["DestinationPort"].(int64); !ok || port <= 5 || port >= 10 {
+			t.Fatalf("expected an integer DestinationPort in (5,10), got %v (%T)", event.Fields["DestinationPort"], event.Fields["DestinationPort"])
+		}
+		if duration, ok := event.Fields["Duration"].(float64); !ok || duration < 1.5 {
+			t.Fatalf("expected a float Duration of at least 1.5, got %v (%T)", event.Fields["Duration"], event.Fields["Duration"])
+		}
+		if !result.Verifications[0].Passed() {
+			t.Fatalf("expected the events to pass verification, got %+v", result.Verifications[0])
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package sevaluator_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bradleyjkemp/cupaloy/v2"
+	"github.com/mtnmunuklu/logen/sigma"
+	"github.com/mtnmunuklu/logen/sigma/sevaluator"
+	"github.com/mtnmunuklu/logen/sigma/sevaluator/formatters"
+	"github.com/mtnmunuklu/logen/sigma/sevaluator/modifiers"
+)
+
+const goldenTestRule = `
+title: Golden Test
+logsource:
+  category: network_connection
+  product: windows
+detection:
+  selection:
+    Image|endswith: '\powershell.exe'
+    CommandLine|contains|all:
+      - 'Invoke-'
+      - 'http'
+    DestinationIp|cidr: '10.0.0.0/8'
+    DestinationPort|gte: 1024
+    DestinationHostname|re: '^[a-z]{4,8}\.example\.(com|net)$'
+  filter:
+    User|startswith: 'NT AUTHORITY'
+  condition: selection and not filter | count() by DestinationIp > 2
+  timeframe: 5m
+`
+
+// generateGolden renders the query and the JSON lines of a rule evaluated with a generator seeded with the given seed.
+func generateGolden(t *testing.T, rule sigma.Rule, seed int64) string {
+	timeline := sevaluator.Timeline{
+		Start:        time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC),
+		Distribution: "exponential",
+		Jitter:       time.Second,
+		Location:     time.UTC,
+	}
+	evaluator := sevaluator.ForRule(rule,
+		sevaluator.WithGenerator(modifiers.NewSeededSyntheticDataGenerator(seed)),
+		sevaluator.WithTimeline(timeline),
+		sevaluator.NegativeSamples,
+	)
+
+	result, err := evaluator.Alters(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Verifications[0].Passed() {
+		t.Fatalf("expected the events to pass verification, got %+v", result.Verifications[0])
+	}
+
+	var output bytes.Buffer
+	output.WriteString(result.Queries[0] + "\n")
+	if err := formatters.WriteJSONLines(&output, formatters.NewRecords(rule, result)); err != nil {
+		t.Fatal(err)
+	}
+	return output.String()
+}
+
+// TestRuleEvaluator_Golden checks that a seeded generator produces byte-identical output, which is compared to a golden file.
+func TestRuleEvaluator_Golden(t *testing.T) {
+	rule, err := sigma.ParseRule([]byte(goldenTestRule))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output := generateGolden(t, rule, 42)
+	for i := 0; i < 5; i++ {
+		if repeated := generateGolden(t, rule, 42); repeated != output {
+			t.Fatalf("expected the same seed to give the same output, got:\n%s\nand:\n%s", output, repeated)
+		}
+	}
+	if other := generateGolden(t, rule, 43); other == output {
+		t.Errorf("expected a different seed to give different output, got:\n%s", other)
+	}
+
+	cupaloy.New(cupaloy.SnapshotSubdirectory("testdata")).SnapshotT(t, output)
+}
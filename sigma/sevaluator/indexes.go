@@ -2,12 +2,14 @@ package sevaluator
 
 // The RelevantToIndex method determines whether the current rule is applicable to the given index.
 // It returns false if a configuration file has not been loaded yet.
+// The keyword fields declared by the matching logsource mappings, or by the configs themselves, are collected as well.
 func (rule *RuleEvaluator) calculateIndexes() {
 	if rule.config == nil {
 		return
 	}
 
 	var indexes []string
+	var keywordFields []string
 
 	// Extract category, product, and service from the current logsource
 	category := rule.Logsource.Category
@@ -47,6 +49,9 @@ func (rule *RuleEvaluator) calculateIndexes() {
 
 			// If the mapping has specified conditions, AND them with the current ones
 			rule.indexConditions = append(rule.indexConditions, logsource.Conditions)
+
+			// Keywords are searched in the free-text fields of the logsource
+			keywordFields = append(keywordFields, logsource.KeywordFields...)
 		}
 
 		// If the rule hasn't matched any mappings and a default index is specified in the config, use it
@@ -55,8 +60,18 @@ func (rule *RuleEvaluator) calculateIndexes() {
 		}
 	}
 
+	// If no logsource mapping declares keyword fields, use the ones declared by the configs
+	if len(keywordFields) == 0 {
+		for _, config := range rule.config {
+			keywordFields = append(keywordFields, config.KeywordFields...)
+		}
+	}
+
 	// Set the possible indexes for the current rule
 	rule.indexes = indexes
+	if len(keywordFields) > 0 {
+		rule.keywordFields = keywordFields
+	}
 }
 
 // The Indexes method returns the possible indexes for the current rule
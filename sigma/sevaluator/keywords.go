@@ -0,0 +1,71 @@
+package sevaluator
+
+import (
+	"strings"
+
+	"github.com/mtnmunuklu/logen/sigma/sevaluator/modifiers"
+)
+
+// DefaultKeywordField is the free-text event field that keywords are searched in if neither the config nor the options declare any.
+const DefaultKeywordField = "message"
+
+// KeywordFields returns the free-text event fields that the keywords of the rule are searched in.
+func (rule RuleEvaluator) KeywordFields() []string {
+	if len(rule.keywordFields) == 0 {
+		return []string{DefaultKeywordField}
+	}
+	return rule.keywordFields
+}
+
+// keywordValues converts keywords to the patterns that have to appear in a keyword field, which may still contain inner wildcards.
+// Keywords are always matched as substrings, so leading and trailing wildcards are redundant, unless the trailing one is escaped.
+func keywordValues(keywords []string) []string {
+	values := make([]string, len(keywords))
+	for i, keyword := range keywords {
+		value := strings.TrimLeft(keyword, "*")
+		for strings.HasSuffix(value, "*") {
+			// A run of backslashes is read in escaped pairs, so the wildcard is escaped if an odd number of them precedes it
+			unescaped := strings.TrimSuffix(value, "*")
+			if backslashes := len(unescaped) - len(strings.TrimRight(unescaped, `\`)); backslashes%2 == 1 {
+				break
+			}
+			value = unescaped
+		}
+		values[i] = value
+	}
+	return values
+}
+
+// compileKeywords compiles a keyword search: any of the keywords appears in any of the keyword fields.
+func (rule RuleEvaluator) compileKeywords(keywords []string) QueryNode {
+	var nodes []QueryNode
+	for _, field := range rule.KeywordFields() {
+		for _, value := range keywordValues(keywords) {
+			nodes = append(nodes, FieldPredicate{Field: field, Operator: "contains", Value: value, CaseSensitive: rule.caseSensitive})
+		}
+	}
+	return disjunction(nodes)
+}
+
+// matchKeywords returns whether any of the keywords appears in any of the keyword fields of an event.
+func (rule RuleEvaluator) matchKeywords(keywords []string, event Event) (bool, error) {
+	var matcher modifiers.MatcherFunc
+	var err error
+	if rule.caseSensitive {
+		matcher, err = modifiers.GetMatcherCaseSensitive("contains")
+	} else {
+		matcher, err = modifiers.GetMatcher("contains")
+	}
+	if err != nil {
+		return false, err
+	}
+
+	for _, field := range rule.KeywordFields() {
+		for _, value := range keywordValues(keywords) {
+			if match, err := matcher(event.Fields[field], value); err != nil || match {
+				return match, err
+			}
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,138 @@
+package sevaluator_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/mtnmunuklu/logen/sigma"
+	"github.com/mtnmunuklu/logen/sigma/sevaluator"
+)
+
+const keywordsTestRule = `
+title: Keywords Test
+logsource:
+  product: linux
+  service: auth
+detection:
+  keywords:
+    - '*Failed password*'
+    - 'authentication failure'
+  condition: keywords
+`
+
+const keywordsTestConfig = `
+title: Keyword Fields
+keywordfields: raw
+logsources:
+  linux_auth:
+    product: linux
+    service: auth
+    keywordfields:
+      - msg
+      - raw
+`
+
+// TestRuleEvaluator_Keywords checks that keyword searches are searched in the keyword fields of the config.
+func TestRuleEvaluator_Keywords(t *testing.T) {
+	rule, err := sigma.ParseRule([]byte(keywordsTestRule))
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := sigma.ParseConfig([]byte(keywordsTestConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tt := []struct {
+		Name    string
+		Options []sevaluator.Option
+		Fields  []string
+	}{
+		{"Default", nil, []string{sevaluator.DefaultKeywordField}},
+		{"Logsource", []sevaluator.Option{sevaluator.WithConfig(config)}, []string{"msg", "raw"}},
+		{"Option", []sevaluator.Option{sevaluator.WithConfig(config), sevaluator.WithKeywordFields("CommandLine")}, []string{"CommandLine"}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			evaluator := sevaluator.ForRule(rule, append(tc.Options, sevaluator.NegativeSamples)...)
+			if fields := evaluator.KeywordFields(); strings.Join(fields, ",") != strings.Join(tc.Fields, ",") {
+				t.Fatalf("expected keyword fields %v, got %v", tc.Fields, fields)
+			}
+
+			result, err := evaluator.Alters(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, field := range tc.Fields {
+				if !strings.Contains(result.Queries[0], strings.ToLower(field)+" contains") {
+					t.Errorf("expected %s in query: %s", field, result.Queries[0])
+				}
+			}
+
+			value, _ := result.Events[0][0].Fields[tc.Fields[0]].(string)
+			if !strings.Contains(value, "Failed password") {
+				t.Errorf("expected the first keyword in the %s field, got %v", tc.Fields[0], result.Events[0][0].Fields)
+			}
+			if !result.Verifications[0].Passed() {
+				t.Errorf("expected the events to pass verification, got %+v", result.Verifications[0])
+			}
+		})
+	}
+}
+
+const wildcardKeywordsTestRule = `
+title: Wildcard Keywords Test
+logsource:
+  product: linux
+  service: auth
+detection:
+  keywords:
+    - '*ssh*root*'
+    - 'glob\*'
+  condition: keywords
+`
+
+// TestRuleEvaluator_WildcardKeywords checks that inner wildcards of keywords are matched as wildcards and filled with random text,
+// while escaped wildcards are matched and generated literally.
+func TestRuleEvaluator_WildcardKeywords(t *testing.T) {
+	rule, err := sigma.ParseRule([]byte(wildcardKeywordsTestRule))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 20; i++ {
+		result, err := sevaluator.ForRule(rule, sevaluator.NegativeSamples).Alters(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result.Events[0]) != 2 {
+			t.Fatalf("expected an event for each keyword, got %v", result.Events[0])
+		}
+
+		message, _ := result.Events[0][0].Fields[sevaluator.DefaultKeywordField].(string)
+		if strings.Contains(message, "*") || !strings.Contains(message, "ssh") || !strings.Contains(message[strings.Index(message, "ssh"):], "root") {
+			t.Errorf("expected ssh and root around random text, got %q", message)
+		}
+		if message, _ := result.Events[0][1].Fields[sevaluator.DefaultKeywordField].(string); !strings.Contains(message, "glob*") {
+			t.Errorf("expected a literal wildcard, got %q", message)
+		}
+		if !result.Verifications[0].Passed() {
+			t.Errorf("expected the events to pass verification, got %+v", result.Verifications[0])
+		}
+	}
+
+	// Events with a literal text that the wildcard keyword matches trigger the rule, those without don't
+	evaluator := sevaluator.ForRule(rule)
+	for message, expected := range map[string]bool{"ssh login as root": true, "root ssh": false, "glob*": true, "globe": false} {
+		result, err := evaluator.Matches(context.Background(), sevaluator.Event{Fields: map[string]interface{}{sevaluator.DefaultKeywordField: message}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Conditions[0] != expected {
+			t.Errorf("expected %q to match: %v, got %v", message, expected, result.Conditions[0])
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package sevaluator
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mtnmunuklu/logen/sigma"
+	"github.com/mtnmunuklu/logen/sigma/sevaluator/modifiers"
+)
+
+// MatchResult represents the outcome of matching an event against a Sigma rule.
+type MatchResult struct {
+	Match      bool            // Whether any condition of the rule matches the event
+	Searches   map[string]bool // The map of search identifiers to whether they match the event
+	Conditions []bool          // Whether each condition of the rule matches the event
+	Adjacent   []bool          // Whether the event matches one of the searches that a near condition requires close to its matches
+}
+
+// Verification is the outcome of matching the synthetic events of a condition back against the rule.
+type Verification struct {
+	Positives  []bool // Whether each positive event satisfies the condition, or a search of its near expression, which it must
+	Negatives  []bool // Whether each negative event satisfies the condition, which it must not
+	Aggregated bool   // Whether the positive events together satisfy the aggregation of the condition, always true without one
+}
+
+// Passed reports whether every positive event satisfies the condition and no negative event does.
+// If the condition has an aggregation, the positive events also have to satisfy it together.
+func (v Verification) Passed() bool {
+	if !v.Aggregated {
+		return false
+	}
+	for _, match := range v.Positives {
+		if !match {
+			return false
+		}
+	}
+	for _, match := range v.Negatives {
+		if match {
+			return false
+		}
+	}
+	return true
+}
+
+// Matches checks whether an event satisfies the detection logic of the rule.
+// Event fields are looked up through the field mappings of the config, and values are compared using the modifiers of the rule,
+// case-insensitively unless the CaseSensitive option is set.
+// Aggregations and logsource conditions aren't evaluated, since they depend on more than a single event, see MatchesAggregation.
+func (rule RuleEvaluator) Matches(ctx context.Context, event Event) (MatchResult, error) {
+	result := MatchResult{
+		Searches:   make(map[string]bool, len(rule.Detection.Searches)),
+		Conditions: make([]bool, len(rule.Detection.Conditions)),
+		Adjacent:   make([]bool, len(rule.Detection.Conditions)),
+	}
+
+	for _, identifier := range rule.searchNames("*") {
+		var err error
+		result.Searches[identifier], err = rule.matchSearch(ctx, rule.Detection.Searches[identifier], event)
+		if err != nil {
+			return MatchResult{}, fmt.Errorf("error matching search %s: %w", identifier, err)
+		}
+	}
+
+	for conditionIndex, condition := range rule.Detection.Conditions {
+		match, err := rule.matchSearchExpression(condition.Search, result.Searches)
+		if err != nil {
+			return MatchResult{}, err
+		}
+		result.Conditions[conditionIndex] = match
+		result.Match = result.Match || match
+
+		if near, ok := condition.Aggregation.(sigma.Near); ok {
+			result.Adjacent[conditionIndex], err = rule.matchesNearSearch(near, result.Searches)
+			if err != nil {
+				return MatchResult{}, err
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// verify matches the synthetic events of a condition back against the rule.
+func (rule RuleEvaluator) verify(ctx context.Context, conditionIndex int, events []Event, negatives []Event) (Verification, error) {
+	verification := Verification{Aggregated: true}
+	if rule.Detection.Conditions[conditionIndex].Aggregation != nil {
+		var err error
+		verification.Aggregated, err = rule.MatchesAggregation(ctx, conditionIndex, events)
+		if err != nil {
+			return Verification{}, err
+		}
+	}
+	for _, event := range events {
+		result, err := rule.Matches(ctx, event)
+		if err != nil {
+			return Verification{}, err
+		}
+		verification.Positives = append(verification.Positives, result.Conditions[conditionIndex] || result.Adjacent[conditionIndex])
+	}
+	for _, event := range negatives {
+		result, err := rule.Matches(ctx, event)
+		if err != nil {
+			return Verification{}, err
+		}
+		verification.Negatives = append(verification.Negatives, result.Conditions[conditionIndex])
+	}
+	return verification, nil
+}
+
+// matchSearchExpression evaluates a Sigma search expression given whether each search matches.
+func (rule RuleEvaluator) matchSearchExpression(search sigma.SearchExpr, searches map[string]bool) (bool, error) {
+	switch s := search.(type) {
+	case sigma.And:
+		for _, node := range s {
+			match, err := rule.matchSearchExpression(node, searches)
+			if err != nil || !match {
+				return false, err
+			}
+		}
+		return true, nil
+
+	case sigma.Or:
+		for _, node := range s {
+			match, err := rule.matchSearchExpression(node, searches)
+			if err != nil || match {
+				return match, err
+			}
+		}
+		return false, nil
+
+	case sigma.Not:
+		match, err := rule.matchSearchExpression(s.Expr, searches)
+		return !match, err
+
+	case sigma.SearchIdentifier:
+		return matchSearchIdentifier(s.Name, searches)
+
+	case sigma.OneOfIdentifier:
+		return matchSearchIdentifier(s.Ident.Name, searches)
+
+	case sigma.AllOfIdentifier:
+		return matchSearchIdentifier(s.Ident.Name, searches)
+
+	case sigma.OneOfThem:
+		return matchSearchNames(rule.searchNames("*"), searches, false), nil
+
+	case sigma.OneOfPattern:
+		return matchSearchNames(rule.searchNames(s.Pattern), searches, false), nil
+
+	case sigma.AllOfThem:
+		return matchSearchNames(rule.searchNames("*"), searches, true), nil
+
+	case sigma.AllOfPattern:
+		return matchSearchNames(rule.searchNames(s.Pattern), searches, true), nil
+	}
+	return false, fmt.Errorf("unhandled node type %T", search)
+}
+
+// matchSearchIdentifier returns whether the search with the given identifier matches.
+func matchSearchIdentifier(name string, searches map[string]bool) (bool, error) {
+	match, ok := searches[name]
+	if !ok {
+		return false, fmt.Errorf("unknown search identifier %s", name)
+	}
+	return match, nil
+}
+
+// matchSearchNames returns whether all, or any, of the named searches match.
+func matchSearchNames(names []string, searches map[string]bool, all bool) bool {
+	for _, name := range names {
+		if searches[name] != all {
+			return !all
+		}
+	}
+	return all && len(names) > 0
+}
+
+// matchSearch returns whether an event matches a single search.
+// A search matches if any of its EventMatchers matches, and an EventMatcher matches if all of its field matchers match.
+func (rule RuleEvaluator) matchSearch(ctx context.Context, search sigma.Search, event Event) (bool, error) {
+	if len(search.Keywords) > 0 {
+		return rule.matchKeywords(search.Keywords, event)
+	}
+
+	if len(search.EventMatchers) == 0 {
+		// degenerate case (but common for logsource conditions)
+		return true, nil
+	}
+
+eventMatchers:
+	for _, eventMatcher := range search.EventMatchers {
+		for _, fieldMatcher := range eventMatcher {
+			match, err := rule.matchFieldMatcher(ctx, fieldMatcher, event)
+			if err != nil {
+				return false, err
+			}
+			if !match {
+				continue eventMatchers
+			}
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// matchFieldMatcher returns whether an event matches a single field matcher.
+// If field mappings are defined for the field, it's enough for any of the mapped event fields to match.
+func (rule RuleEvaluator) matchFieldMatcher(ctx context.Context, fieldMatcher sigma.FieldMatcher, event Event) (bool, error) {
+	// The 'all' modifier means that the field has to match every value instead of any of them
+	allValuesMustMatch := false
+	fieldModifiers := fieldMatcher.Modifiers
+	if len(fieldModifiers) > 0 && fieldModifiers[len(fieldModifiers)-1] == "all" {
+		allValuesMustMatch = true
+		fieldModifiers = fieldModifiers[:len(fieldModifiers)-1]
+	}
+
+	// The 'fieldref' modifier means that the values name other fields of the event, whose values are expected instead
+	fieldModifiers, fieldReference := removeModifier(fieldModifiers, "fieldref")
+
+	var matcher modifiers.MatcherFunc
+	var err error
+	if rule.caseSensitive {
+		matcher, err = modifiers.GetMatcherCaseSensitive(fieldModifiers...)
+	} else {
+		matcher, err = modifiers.GetMatcher(fieldModifiers...)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	matcherValues, err := rule.getMatcherValues(ctx, fieldMatcher)
+	if err != nil {
+		return false, err
+	}
+
+	fields := rule.fieldmappings[fieldMatcher.Field]
+	if len(fields) == 0 {
+		fields = []string{fieldMatcher.Field}
+	}
+
+	for _, field := range fields {
+		actual := event.Fields[field]
+		matches := 0
+		for _, value := range matcherValues {
+			var expected interface{} = value
+			if fieldReference {
+				var ok bool
+				if expected, ok = event.Fields[rule.eventField(value)]; !ok {
+					continue
+				}
+			}
+			match, err := matcher(actual, expected)
+			if err != nil {
+				return false, err
+			}
+			if match {
+				matches++
+			}
+		}
+
+		if (allValuesMustMatch && matches == len(matcherValues)) || (!allValuesMustMatch && matches > 0) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// removeModifier returns the modifiers without the given one, and whether it was there.
+func removeModifier(fieldModifiers []string, name string) ([]string, bool) {
+	var remaining []string
+	found := false
+	for _, modifier := range fieldModifiers {
+		if modifier == name {
+			found = true
+			continue
+		}
+		remaining = append(remaining, modifier)
+	}
+	return remaining, found
+}
@@ -0,0 +1,115 @@
+package sevaluator_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mtnmunuklu/logen/sigma"
+	"github.com/mtnmunuklu/logen/sigma/sevaluator"
+)
+
+const matchTestRule = `
+title: Match Test
+logsource:
+  category: network_connection
+  product: windows
+detection:
+  selection:
+    Image|endswith: '\powershell.exe'
+    DestinationIp|cidr: '10.0.0.0/8'
+    DestinationPort|gte: 1024
+  selection_user:
+    User: 'CORP\svc_*'
+  filter:
+    CommandLine|re: '^powershell -nop'
+  condition: selection and 1 of selection_* and not filter
+`
+
+// TestRuleEvaluator_Matches checks that events are matched using the field mappings and modifiers of the rule.
+func TestRuleEvaluator_Matches(t *testing.T) {
+	rule, err := sigma.ParseRule([]byte(matchTestRule))
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := sigma.ParseConfig([]byte(testConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	matching := map[string]interface{}{
+		"sproc":           `C:\Windows\System32\WindowsPowerShell\v1.0\PowerShell.exe`,
+		"DestinationIp":   "10.20.30.40",
+		"DestinationPort": 4444,
+		"User":            `corp\svc_backup`,
+		"command":         "powershell -enc AAAA",
+	}
+
+	tt := []struct {
+		Name          string
+		Fields        map[string]interface{}
+		CaseSensitive bool
+		Match         bool
+	}{
+		{"Matching", matching, false, true},
+		{"CaseSensitive", matching, true, false},
+		{"OutsideCIDR", with(matching, "DestinationIp", "11.0.0.1"), false, false},
+		{"BelowThreshold", with(matching, "DestinationPort", "1023"), false, false},
+		{"WildcardMismatch", with(matching, "User", `corp\admin`), false, false},
+		{"Filtered", with(matching, "command", "powershell -nop -w hidden"), false, false},
+		{"UnmappedField", with(with(matching, "sproc", nil), "Image", `C:\powershell.exe`), false, false},
+		{"MissingField", with(matching, "DestinationIp", nil), false, false},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			options := []sevaluator.Option{sevaluator.WithConfig(config)}
+			if tc.CaseSensitive {
+				options = append(options, sevaluator.CaseSensitive)
+			}
+
+			result, err := sevaluator.ForRule(rule, options...).Matches(context.Background(), sevaluator.Event{Fields: tc.Fields})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Match != tc.Match {
+				t.Errorf("expected match %v, got %v (searches: %v)", tc.Match, result.Match, result.Searches)
+			}
+		})
+	}
+}
+
+// TestRuleEvaluator_Verifications checks that the generated events are verified against the rule.
+func TestRuleEvaluator_Verifications(t *testing.T) {
+	rule, err := sigma.ParseRule([]byte(generateTestRule))
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := sigma.ParseConfig([]byte(testConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := sevaluator.ForRule(rule, sevaluator.WithConfig(config), sevaluator.NegativeSamples).Alters(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verification := result.Verifications[0]
+	if len(verification.Positives) != 1 || len(verification.Negatives) != 5 || !verification.Passed() {
+		t.Errorf("expected the events to pass verification, got %+v", verification)
+	}
+}
+
+// with returns a copy of the fields with the given field set, or removed if the value is nil.
+func with(fields map[string]interface{}, field string, value interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(fields))
+	for name, value := range fields {
+		copied[name] = value
+	}
+	if value == nil {
+		delete(copied, field)
+	} else {
+		copied[field] = value
+	}
+	return copied
+}
@@ -4,6 +4,9 @@ import (
 	"encoding/base64"
 	"encoding/binary"
 	"fmt"
+	"net"
+	"regexp"
+	"strconv"
 	"strings"
 	"unicode/utf16"
 )
@@ -11,64 +14,348 @@ import (
 // syntheticDataGenerator is a global instance of SyntheticDataGenerator.
 var syntheticDataGenerator = NewSyntheticDataGenerator()
 
+// GetComparator returns a ComparatorFunc that renders a pseudo-query filter of a field, with a synthetic value that satisfies the modifiers.
+//
+// Deprecated: The evaluator compiles rules to query trees and generates events from constraints, use GetValues,
+// GetConstraint and GetMatcher instead. GetComparator is kept for existing callers and is no longer used by the evaluator.
 func GetComparator(modifiers ...string) (ComparatorFunc, error) {
-	return getComparator(Comparators, modifiers...)
+	return syntheticDataGenerator.GetComparator(modifiers...)
 }
 
+// GetComparatorCaseSensitive returns a ComparatorFunc like GetComparator, whose filters keep the case of the synthetic values.
+//
+// Deprecated: Use GetValues, GetConstraint and GetMatcherCaseSensitive instead.
 func GetComparatorCaseSensitive(modifiers ...string) (ComparatorFunc, error) {
-	return getComparator(ComparatorsCaseSensitive, modifiers...)
+	return syntheticDataGenerator.GetComparatorCaseSensitive(modifiers...)
 }
 
-func getComparator(comparators map[string]Comparator, modifiers ...string) (ComparatorFunc, error) {
+// GetComparator returns a ComparatorFunc like the package-level GetComparator, whose synthetic values are drawn from the generator.
+//
+// Deprecated: Use GetValues, GetConstraint and GetMatcher instead.
+func (g *SyntheticDataGenerator) GetComparator(modifiers ...string) (ComparatorFunc, error) {
+	return g.getComparator(Comparators, modifiers...)
+}
+
+// GetComparatorCaseSensitive returns a ComparatorFunc like the package-level GetComparatorCaseSensitive, whose synthetic values are drawn from the generator.
+//
+// Deprecated: Use GetValues, GetConstraint and GetMatcherCaseSensitive instead.
+func (g *SyntheticDataGenerator) GetComparatorCaseSensitive(modifiers ...string) (ComparatorFunc, error) {
+	return g.getComparator(ComparatorsCaseSensitive, modifiers...)
+}
+
+// getComparator implements the deprecated GetComparator functions, rendering the filters with the Alters methods of the comparators.
+func (g *SyntheticDataGenerator) getComparator(comparators map[string]Comparator, modifiers ...string) (ComparatorFunc, error) {
 	if len(modifiers) == 0 {
 		return baseComparator{}.Alters, nil
 	}
 
+	valueModifiers, name, err := parseModifiers(comparators, modifiers...)
+	if err != nil {
+		return nil, err
+	}
+	comparator := bindGenerator(selectComparator(comparators, name, modifiers), g)
+
+	return func(field, value any) (string, error) {
+		values, err := applyModifiers(valueModifiers, value)
+		if err != nil {
+			return "", err
+		}
+
+		// Values that expand into several variants match if any variant does
+		var filters []string
+		for _, value := range values {
+			var filter string
+			if reference, ok := value.(FieldRef); ok {
+				filter = fmt.Sprintf("%v %s %v", strings.ToLower(coerceString(field)), comparatorName(name), strings.ToLower(reference.Field))
+			} else if filter, err = comparator.Alters(field, value); err != nil {
+				return "", err
+			}
+			filters = append(filters, filter)
+		}
+		if len(filters) > 1 {
+			return "(" + strings.Join(filters, " or ") + ")", nil
+		}
+		return strings.Join(filters, ""), nil
+	}, nil
+}
+
+// GetMatcher returns a MatcherFunc that checks whether the value of an event field matches an expected value.
+// The modifiers are validated and applied to the expected value in the same way as for GetValues.
+func GetMatcher(modifiers ...string) (MatcherFunc, error) {
+	return getMatcher(Comparators, modifiers...)
+}
+
+// GetMatcherCaseSensitive returns a MatcherFunc like GetMatcher that compares strings case-sensitively.
+func GetMatcherCaseSensitive(modifiers ...string) (MatcherFunc, error) {
+	return getMatcher(ComparatorsCaseSensitive, modifiers...)
+}
+
+func getMatcher(comparators map[string]Comparator, modifiers ...string) (MatcherFunc, error) {
+	valueModifiers, name, err := parseModifiers(comparators, modifiers...)
+	if err != nil {
+		return nil, err
+	}
+	comparator := selectComparator(comparators, name, modifiers)
+
+	return func(actual, expected any) (bool, error) {
+		// Only the default and exists comparators can match a missing field, against the value null or false
+		if actual == nil && name != "" && name != "exists" {
+			return false, nil
+		}
+
+		values, err := applyModifiers(valueModifiers, expected)
+		if err != nil {
+			return false, err
+		}
+
+		// Values that expand into several variants match if any variant does
+		for _, value := range values {
+			if reference, ok := value.(FieldRef); ok {
+				return false, fmt.Errorf("unresolved reference to field %s", reference.Field)
+			}
+			match, err := comparator.Matches(actual, value)
+			if err != nil || match {
+				return match, err
+			}
+		}
+		return false, nil
+	}, nil
+}
+
+// GetConstraint returns a ConstraintFunc that turns an expected value into a Constraint on an event field.
+// The modifiers are validated in the same way as for GetValues.
+func GetConstraint(modifiers ...string) (ConstraintFunc, error) {
+	return syntheticDataGenerator.GetConstraint(modifiers...)
+}
+
+// GetConstraint returns a ConstraintFunc like the package-level GetConstraint, which picks variants of the expected value with the generator.
+func (g *SyntheticDataGenerator) GetConstraint(modifiers ...string) (ConstraintFunc, error) {
+	valueModifiers, name, err := parseModifiers(Comparators, modifiers...)
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		name = "equal"
+	}
+
+	return func(value any) (Constraint, error) {
+		values, err := applyModifiers(valueModifiers, value)
+		if err != nil {
+			return Constraint{}, err
+		}
+
+		// Values that expand into several variants are satisfied by any of them, so pick one at random
+		return Constraint{Operator: name, Value: g.pick(values)}, nil
+	}, nil
+}
+
+// GetValues returns the name of the comparator of a sequence of modifiers ("equal" if none is specified)
+// and a ValuesFunc that applies its value modifiers, so that the expected values can be rendered in other query languages.
+// The modifiers are validated by parseModifiers, like those of GetMatcher and GetConstraint.
+func GetValues(modifiers ...string) (string, ValuesFunc, error) {
+	valueModifiers, name, err := parseModifiers(Comparators, modifiers...)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return comparatorName(name), func(value any) ([]any, error) {
+		return applyModifiers(valueModifiers, value)
+	}, nil
+}
+
+// applyModifiers applies a sequence of value modifiers to a value and returns the resulting variants.
+// Most modifiers turn a value into a single new value, but some, like windash and base64offset, expand it into several variants,
+// each of which goes through the rest of the modifiers.
+func applyModifiers(valueModifiers []ValueModifier, value any) ([]any, error) {
+	values := []any{value}
+	for _, modifier := range valueModifiers {
+		var modified []any
+		for _, value := range values {
+			result, err := modifier.Modify(value)
+			if err != nil {
+				return nil, err
+			}
+			if variants, ok := result.([]any); ok {
+				modified = append(modified, variants...)
+			} else {
+				modified = append(modified, result)
+			}
+		}
+		values = modified
+	}
+	return values, nil
+}
+
+// selectComparator returns the comparator with the given name, or the default comparator if the name is empty.
+// The cased modifier switches to the case-sensitive comparators, whatever the mode of the rule is.
+func selectComparator(comparators map[string]Comparator, name string, modifiers []string) Comparator {
+	cased := false
+	for _, modifier := range modifiers {
+		cased = cased || modifier == "cased"
+	}
+	if cased {
+		comparators = ComparatorsCaseSensitive
+	}
+
+	if comparator := comparators[name]; comparator != nil {
+		return comparator
+	}
+	if cased {
+		return baseComparatorCS{}
+	}
+	return baseComparator{}
+}
+
+// bindGenerator returns a copy of a comparator that draws its synthetic values from the given generator.
+// Comparators that don't generate values are returned as is.
+// It only serves the deprecated GetComparator functions, and goes away with them.
+func bindGenerator(comparator Comparator, g *SyntheticDataGenerator) Comparator {
+	switch c := comparator.(type) {
+	case contains:
+		c.generator = g
+		return c
+	case endswith:
+		c.generator = g
+		return c
+	case startswith:
+		c.generator = g
+		return c
+	case containsCS:
+		c.generator = g
+		return c
+	case endswithCS:
+		c.generator = g
+		return c
+	case startswithCS:
+		c.generator = g
+		return c
+	case re:
+		c.generator = g
+		return c
+	case cidr:
+		c.generator = g
+		return c
+	case gt:
+		c.generator = g
+		return c
+	case gte:
+		c.generator = g
+		return c
+	case lt:
+		c.generator = g
+		return c
+	case lte:
+		c.generator = g
+		return c
+	default:
+		return comparator
+	}
+}
+
+// comparatorName returns the name of a comparator as written in queries, equal for the default comparator.
+func comparatorName(name string) string {
+	if name == "" {
+		return "equal"
+	}
+	return name
+}
+
+// parseModifiers splits a sequence of modifiers into its value modifiers and the name of its comparator.
+// The returned name is empty if no comparator is specified.
+// The re comparator can be followed by regex flags, which are turned into a value modifier that prefixes the pattern with them.
+func parseModifiers(comparators map[string]Comparator, modifiers ...string) ([]ValueModifier, string, error) {
 	// A valid sequence of modifiers is ([ValueModifier]*)[Comparator]?
-	// If a comparator is specified, it must be in the last position and cannot be succeeded by any other modifiers
+	// If a comparator is specified, it must be in the last position and cannot be succeeded by any other modifiers, except for regex flags
 	// If no comparator is specified, the default comparator is used
 	var valueModifiers []ValueModifier
-	var comparator Comparator
+	var name string
+	var flags regexFlags
 	for i, modifier := range modifiers {
 		comparatorModifier := comparators[modifier]
 		valueModifier := ValueModifiers[modifier]
+		flag, isFlag := RegexFlags[modifier]
 		switch {
 		// Validate correctness
+		case isFlag && name != "re":
+			return nil, "", fmt.Errorf("regex flag %s must follow the re modifier", modifier)
+		case isFlag:
+			flags += regexFlags(flag)
+			continue
 		case comparatorModifier == nil && valueModifier == nil:
-			return nil, fmt.Errorf("unknown modifier %s", modifier)
-		case i < len(modifiers)-1 && comparators[modifier] != nil:
-			return nil, fmt.Errorf("comparator modifier %s must be the last modifier", modifier)
+			return nil, "", fmt.Errorf("unknown modifier %s", modifier)
+		case i < len(modifiers)-1 && comparators[modifier] != nil && !(modifier == "re" && onlyRegexFlags(modifiers[i+1:])):
+			return nil, "", fmt.Errorf("comparator modifier %s must be the last modifier", modifier)
 
 		// Build up list of modifiers
 		case valueModifier != nil:
 			valueModifiers = append(valueModifiers, valueModifier)
 		case comparatorModifier != nil:
-			comparator = comparatorModifier
+			name = modifier
 		}
 	}
-	if comparator == nil {
-		comparator = baseComparator{}
+
+	if flags != "" {
+		valueModifiers = append(valueModifiers, flags)
 	}
+	return valueModifiers, name, nil
+}
 
-	return func(field, value any) (string, error) {
-		var err error
-		for _, modifier := range valueModifiers {
-			value, err = modifier.Modify(value)
-			if err != nil {
-				return "", err
-			}
+// onlyRegexFlags reports whether all the modifiers are regex flags.
+func onlyRegexFlags(modifiers []string) bool {
+	for _, modifier := range modifiers {
+		if _, ok := RegexFlags[modifier]; !ok {
+			return false
 		}
-
-		return comparator.Alters(field, value)
-	}, nil
+	}
+	return true
 }
 
+// Comparator compares the values of event fields with the expected values of a modifier.
 type Comparator interface {
+	// Alters renders a pseudo-query filter of the field with a synthetic value that satisfies the expected value.
+	//
+	// Deprecated: Alters only serves the deprecated GetComparator functions, the evaluator renders queries from query trees.
 	Alters(field any, value any) (string, error)
+	// Matches reports whether the actual value of an event field satisfies the expected value.
+	Matches(actual any, expected any) (bool, error)
 }
 
+// ComparatorFunc renders a pseudo-query filter of a field with a synthetic value, as returned by GetComparator.
+//
+// Deprecated: Use GetValues, GetConstraint and GetMatcher instead.
 type ComparatorFunc func(field, value any) (string, error)
 
+// MatcherFunc checks whether the actual value of an event field matches an expected value.
+type MatcherFunc func(actual, expected any) (bool, error)
+
+// Constraint is a single requirement that the value of an event field has to satisfy.
+// For example, the `contains` modifier with the value "foo" becomes Constraint{Operator: "contains", Value: "foo"}.
+// A negated constraint is a requirement that the value must not satisfy, used to generate near-miss values.
+type Constraint struct {
+	Operator string // The name of the comparator the value must satisfy ("equal" if no comparator is specified)
+	Value    any    // The expected value after all value modifiers have been applied
+	Negated  bool   // Whether the value must not satisfy the comparator
+}
+
+// Negate returns the constraint that is satisfied by exactly the values that don't satisfy c.
+func (c Constraint) Negate() Constraint {
+	c.Negated = !c.Negated
+	return c
+}
+
+// FieldRef is the expected value of a field matcher with the fieldref modifier: the value of another field of the same event.
+// References can't be resolved by the modifiers themselves, the rule evaluator replaces them with the value of the referenced field.
+type FieldRef struct {
+	Field string // The name of the referenced field
+}
+
+// ConstraintFunc converts an expected value into a Constraint.
+type ConstraintFunc func(value any) (Constraint, error)
+
+// ValuesFunc applies value modifiers to an expected value and returns the variants it expands into, any of which the field has to match.
+// The variant of a value with the fieldref modifier is a FieldRef.
+type ValuesFunc func(value any) ([]any, error)
+
 // ValueModifier modifies the expected value before it is passed to the comparator.
 // For example, the `base64` modifier converts the expected value to base64.
 type ValueModifier interface {
@@ -76,6 +363,7 @@ type ValueModifier interface {
 }
 
 var Comparators = map[string]Comparator{
+	"exists":     exists{},
 	"contains":   contains{generator: syntheticDataGenerator},
 	"endswith":   endswith{generator: syntheticDataGenerator},
 	"startswith": startswith{generator: syntheticDataGenerator},
@@ -88,6 +376,7 @@ var Comparators = map[string]Comparator{
 }
 
 var ComparatorsCaseSensitive = map[string]Comparator{
+	"exists":     exists{},
 	"contains":   containsCS{generator: syntheticDataGenerator},
 	"endswith":   endswithCS{generator: syntheticDataGenerator},
 	"startswith": startswithCS{generator: syntheticDataGenerator},
@@ -100,8 +389,28 @@ var ComparatorsCaseSensitive = map[string]Comparator{
 }
 
 var ValueModifiers = map[string]ValueModifier{
-	"base64": b64{},
-	"wide":   wide{},
+	"base64":       b64{},
+	"base64offset": b64offset{},
+	"wide":         utf16LE{},
+	"utf16le":      utf16LE{},
+	"utf16be":      utf16BE{},
+	"utf16":        utf16BOM{},
+	"windash":      windash{},
+	"fieldref":     fieldref{},
+
+	// Markers don't change the value, they are handled by the comparator or the rule evaluator
+	"cased":  marker{},
+	"expand": marker{},
+}
+
+// RegexFlags maps the sub-modifiers of the re modifier to the flags of the Go regex syntax they enable.
+var RegexFlags = map[string]string{
+	"i":          "i", // Case-insensitive matching
+	"ignorecase": "i",
+	"m":          "m", // ^ and $ match at the start and end of every line
+	"multiline":  "m",
+	"s":          "s", // . matches newlines as well
+	"dotall":     "s",
 }
 
 type baseComparator struct{}
@@ -116,84 +425,212 @@ func (baseComparator) Alters(field, value any) (string, error) {
 	}
 }
 
+func (baseComparator) Matches(actual, expected any) (bool, error) {
+	switch {
+	case actual == nil && expected == "null":
+		return true, nil
+	case actual == nil:
+		return false, nil
+	default:
+		// The Sigma spec defines that by default comparisons are case-insensitive
+		return globMatch(strings.ToLower(coerceString(actual)), strings.ToLower(coerceString(expected))), nil
+	}
+}
+
+// baseComparatorCS is the default comparator of fields with the cased modifier, it compares values case-sensitively.
+type baseComparatorCS struct{}
+
+func (baseComparatorCS) Alters(field, value any) (string, error) {
+	switch {
+	case field == nil && value == "null":
+		return "", nil
+	default:
+		return fmt.Sprintf("%v equal '%v'", strings.ToLower(coerceString(field)), coerceString(value)), nil
+	}
+}
+
+func (baseComparatorCS) Matches(actual, expected any) (bool, error) {
+	switch {
+	case actual == nil && expected == "null":
+		return true, nil
+	case actual == nil:
+		return false, nil
+	default:
+		return globMatch(coerceString(actual), coerceString(expected)), nil
+	}
+}
+
+// exists checks whether a field is present in the event, or absent if the expected value is false.
+type exists struct{}
+
+func (exists) Alters(field any, value any) (string, error) {
+	expected, err := strconv.ParseBool(coerceString(value))
+	if err != nil {
+		return "", fmt.Errorf("expected true or false for the exists modifier, got %v", value)
+	}
+	if !expected {
+		return fmt.Sprintf("not %v exists", strings.ToLower(coerceString(field))), nil
+	}
+	return fmt.Sprintf("%v exists", strings.ToLower(coerceString(field))), nil
+}
+
+func (exists) Matches(actual any, expected any) (bool, error) {
+	exists, err := strconv.ParseBool(coerceString(expected))
+	if err != nil {
+		return false, fmt.Errorf("expected true or false for the exists modifier, got %v", expected)
+	}
+	return (actual != nil) == exists, nil
+}
+
 type contains struct {
 	generator *SyntheticDataGenerator
 }
 
 func (c contains) Alters(field, value any) (string, error) {
-	syntheticValue := c.generator.GenerateSyntheticValue(coerceString(value), "contains")
+	syntheticValue, err := c.generator.GenerateSyntheticValue(coerceString(value), "contains")
+	if err != nil {
+		return "", err
+	}
 	return fmt.Sprintf("%v contains '%v'", strings.ToLower(coerceString(field)), strings.ToLower(syntheticValue)), nil
 }
 
+func (contains) Matches(actual, expected any) (bool, error) {
+	return matchSubstring(strings.ToLower(coerceString(actual)), strings.ToLower(coerceString(expected)), "contains"), nil
+}
+
 type endswith struct {
 	generator *SyntheticDataGenerator
 }
 
 func (e endswith) Alters(field, value any) (string, error) {
-	syntheticValue := e.generator.GenerateSyntheticValue(coerceString(value), "endswith")
+	syntheticValue, err := e.generator.GenerateSyntheticValue(coerceString(value), "endswith")
+	if err != nil {
+		return "", err
+	}
 	return fmt.Sprintf("%v endswith '%v'", strings.ToLower(coerceString(field)), strings.ToLower(syntheticValue)), nil
 }
 
+func (endswith) Matches(actual, expected any) (bool, error) {
+	return matchSubstring(strings.ToLower(coerceString(actual)), strings.ToLower(coerceString(expected)), "endswith"), nil
+}
+
 type startswith struct {
 	generator *SyntheticDataGenerator
 }
 
 func (s startswith) Alters(field, value any) (string, error) {
-	syntheticValue := s.generator.GenerateSyntheticValue(coerceString(value), "startswith")
+	syntheticValue, err := s.generator.GenerateSyntheticValue(coerceString(value), "startswith")
+	if err != nil {
+		return "", err
+	}
 	return fmt.Sprintf("%v startswith '%v'", strings.ToLower(coerceString(field)), strings.ToLower(syntheticValue)), nil
 }
 
+func (startswith) Matches(actual, expected any) (bool, error) {
+	return matchSubstring(strings.ToLower(coerceString(actual)), strings.ToLower(coerceString(expected)), "startswith"), nil
+}
+
 type containsCS struct {
 	generator *SyntheticDataGenerator
 }
 
 func (c containsCS) Alters(field, value any) (string, error) {
-	syntheticValue := c.generator.GenerateSyntheticValue(coerceString(value), "contains")
+	syntheticValue, err := c.generator.GenerateSyntheticValue(coerceString(value), "contains")
+	if err != nil {
+		return "", err
+	}
 	return fmt.Sprintf("%v contains '%v'", strings.ToLower(coerceString(field)), syntheticValue), nil
 }
 
+func (containsCS) Matches(actual, expected any) (bool, error) {
+	return matchSubstring(coerceString(actual), coerceString(expected), "contains"), nil
+}
+
 type endswithCS struct {
 	generator *SyntheticDataGenerator
 }
 
 func (e endswithCS) Alters(field, value any) (string, error) {
-	syntheticValue := e.generator.GenerateSyntheticValue(coerceString(value), "endswith")
+	syntheticValue, err := e.generator.GenerateSyntheticValue(coerceString(value), "endswith")
+	if err != nil {
+		return "", err
+	}
 	return fmt.Sprintf("%v endswith '%v'", strings.ToLower(coerceString(field)), syntheticValue), nil
 }
 
+func (endswithCS) Matches(actual, expected any) (bool, error) {
+	return matchSubstring(coerceString(actual), coerceString(expected), "endswith"), nil
+}
+
 type startswithCS struct {
 	generator *SyntheticDataGenerator
 }
 
 func (s startswithCS) Alters(field, value any) (string, error) {
-	syntheticValue := s.generator.GenerateSyntheticValue(coerceString(value), "startswith")
+	syntheticValue, err := s.generator.GenerateSyntheticValue(coerceString(value), "startswith")
+	if err != nil {
+		return "", err
+	}
 	return fmt.Sprintf("%v startswith '%v'", strings.ToLower(coerceString(field)), syntheticValue), nil
 }
 
+func (startswithCS) Matches(actual, expected any) (bool, error) {
+	return matchSubstring(coerceString(actual), coerceString(expected), "startswith"), nil
+}
+
 type re struct {
 	generator *SyntheticDataGenerator
 }
 
 func (r re) Alters(field any, value any) (string, error) {
-	syntheticValue := r.generator.GenerateSyntheticValue(coerceString(value), "re")
+	syntheticValue, err := r.generator.GenerateSyntheticValue(coerceString(value), "re")
+	if err != nil {
+		return "", err
+	}
 	return fmt.Sprintf("%v equal '%v'", strings.ToLower(coerceString(field)), syntheticValue), nil
 }
 
+func (re) Matches(actual any, expected any) (bool, error) {
+	return regexp.MatchString(coerceString(expected), coerceString(actual))
+}
+
 type cidr struct {
 	generator *SyntheticDataGenerator
 }
 
 func (c cidr) Alters(field any, value any) (string, error) {
-	syntheticValue := c.generator.GenerateSyntheticValue(coerceString(value), "cidr")
+	syntheticValue, err := c.generator.GenerateSyntheticValue(coerceString(value), "cidr")
+	if err != nil {
+		return "", err
+	}
 	return fmt.Sprintf("%v equal '%v'", strings.ToLower(coerceString(field)), syntheticValue), nil
 }
 
+func (cidr) Matches(actual any, expected any) (bool, error) {
+	_, cidr, err := net.ParseCIDR(coerceString(expected))
+	if err != nil {
+		return false, err
+	}
+
+	ip := net.ParseIP(coerceString(actual))
+	return ip != nil && cidr.Contains(ip), nil
+}
+
 type gt struct {
 	generator *SyntheticDataGenerator
 }
 
 func (g gt) Alters(field any, value any) (string, error) {
-	return fmt.Sprintf("%v gt '%v'", strings.ToLower(coerceString(field)), coerceString(value)), nil
+	syntheticValue, err := g.generator.GenerateSyntheticValue(coerceString(value), "gt")
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%v equal '%v'", strings.ToLower(coerceString(field)), syntheticValue), nil
+}
+
+func (gt) Matches(actual any, expected any) (bool, error) {
+	comparison, ok, err := compareNumeric(actual, expected)
+	return ok && comparison > 0, err
 }
 
 type gte struct {
@@ -201,7 +638,16 @@ type gte struct {
 }
 
 func (g gte) Alters(field any, value any) (string, error) {
-	return fmt.Sprintf("%v gte '%v'", strings.ToLower(coerceString(field)), coerceString(value)), nil
+	syntheticValue, err := g.generator.GenerateSyntheticValue(coerceString(value), "gte")
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%v equal '%v'", strings.ToLower(coerceString(field)), syntheticValue), nil
+}
+
+func (gte) Matches(actual any, expected any) (bool, error) {
+	comparison, ok, err := compareNumeric(actual, expected)
+	return ok && comparison >= 0, err
 }
 
 type lt struct {
@@ -209,7 +655,16 @@ type lt struct {
 }
 
 func (l lt) Alters(field any, value any) (string, error) {
-	return fmt.Sprintf("%v lt '%v'", strings.ToLower(coerceString(field)), coerceString(value)), nil
+	syntheticValue, err := l.generator.GenerateSyntheticValue(coerceString(value), "lt")
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%v equal '%v'", strings.ToLower(coerceString(field)), syntheticValue), nil
+}
+
+func (lt) Matches(actual any, expected any) (bool, error) {
+	comparison, ok, err := compareNumeric(actual, expected)
+	return ok && comparison < 0, err
 }
 
 type lte struct {
@@ -217,7 +672,16 @@ type lte struct {
 }
 
 func (l lte) Alters(field any, value any) (string, error) {
-	return fmt.Sprintf("%v lte '%v'", strings.ToLower(coerceString(field)), coerceString(value)), nil
+	syntheticValue, err := l.generator.GenerateSyntheticValue(coerceString(value), "lte")
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%v equal '%v'", strings.ToLower(coerceString(field)), syntheticValue), nil
+}
+
+func (lte) Matches(actual any, expected any) (bool, error) {
+	comparison, ok, err := compareNumeric(actual, expected)
+	return ok && comparison <= 0, err
 }
 
 type b64 struct{}
@@ -226,15 +690,215 @@ func (b64) Modify(value any) (any, error) {
 	return base64.StdEncoding.EncodeToString([]byte(coerceString(value))), nil
 }
 
-type wide struct{}
+// b64offset encodes the value in base64 at each of the three offsets it can have within a longer encoded string.
+// Each variant leaves out the leading and trailing characters that depend on the data around the value, so it's meant to be used with contains.
+type b64offset struct{}
+
+func (b64offset) Modify(value any) (any, error) {
+	data := []byte(coerceString(value))
+	starts := []int{0, 2, 3}
+	ends := []int{0, 3, 2}
+
+	variants := make([]any, 0, 3)
+	for offset := 0; offset < 3; offset++ {
+		padded := append(make([]byte, offset), data...)
+		encoded := base64.StdEncoding.EncodeToString(padded)
+
+		// Trim the characters that encode the leading bytes, and the last character and padding that depend on the bytes after the value
+		end := len(encoded) - ends[(len(data)+offset)%3]
+		if end < starts[offset] {
+			end = starts[offset]
+		}
+		variants = append(variants, encoded[starts[offset]:end])
+	}
+	return variants, nil
+}
+
+// utf16LE encodes the value in UTF-16 little endian, like the wide modifier.
+type utf16LE struct{}
+
+func (utf16LE) Modify(value any) (any, error) {
+	return encodeUTF16(coerceString(value), binary.LittleEndian), nil
+}
+
+// utf16BE encodes the value in UTF-16 big endian.
+type utf16BE struct{}
 
-func (wide) Modify(value any) (any, error) {
-	runes := utf16.Encode([]rune(coerceString(value)))
+func (utf16BE) Modify(value any) (any, error) {
+	return encodeUTF16(coerceString(value), binary.BigEndian), nil
+}
+
+// utf16BOM encodes the value in UTF-16 little endian, preceded by a byte order mark.
+type utf16BOM struct{}
+
+func (utf16BOM) Modify(value any) (any, error) {
+	return "\xff\xfe" + encodeUTF16(coerceString(value), binary.LittleEndian), nil
+}
+
+// encodeUTF16 encodes a string in UTF-16 with the given byte order.
+func encodeUTF16(value string, order binary.ByteOrder) string {
+	runes := utf16.Encode([]rune(value))
 	bytes := make([]byte, 2*len(runes))
 	for i, r := range runes {
-		binary.LittleEndian.PutUint16(bytes[i*2:], r)
+		order.PutUint16(bytes[i*2:], r)
+	}
+	return coerceString(bytes)
+}
+
+// Dashes lists the characters that Windows accepts in front of command-line flags, used by the windash modifier.
+var Dashes = []string{"-", "/", "\u2013", "\u2014", "\u2015"}
+
+// windashFlag matches the dashes that start a command-line flag, i.e. the ones that don't follow a word character and precede one,
+// so flags after a space, a quote or an equals sign are all varied, e.g. in "-c", 'cmd "/c"' and "--mode=-x".
+var windashFlag = regexp.MustCompile(`\B[-/]\b`)
+
+// MaxDashVariants caps the number of variants that the windash modifier expands a value into, since every flag multiplies them by len(Dashes).
+var MaxDashVariants = 125
+
+// windash expands the value into every combination of the dashes that Windows accepts in front of command-line flags.
+// Only the first flags are expanded, as many as fit in MaxDashVariants, the later ones keep the dash of the rule.
+type windash struct{}
+
+func (windash) Modify(value any) (any, error) {
+	text := coerceString(value)
+
+	// Find the position of every dash that starts a flag
+	var positions []int
+	for _, match := range windashFlag.FindAllStringIndex(text, -1) {
+		positions = append(positions, match[0])
+	}
+	if len(positions) == 0 {
+		return text, nil
+	}
+
+	variants := []any{""}
+	previous := 0
+	for _, position := range positions {
+		// The variants of the next flag wouldn't fit, so the rest of the value is kept as is
+		if len(variants)*len(Dashes) > MaxDashVariants {
+			break
+		}
+		var expanded []any
+		for _, variant := range variants {
+			for _, dash := range Dashes {
+				expanded = append(expanded, coerceString(variant)+text[previous:position]+dash)
+			}
+		}
+		variants = expanded
+		previous = position + 1
+	}
+	for i, variant := range variants {
+		variants[i] = coerceString(variant) + text[previous:]
+	}
+	return variants, nil
+}
+
+// regexFlags prefixes a regex pattern with the flags of the sub-modifiers of the re modifier, e.g. (?i) for re|i.
+type regexFlags string
+
+func (f regexFlags) Modify(value any) (any, error) {
+	return "(?" + string(f) + ")" + coerceString(value), nil
+}
+
+// fieldref turns the value into a reference to the field it names.
+type fieldref struct{}
+
+func (fieldref) Modify(value any) (any, error) {
+	return FieldRef{Field: coerceString(value)}, nil
+}
+
+// marker is a modifier that leaves the value unchanged, such as cased and expand, which are handled elsewhere.
+type marker struct{}
+
+func (marker) Modify(value any) (any, error) {
+	return value, nil
+}
+
+// globMatch reports whether the value matches a Sigma wildcard pattern, where * matches any sequence of characters and ? any single character.
+// Wildcards can be escaped with a backslash.
+func globMatch(value, pattern string) bool {
+	if !strings.ContainsAny(pattern, "*?") {
+		return value == pattern
+	}
+
+	var expression strings.Builder
+	expression.WriteString("(?s)^")
+	for i := 0; i < len(pattern); i++ {
+		switch char := pattern[i]; {
+		case char == '\\' && i+1 < len(pattern) && strings.ContainsRune("*?\\", rune(pattern[i+1])):
+			expression.WriteString(regexp.QuoteMeta(pattern[i+1 : i+2]))
+			i++
+		case char == '*':
+			expression.WriteString(".*")
+		case char == '?':
+			expression.WriteString(".")
+		default:
+			expression.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	expression.WriteString("$")
+
+	return regexp.MustCompile(expression.String()).MatchString(value)
+}
+
+// GlobPattern returns the Sigma wildcard pattern that a value has to match for the contains, startswith or endswith comparator,
+// e.g. *value* for contains, and the value itself for other comparators. The value may contain wildcards of its own.
+// A lone trailing backslash of the value is escaped, so that it isn't taken as the escape of the appended wildcard.
+func GlobPattern(operator, value string) string {
+	switch operator {
+	case "contains", "startswith":
+		// A run of backslashes is read in escaped pairs, so an odd one ends with a literal backslash
+		trailing := len(value) - len(strings.TrimRight(value, `\`))
+		if trailing%2 == 1 {
+			value += `\`
+		}
+		if operator == "startswith" {
+			return value + "*"
+		}
+		return "*" + value + "*"
+	case "endswith":
+		return "*" + value
+	default:
+		return value
+	}
+}
+
+// matchSubstring reports whether the value matches the expected value of a contains, startswith or endswith comparator.
+// Wildcards in the expected value are matched with globMatch, like the patterns that the backends render for it.
+func matchSubstring(value, expected, operator string) bool {
+	if !strings.ContainsAny(expected, `*?\`) {
+		switch operator {
+		case "startswith":
+			return strings.HasPrefix(value, expected)
+		case "endswith":
+			return strings.HasSuffix(value, expected)
+		default:
+			return strings.Contains(value, expected)
+		}
+	}
+	return globMatch(value, GlobPattern(operator, expected))
+}
+
+// compareNumeric compares the actual and expected values as numbers, returning -1, 0 or +1.
+// The boolean result is false if the actual value isn't a number, in which case it can't match any comparison.
+func compareNumeric(actual, expected any) (int, bool, error) {
+	expectedNumber, err := strconv.ParseFloat(coerceString(expected), 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("expected a numeric value, got %v", expected)
+	}
+	actualNumber, err := strconv.ParseFloat(coerceString(actual), 64)
+	if err != nil {
+		return 0, false, nil
+	}
+
+	switch {
+	case actualNumber < expectedNumber:
+		return -1, true, nil
+	case actualNumber > expectedNumber:
+		return 1, true, nil
+	default:
+		return 0, true, nil
 	}
-	return coerceString(bytes), nil
 }
 
 func coerceString(v interface{}) string {
@@ -0,0 +1,199 @@
+package modifiers_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mtnmunuklu/logen/sigma/sevaluator/modifiers"
+)
+
+func TestMatcherValueModifiers(t *testing.T) {
+	tt := []struct {
+		Modifiers []string
+		Expected  string
+		Actual    any
+		Match     bool
+	}{
+		{[]string{"windash", "contains"}, "-exec bypass", "powershell /exec bypass", true},
+		{[]string{"windash", "contains"}, "-exec bypass", "powershell \u2013exec bypass", true},
+		{[]string{"windash", "contains"}, "-exec bypass", "powershell +exec bypass", false},
+		{[]string{"windash"}, "cmd -c -x", "cmd /c -x", true},
+		{[]string{"windash", "contains"}, `"-enc`, `powershell "/enc AAAA"`, true},
+		{[]string{"windash", "contains"}, "mode=-x", "mode=\u2014x", true},
+		{[]string{"windash", "contains"}, "wmic'-format", "wmic'/format", true},
+		{[]string{"windash", "contains"}, "co-author", "co/author", false},
+		{[]string{"base64offset", "contains"}, "/bin/sh", "ZXhlYyAvYmluL3No", true},
+		{[]string{"base64offset", "contains"}, "/bin/sh", "IC9iaW4vc2g=", true},
+		{[]string{"base64offset", "contains"}, "/bin/sh", "L2Jpbi9zaA==", true},
+		{[]string{"base64offset", "contains"}, "/bin/sh", "L2Jpbi9iYXNo", false},
+		{[]string{"utf16le", "base64"}, "ping", "cABpAG4AZwA=", true},
+		{[]string{"wide", "base64"}, "ping", "cABpAG4AZwA=", true},
+		{[]string{"utf16be", "base64"}, "ping", "AHAAaQBuAGc=", true},
+		{[]string{"utf16", "base64"}, "ping", "//5wAGkAbgBnAA==", true},
+		{[]string{"exists"}, "true", "anything", true},
+		{[]string{"exists"}, "true", nil, false},
+		{[]string{"exists"}, "false", nil, true},
+		{[]string{"exists"}, "false", "anything", false},
+		{[]string{"cased"}, "Invoke-Mimikatz", "Invoke-Mimikatz", true},
+		{[]string{"cased"}, "Invoke-Mimikatz", "invoke-mimikatz", false},
+		{[]string{"cased", "contains"}, "Mimi", "Invoke-mimikatz", false},
+		{[]string{"expand"}, "C:\\Windows", "c:\\windows", true},
+		{[]string{"re"}, "^cmd\\.exe$", "CMD.exe", false},
+		{[]string{"re", "i"}, "^cmd\\.exe$", "CMD.exe", true},
+		{[]string{"re", "ignorecase"}, "^cmd\\.exe$", "CMD.exe", true},
+		{[]string{"re", "m"}, "^whoami$", "cd /\nwhoami\nexit", true},
+		{[]string{"re"}, "^whoami$", "cd /\nwhoami\nexit", false},
+		{[]string{"re", "s"}, "begin.end", "begin\nend", true},
+		{[]string{"re", "i", "s"}, "BEGIN.END", "begin\nend", true},
+	}
+
+	for _, tc := range tt {
+		t.Run(strings.Join(tc.Modifiers, "|"), func(t *testing.T) {
+			matcher, err := modifiers.GetMatcher(tc.Modifiers...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			match, err := matcher(tc.Actual, tc.Expected)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if match != tc.Match {
+				t.Errorf("expected %v to match %v: %v, got %v", tc.Actual, tc.Expected, tc.Match, match)
+			}
+		})
+	}
+}
+
+// TestMatcherWildcards checks that the substring comparators match the Sigma wildcards in their expected values, like the rendered queries do.
+func TestMatcherWildcards(t *testing.T) {
+	tt := []struct {
+		Modifiers []string
+		Expected  string
+		Actual    string
+		Match     bool
+	}{
+		{[]string{"contains"}, "ssh*root", "sudo SSH as root", true},
+		{[]string{"contains"}, "ssh*root", "sudo root ssh", false},
+		{[]string{"contains"}, `ssh\*root`, "ssh as root", false},
+		{[]string{"contains"}, `ssh\*root`, "x ssh*root x", true},
+		{[]string{"contains"}, "a?c", "xxabcxx", true},
+		{[]string{"contains"}, `C:\Windows\`, `C:\Windows\System32\cmd.exe`, true},
+		{[]string{"contains"}, `C:\Windows\`, `C:\Windows`, false},
+		{[]string{"startswith"}, `C:\Windows\`, `c:\windows\system32`, true},
+		{[]string{"startswith"}, `C:*cmd`, `C:\Windows\cmd.exe`, true},
+		{[]string{"startswith"}, `C:*cmd`, `D:\Windows\cmd.exe`, false},
+		{[]string{"endswith"}, `evil*.exe`, `C:\Temp\evil64.EXE`, true},
+		{[]string{"endswith"}, `evil*.exe`, `evil.exe.txt`, false},
+		{[]string{"endswith"}, `\*.exe`, `C:\Temp\evil.exe`, false},
+		{[]string{"endswith"}, `\*.exe`, `C:\Temp\*.exe`, true},
+		{[]string{"cased", "contains"}, "A?C", "xAbCx", true},
+		{[]string{"cased", "contains"}, "A?C", "xabcx", false},
+		{[]string{"cased", "endswith"}, "*.Exe", "evil.exe", false},
+	}
+
+	for _, tc := range tt {
+		matcher, err := modifiers.GetMatcher(tc.Modifiers...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if match, err := matcher(tc.Actual, tc.Expected); err != nil || match != tc.Match {
+			t.Errorf("expected %s %s to match %s: %v, got %v (%v)", strings.Join(tc.Modifiers, "|"), tc.Expected, tc.Actual, tc.Match, match, err)
+		}
+	}
+
+	// A lone trailing backslash doesn't escape the appended wildcard
+	if pattern := modifiers.GlobPattern("contains", `C:\Windows\`); pattern != `*C:\Windows\\*` {
+		t.Errorf("unexpected pattern %s", pattern)
+	}
+}
+
+func TestRegexFlagErrors(t *testing.T) {
+	for _, sequence := range [][]string{{"i"}, {"contains", "i"}, {"re", "i", "contains"}} {
+		if _, err := modifiers.GetMatcher(sequence...); err == nil {
+			t.Errorf("expected an error for modifiers %v", sequence)
+		}
+	}
+}
+
+func TestComparatorVariants(t *testing.T) {
+	tt := []struct {
+		Modifiers []string
+		Value     string
+		Contains  []string
+	}{
+		{[]string{"windash"}, "-c", []string{"'-c'", "'/c'", "'\u2013c'", "'\u2014c'", "'\u2015c'"}},
+		{[]string{"base64offset"}, "/bin/sh", []string{"'l2jpbi9za'", "'9iaw4vc2'", "'vymlul3no'"}},
+		{[]string{"fieldref"}, "TargetUser", []string{"user equal targetuser"}},
+		{[]string{"exists"}, "false", []string{"not user exists"}},
+		{[]string{"cased"}, "SYSTEM", []string{"user equal 'SYSTEM'"}},
+	}
+
+	for _, tc := range tt {
+		t.Run(strings.Join(tc.Modifiers, "|"), func(t *testing.T) {
+			comparator, err := modifiers.GetComparator(tc.Modifiers...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			query, err := comparator("User", tc.Value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, expected := range tc.Contains {
+				if !strings.Contains(query, expected) {
+					t.Errorf("expected query %s to contain %s", query, expected)
+				}
+			}
+		})
+	}
+}
+
+func TestConstraintVariants(t *testing.T) {
+	toConstraint, err := modifiers.GetConstraint("windash", "contains")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every variant should be generated sooner or later
+	seen := map[string]bool{}
+	for i := 0; i < 200; i++ {
+		constraint, err := toConstraint("-enc")
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen[constraint.Value.(string)] = true
+	}
+	if len(seen) != len(modifiers.Dashes) {
+		t.Errorf("expected %d variants, got %v", len(modifiers.Dashes), seen)
+	}
+
+	// Values with many flags are only expanded up to the maximum number of variants
+	windash, err := modifiers.GetComparator("windash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	query, err := windash("CommandLine", "cmd -a -b -c -d -e -f -g -h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if variants := strings.Count(query, " or ") + 1; variants != modifiers.MaxDashVariants {
+		t.Errorf("expected %d variants, got %d", modifiers.MaxDashVariants, variants)
+	}
+	if !strings.Contains(query, "'cmd /a /b /c -d -e -f -g -h'") {
+		t.Errorf("expected the later flags to keep their dash in %s", query)
+	}
+
+	absent, err := modifiers.GetConstraint("exists")
+	if err != nil {
+		t.Fatal(err)
+	}
+	constraint, err := absent("false")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, err := modifiers.Synthesize(constraint); err != nil || value != nil {
+		t.Errorf("expected no value for a field that must be absent, got %v", value)
+	}
+	if value, err := modifiers.Synthesize(constraint.Negate()); err != nil || value == nil {
+		t.Errorf("expected a value for a field that must be present")
+	}
+}
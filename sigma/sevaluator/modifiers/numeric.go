@@ -0,0 +1,199 @@
+package modifiers
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// NumericSpan is how far beyond a threshold numbers are generated for comparisons that are only bounded on one side, e.g. gt 5.
+const NumericSpan = 100
+
+// numericOperators maps the numeric comparators to the comparator that is satisfied by exactly the numbers they aren't.
+var numericOperators = map[string]string{
+	"gt":  "lte",
+	"gte": "lt",
+	"lt":  "gte",
+	"lte": "gt",
+}
+
+// isNumeric reports whether a constraint is a numeric comparison.
+func isNumeric(constraint Constraint) bool {
+	_, ok := numericOperators[constraint.Operator]
+	return ok
+}
+
+// numericRange is the range of numbers that satisfies a set of numeric comparisons.
+type numericRange struct {
+	low, high         float64 // The bounds of the range, infinite if the range is unbounded on that side
+	lowOpen, highOpen bool    // Whether the bounds themselves are left out of the range
+	float             bool    // Whether any threshold is a float, in which case floats are generated instead of integers
+}
+
+// newNumericRange returns the range of numbers that satisfies every numeric constraint.
+// It returns false if a threshold isn't a number.
+func newNumericRange(constraints []Constraint) (numericRange, bool) {
+	r := numericRange{low: math.Inf(-1), high: math.Inf(1)}
+	for _, constraint := range constraints {
+		if !isNumeric(constraint) {
+			continue
+		}
+
+		text := coerceString(constraint.Value)
+		threshold, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return numericRange{}, false
+		}
+		switch constraint.Value.(type) {
+		case float32, float64:
+			r.float = true
+		default:
+			if _, err := strconv.ParseInt(text, 10, 64); err != nil {
+				r.float = true
+			}
+		}
+
+		operator := constraint.Operator
+		if constraint.Negated {
+			operator = numericOperators[operator]
+		}
+		r.restrict(operator, threshold)
+	}
+	return r, true
+}
+
+// restrict narrows the range to the numbers that satisfy a comparison with a threshold.
+func (r *numericRange) restrict(operator string, threshold float64) {
+	switch operator {
+	case "gt", "gte":
+		open := operator == "gt"
+		if threshold > r.low || (threshold == r.low && open) {
+			r.low, r.lowOpen = threshold, open
+		}
+	case "lt", "lte":
+		open := operator == "lt"
+		if threshold < r.high || (threshold == r.high && open) {
+			r.high, r.highOpen = threshold, open
+		}
+	}
+}
+
+// generate returns a random number within the range, an int64 or a float64 depending on the type of the thresholds.
+// It returns false if the range is empty, e.g. for gt 5 and lt 3.
+func (r numericRange) generate(g *SyntheticDataGenerator) (any, bool) {
+	if r.float {
+		return r.generateFloat(g)
+	}
+	return r.generateInteger(g)
+}
+
+// generateInteger returns a random integer within the range.
+func (r numericRange) generateInteger(g *SyntheticDataGenerator) (any, bool) {
+	low, high := math.Ceil(r.low), math.Floor(r.high)
+	if r.lowOpen && low == r.low {
+		low++
+	}
+	if r.highOpen && high == r.high {
+		high--
+	}
+
+	// Ranges that are unbounded on a side reach NumericSpan beyond the other bound, or around zero
+	switch {
+	case math.IsInf(low, -1) && math.IsInf(high, 1):
+		low, high = 0, NumericSpan
+	case math.IsInf(low, -1):
+		low = high - NumericSpan
+	case math.IsInf(high, 1):
+		high = low + NumericSpan
+	}
+	if low > high || low < math.MinInt64 || high > math.MaxInt64 {
+		return nil, false
+	}
+
+	span := high - low
+	if span >= math.MaxInt64 {
+		span = math.MaxInt64 - 1
+	}
+	return int64(low) + g.randomGenerator.Int63n(int64(span)+1), true
+}
+
+// generateFloat returns a random float within the range, rounded to two decimals if that keeps it within the range.
+func (r numericRange) generateFloat(g *SyntheticDataGenerator) (any, bool) {
+	low, high := r.low, r.high
+	switch {
+	case math.IsInf(low, -1) && math.IsInf(high, 1):
+		low, high = 0, NumericSpan
+	case math.IsInf(low, -1):
+		low = high - NumericSpan
+	case math.IsInf(high, 1):
+		high = low + NumericSpan
+	}
+	if low > high || (low == high && (r.lowOpen || r.highOpen)) {
+		return nil, false
+	}
+
+	value := low + g.randomGenerator.Float64()*(high-low)
+	if !r.contains(value) {
+		value = low + (high-low)/2
+	}
+	if rounded := math.Round(value*100) / 100; r.contains(rounded) {
+		value = rounded
+	}
+	if !r.contains(value) {
+		return nil, false
+	}
+	return value, true
+}
+
+// contains reports whether a number is within the range.
+func (r numericRange) contains(value float64) bool {
+	if value < r.low || (value == r.low && r.lowOpen) {
+		return false
+	}
+	if value > r.high || (value == r.high && r.highOpen) {
+		return false
+	}
+	return true
+}
+
+// generateNumeric generates a number that satisfies all the numeric constraints.
+// If they can't be satisfied, the threshold of the first one is returned as is.
+func (g *SyntheticDataGenerator) generateNumeric(constraints []Constraint) any {
+	if r, ok := newNumericRange(constraints); ok {
+		if value, ok := r.generate(g); ok {
+			return value
+		}
+	}
+	for _, constraint := range constraints {
+		if isNumeric(constraint) {
+			return constraint.Value
+		}
+	}
+	return nil
+}
+
+// formatNumber formats a generated number, keeping a decimal point for floats so that their type is preserved.
+func formatNumber(value any) string {
+	switch number := value.(type) {
+	case float64:
+		text := strconv.FormatFloat(number, 'f', -1, 64)
+		if !strings.ContainsAny(text, ".eEnN") {
+			text += ".0"
+		}
+		return text
+	default:
+		return coerceString(value)
+	}
+}
+
+// parseNumber converts a number formatted as a string into an int64 or a float64, depending on its format.
+// Other strings are returned as is.
+func parseNumber(text string) any {
+	if integer, err := strconv.ParseInt(text, 10, 64); err == nil {
+		return integer
+	}
+	if float, err := strconv.ParseFloat(text, 64); err == nil {
+		return float
+	}
+	return text
+}
@@ -2,164 +2,695 @@ package modifiers
 
 import (
 	"fmt"
+	"math/big"
 	"math/rand"
 	"net"
+	"regexp"
 	"regexp/syntax"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode"
 )
 
 // SyntheticDataGenerator is used for generating synthetic data.
+// It is safe for concurrent use, e.g. by evaluators of several rules in parallel goroutines,
+// but the values of a seeded generator are only reproducible if it is called in the same order.
 type SyntheticDataGenerator struct {
 	randomGenerator *rand.Rand
+
+	// IncludeNetworkAndBroadcast is whether addresses generated for CIDR blocks can be the first and last address of the block
+	IncludeNetworkAndBroadcast bool
+
+	// MaxRepeat is the maximum number of repetitions generated for unbounded regex repeats, such as * and +,
+	// on top of their minimum. Bounded repeats with a wider range are capped in the same way. DefaultMaxRepeat is used if it's zero.
+	MaxRepeat int
 }
 
-// NewSyntheticDataGenerator creates a new instance of SyntheticDataGenerator.
+// NewSyntheticDataGenerator creates a new instance of SyntheticDataGenerator, seeded with the current time.
 func NewSyntheticDataGenerator() *SyntheticDataGenerator {
-	source := rand.NewSource(time.Now().UnixNano())
-	randomGenerator := rand.New(source)
+	return NewSeededSyntheticDataGenerator(time.Now().UnixNano())
+}
+
+// NewSeededSyntheticDataGenerator creates a new instance of SyntheticDataGenerator with an explicit seed.
+// Generators with the same seed produce the same sequence of values when they are called in the same order.
+func NewSeededSyntheticDataGenerator(seed int64) *SyntheticDataGenerator {
 	return &SyntheticDataGenerator{
-		randomGenerator: randomGenerator,
+		randomGenerator: rand.New(newLockedSource(seed)),
+		MaxRepeat:       DefaultMaxRepeat,
 	}
 }
 
+// DefaultGenerator returns the global generator, which is used by the package-level functions such as GetConstraint and Synthesize.
+func DefaultGenerator() *SyntheticDataGenerator {
+	return syntheticDataGenerator
+}
+
+// Rand returns the source of randomness of the generator, for callers that draw random values of their own, e.g. the time between events.
+// Drawing from it advances the sequence of values the generator produces. Like the generator, it is safe for concurrent use, except for its Read method.
+func (g *SyntheticDataGenerator) Rand() *rand.Rand {
+	return g.randomGenerator
+}
+
+// lockedSource is a source of random numbers that is safe for concurrent use, like the source behind the top-level functions of math/rand.
+// A rand.Rand only keeps state of its own for its Read method, so a rand.Rand over a lockedSource can be shared between goroutines.
+type lockedSource struct {
+	lock   sync.Mutex
+	source rand.Source64
+}
+
+// newLockedSource returns a lockedSource seeded with the given value.
+func newLockedSource(seed int64) *lockedSource {
+	return &lockedSource{source: rand.NewSource(seed).(rand.Source64)}
+}
+
+func (s *lockedSource) Int63() int64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.source.Int63()
+}
+
+func (s *lockedSource) Uint64() uint64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.source.Uint64()
+}
+
+func (s *lockedSource) Seed(seed int64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.source.Seed(seed)
+}
+
 // GenerateSyntheticValue generates a synthetic value based on a specific operation type.
-func (g *SyntheticDataGenerator) GenerateSyntheticValue(value string, operationType string) string {
+// An error is returned if the value is invalid or no value satisfies it, e.g. a malformed CIDR block or a regex that can't match anything.
+func (g *SyntheticDataGenerator) GenerateSyntheticValue(value string, operationType string) (string, error) {
 	syntheticData := g.generateRandomString(10)
 
 	switch operationType {
 	case "contains":
 		randomIndex := g.randomGenerator.Intn(len(syntheticData) + 1)
-		syntheticData = syntheticData[:randomIndex] + value + syntheticData[randomIndex:]
+		syntheticData = syntheticData[:randomIndex] + g.expandWildcards(value) + syntheticData[randomIndex:]
 	case "startswith":
-		syntheticData = value + syntheticData
+		syntheticData = g.expandWildcards(value) + syntheticData
 	case "endswith":
-		syntheticData = syntheticData + value
+		syntheticData = syntheticData + g.expandWildcards(value)
 	case "re":
-		syntheticData = g.generateRegexSyntheticData(value)
+		return g.generateRegexSyntheticData(value)
 	case "cidr":
-		syntheticData = g.generateCIDRMatch(value)
+		return g.generateCIDRMatch(value)
+	case "gt", "gte", "lt", "lte":
+		syntheticData = formatNumber(g.generateNumeric([]Constraint{{Operator: operationType, Value: value}}))
+	case "equal":
+		syntheticData = coerceString(g.expandEqualValue(value))
 	default:
 		syntheticData = value
 	}
 
-	return syntheticData
+	return syntheticData, nil
+}
+
+// GenerateNearMiss generates a synthetic value that almost, but not quite, satisfies a specific operation type.
+// Near-miss values are used for negative samples that must not trigger a rule, e.g. an IP just outside a CIDR block.
+// An error is returned if the value is invalid or there is no value that misses it, e.g. a CIDR block that covers every address.
+func (g *SyntheticDataGenerator) GenerateNearMiss(value string, operationType string) (string, error) {
+	switch operationType {
+	case "contains":
+		// Alter a character in the middle of the substring and surround it with random data
+		return g.generateRandomString(5) + g.mutateString(value, len([]rune(value))/2) + g.generateRandomString(5), nil
+	case "startswith":
+		return g.mutateString(value, len([]rune(value))-1) + g.generateRandomString(10), nil
+	case "endswith":
+		return g.generateRandomString(10) + g.mutateString(value, len([]rune(value))-1), nil
+	case "re":
+		return g.generateRegexNearMiss(value)
+	case "cidr":
+		return g.generateCIDRNearMiss(value)
+	case "gt", "gte", "lt", "lte":
+		return generateNumericNearMiss(value, operationType), nil
+	default:
+		// Null values are violated by any value, other values by altering their last character
+		if value == "null" {
+			return g.generateRandomString(10), nil
+		}
+		return g.mutateString(value, len([]rune(value))-1), nil
+	}
+}
+
+// GenerateConstrainedValue generates a synthetic value that satisfies all the given constraints at once.
+// Exact, regex and CIDR constraints fully determine the value, so they take precedence over substring constraints.
+// Substring constraints on the same field are combined into a single value of the form prefix...infix...suffix.
+// Negated constraints turn into near-miss values, or near-miss substrings if the field has substring constraints as well.
+// Exists constraints only decide whether the field is present: nil is returned if it must be absent.
+// Numeric comparisons produce an int64 or a float64 within the range they allow, depending on the type of their thresholds.
+// Values that still satisfy one of the negated constraints are drawn again, up to negationAttempts times.
+// An error is returned if a value can't be generated for one of the constraints, e.g. a near-miss of a CIDR block that covers every address.
+func (g *SyntheticDataGenerator) GenerateConstrainedValue(constraints []Constraint) (any, error) {
+	value, err := g.generateConstrainedValue(constraints)
+	for attempt := 0; attempt < negationAttempts && err == nil && value != nil && satisfiesNegated(value, constraints); attempt++ {
+		value, err = g.generateConstrainedValue(constraints)
+	}
+	return value, err
+}
+
+// negationAttempts is the number of times a constrained value is drawn again if it satisfies a negated constraint.
+const negationAttempts = 20
+
+// satisfiesNegated returns whether the value satisfies any of the negated constraints, which it is supposed to violate.
+// Strings are compared case-insensitively, so a value that violates a constraint also violates its case-sensitive variant.
+func satisfiesNegated(value any, constraints []Constraint) bool {
+	for _, constraint := range constraints {
+		if !constraint.Negated || constraint.Operator == "exists" {
+			continue
+		}
+		var comparator Comparator = baseComparator{}
+		if c, ok := Comparators[constraint.Operator]; ok {
+			comparator = c
+		}
+		if matches, err := comparator.Matches(value, constraint.Value); err == nil && matches {
+			return true
+		}
+	}
+	return false
+}
+
+// generateConstrainedValue draws a single value for GenerateConstrainedValue, which may still satisfy a negated constraint.
+func (g *SyntheticDataGenerator) generateConstrainedValue(constraints []Constraint) (any, error) {
+	var negated []Constraint
+	var required []Constraint
+	for _, constraint := range constraints {
+		if constraint.Operator == "exists" {
+			if present, _ := strconv.ParseBool(coerceString(constraint.Value)); present == constraint.Negated {
+				return nil, nil
+			}
+			continue
+		}
+		if constraint.Negated {
+			negated = append(negated, constraint)
+		} else {
+			required = append(required, constraint)
+		}
+	}
+	if len(required) == 0 && len(negated) > 0 {
+		// A near-miss of one negated constraint may still satisfy another, e.g. 'abX' for not equal 'abc' and not startswith 'ab',
+		// so the near-miss of each constraint is tried in turn until one of them violates all of them
+		var first any
+		for i, constraint := range negated {
+			text, err := g.GenerateNearMiss(coerceString(constraint.Value), constraint.Operator)
+			if err != nil {
+				return nil, err
+			}
+			var nearMiss any = text
+			if isNumeric(constraint) {
+				nearMiss = parseNumber(text)
+			}
+			if !satisfiesNegated(nearMiss, negated) {
+				return nearMiss, nil
+			}
+			if i == 0 {
+				first = nearMiss
+			}
+		}
+		return first, nil
+	}
+
+	// Numeric comparisons on the same field are combined into a single range, and the value is drawn from it
+	for _, constraint := range required {
+		if isNumeric(constraint) {
+			return g.generateNumeric(append(required, negated...)), nil
+		}
+	}
+	constraints = required
+
+	// A single constraint keeps the shape of the values produced by GenerateSyntheticValue
+	if len(constraints) == 1 && len(negated) == 0 {
+		constraint := constraints[0]
+		if constraint.Operator == "equal" {
+			return g.expandEqualValue(constraint.Value), nil
+		}
+		return g.GenerateSyntheticValue(coerceString(constraint.Value), constraint.Operator)
+	}
+
+	var prefix, suffix string
+	var infixes []string
+	for _, constraint := range constraints {
+		value := coerceString(constraint.Value)
+		switch constraint.Operator {
+		case "re", "cidr":
+			return g.GenerateSyntheticValue(value, constraint.Operator)
+		case "startswith":
+			// Keep the longest prefix, as shorter ones are usually contained in it
+			if len(value) > len(prefix) {
+				prefix = value
+			}
+		case "endswith":
+			// Keep the longest suffix, as shorter ones are usually contained in it
+			if len(value) > len(suffix) {
+				suffix = value
+			}
+		case "contains":
+			infixes = append(infixes, value)
+		default:
+			// Equality and comparisons without synthesis support use the expected value, with its wildcards expanded
+			return g.expandEqualValue(constraint.Value), nil
+		}
+	}
+
+	// Near-miss prefixes and suffixes are only used if the value isn't required to have one
+	for _, constraint := range negated {
+		value := coerceString(constraint.Value)
+		last := len([]rune(value)) - 1
+		switch constraint.Operator {
+		case "startswith":
+			if prefix == "" {
+				prefix = g.mutateString(value, last)
+			}
+		case "endswith":
+			if suffix == "" {
+				suffix = g.mutateString(value, last)
+			}
+		case "contains":
+			infixes = append(infixes, g.mutateString(value, (last+1)/2))
+		}
+	}
+
+	// Join the prefix, infixes and suffix with random filler between them
+	var builder strings.Builder
+	builder.WriteString(g.expandWildcards(prefix))
+	for _, infix := range infixes {
+		builder.WriteString(g.generateRandomString(5))
+		builder.WriteString(g.expandWildcards(infix))
+	}
+	builder.WriteString(g.generateRandomString(5))
+	builder.WriteString(g.expandWildcards(suffix))
+	return builder.String(), nil
+}
+
+// expandWildcards replaces the Sigma wildcards of a pattern with random text that they match: * with a few characters and ? with one.
+// Escaped wildcards and backslashes are replaced by the character they escape, as in globMatch. Values without wildcards are returned as is.
+func (g *SyntheticDataGenerator) expandWildcards(pattern string) string {
+	if !strings.ContainsAny(pattern, "*?\\") {
+		return pattern
+	}
+
+	var builder strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		switch char := pattern[i]; {
+		case char == '\\' && i+1 < len(pattern) && strings.IndexByte("*?\\", pattern[i+1]) >= 0:
+			builder.WriteByte(pattern[i+1])
+			i++
+		case char == '*':
+			builder.WriteString(g.generateRandomString(3))
+		case char == '?':
+			builder.WriteString(g.generateRandomString(1))
+		default:
+			builder.WriteByte(char)
+		}
+	}
+	return builder.String()
+}
+
+// expandEqualValue returns the expected value of an equality, with its wildcards expanded if it's a string that has any.
+// Values without wildcards are compared literally by globMatch, so they are returned as they are written, backslashes included.
+func (g *SyntheticDataGenerator) expandEqualValue(value any) any {
+	if text, ok := value.(string); ok && strings.ContainsAny(text, "*?") {
+		return g.expandWildcards(text)
+	}
+	return value
+}
+
+// Synthesize generates a synthetic value that satisfies all the given constraints using the global generator.
+func Synthesize(constraints ...Constraint) (any, error) {
+	return syntheticDataGenerator.GenerateConstrainedValue(constraints)
+}
+
+// mutateString replaces the character at the given index with a different one, ignoring case.
+// An empty string is replaced by random data, since every value contains it.
+func (g *SyntheticDataGenerator) mutateString(value string, index int) string {
+	runes := []rune(value)
+	if len(runes) == 0 {
+		return g.generateRandomString(5)
+	}
+	if index < 0 || index >= len(runes) {
+		index = len(runes) - 1
+	}
+
+	const charset = "abcdefghijklmnopqrstuvwxyz0123456789"
+	for {
+		replacement := rune(charset[g.randomGenerator.Intn(len(charset))])
+		if unicode.ToLower(runes[index]) != replacement {
+			runes[index] = replacement
+			return string(runes)
+		}
+	}
+}
+
+// generateRegexNearMiss generates a value that doesn't match the given regex pattern.
+// It first alters characters of a matching value, and falls back to random data if every attempt still matches.
+func (g *SyntheticDataGenerator) generateRegexNearMiss(pattern string) (string, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return "", fmt.Errorf("regex parsing error: %w", err)
+	}
+
+	// The match is left empty if none can be generated, so the candidates are random
+	generated, _ := g.generateRegexSyntheticData(pattern)
+	match := []rune(generated)
+	for attempt := 0; attempt < 20; attempt++ {
+		var candidate string
+		if len(match) > 0 {
+			candidate = g.mutateString(string(match), g.randomGenerator.Intn(len(match)))
+		} else {
+			candidate = g.generateRandomString(10)
+		}
+		if !re.MatchString(candidate) {
+			return candidate, nil
+		}
+	}
+	for attempt := 0; attempt < 20; attempt++ {
+		candidate := g.generateRandomString(10)
+		if !re.MatchString(candidate) {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("unable to generate a value that doesn't match regex %s", pattern)
+}
+
+// generateCIDRNearMiss generates the address right after the given CIDR block, or right before it if the block ends the address space.
+func (g *SyntheticDataGenerator) generateCIDRNearMiss(cidrValue string) (string, error) {
+	_, ipNet, err := net.ParseCIDR(cidrValue)
+	if err != nil {
+		return "", fmt.Errorf("CIDR parsing error: %w", err)
+	}
+
+	// Compute the last address of the block and step past it
+	first := ipNet.IP.Mask(ipNet.Mask)
+	next := lastIP(ipNet)
+	inc(next)
+	if !next.Equal(make(net.IP, len(next))) {
+		return next.String(), nil
+	}
+
+	// The block ends the address space, so step before its first address instead
+	previous := make(net.IP, len(first))
+	copy(previous, first)
+	for j := len(previous) - 1; j >= 0; j-- {
+		previous[j]--
+		if previous[j] != 0xff {
+			break
+		}
+	}
+	if ipNet.Contains(previous) {
+		return "", fmt.Errorf("CIDR block %s covers the whole address space", cidrValue)
+	}
+	return previous.String(), nil
+}
+
+// generateNumericNearMiss generates the closest number that doesn't satisfy the given numeric comparison.
+func generateNumericNearMiss(value string, operationType string) string {
+	if integer, err := strconv.ParseInt(value, 10, 64); err == nil {
+		switch operationType {
+		case "gte":
+			integer--
+		case "lte":
+			integer++
+		}
+		return strconv.FormatInt(integer, 10)
+	}
+	if float, err := strconv.ParseFloat(value, 64); err == nil {
+		switch operationType {
+		case "gte":
+			float--
+		case "lte":
+			float++
+		}
+		return strconv.FormatFloat(float, 'f', -1, 64)
+	}
+	return value
 }
 
 // GenerateRegexSyntheticData generates a synthetic value based on the given regex pattern.
-func (g *SyntheticDataGenerator) generateRegexSyntheticData(pattern string) string {
+// Every candidate is checked against the pattern, and a new one is generated if it doesn't match,
+// e.g. because an anchor isn't satisfied by the characters around it. An error is returned if none of them matches.
+func (g *SyntheticDataGenerator) generateRegexSyntheticData(pattern string) (string, error) {
 	re, err := syntax.Parse(pattern, syntax.Perl)
 	if err != nil {
-		// Handle parsing error
-		fmt.Println("Regex parsing error:", err)
-		return ""
+		return "", fmt.Errorf("regex parsing error: %w", err)
+	}
+	compiled, err := regexp.Compile(pattern)
+	if err != nil {
+		return "", fmt.Errorf("regex parsing error: %w", err)
 	}
 
-	var syntheticDataBuilder strings.Builder
-	g.generateRegexSyntheticDataRecursive(re, &syntheticDataBuilder)
-	return syntheticDataBuilder.String()
+	for attempt := 0; attempt < regexAttempts; attempt++ {
+		var syntheticDataBuilder strings.Builder
+		next := anyRune
+		g.generateRegexSyntheticDataRecursive(re, &syntheticDataBuilder, &next)
+		// A word boundary at the end of the pattern that needs a word character after it gets one, the end of the value counts as a non-word character
+		if next == wordRune {
+			syntheticDataBuilder.WriteRune(g.randomClassRune(wordRunes))
+		}
+		if candidate := syntheticDataBuilder.String(); compiled.MatchString(candidate) {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("unable to generate a value matching regex %s", pattern)
 }
 
-func (g *SyntheticDataGenerator) generateRegexSyntheticDataRecursive(re *syntax.Regexp, builder *strings.Builder) {
-	// If re.Sub is empty, perform other operations
-	if re.Sub == nil || len(re.Sub) == 0 {
-		// Handle other syntax.Op values
-		g.handleOtherRegexOperations(re, builder)
-		return
-	}
+// regexAttempts is the number of candidates generated for a regex pattern before giving up.
+const regexAttempts = 50
+
+// DefaultMaxRepeat is the default maximum number of repetitions generated for unbounded regex repeats, such as * and +.
+const DefaultMaxRepeat = 8
 
-	// Assuming there is only one sub-expression in OpRepeat
-	sub := re.Sub[0]
+// wordRequirement is the kind of rune that a word boundary assertion requires after the runes generated before it.
+type wordRequirement int
 
+const (
+	anyRune     wordRequirement = iota // No assertion precedes the next rune
+	wordRune                           // The next rune must be a word character, [0-9A-Za-z_]
+	nonWordRune                        // The next rune must not be a word character
+)
+
+// wordRunes is the character class of the word characters that \b and \B refer to, as pairs of the first and last rune of each range.
+var wordRunes = []rune{'0', '9', 'A', 'Z', '_', '_', 'a', 'z'}
+
+// generateRegexSyntheticDataRecursive writes a random string that matches the parsed regex to the builder.
+// Word boundaries set the kind of rune that must come next, given the previous rune, in next. Other zero-width assertions such as anchors
+// don't produce characters, they are checked once the whole value is generated.
+func (g *SyntheticDataGenerator) generateRegexSyntheticDataRecursive(re *syntax.Regexp, builder *strings.Builder, next *wordRequirement) {
 	switch re.Op {
-	case syntax.OpRepeat:
-		// Repeat: build the repeated string and append to the builder
-		var repeatedBuilder strings.Builder
-
-		// OpRepeat sub-expression check
-		if sub != nil {
-			for j := 0; j < re.Min+g.randomGenerator.Intn(re.Max-re.Min+1); j++ {
-				// Process sub-expressions within the repeated expression
-				g.generateRegexSyntheticDataRecursive(sub, &repeatedBuilder)
+	case syntax.OpLiteral:
+		// Case-folded literals get a random case for every letter
+		for _, r := range re.Rune {
+			if re.Flags&syntax.FoldCase != 0 && g.randomGenerator.Intn(2) == 0 {
+				if unicode.IsLower(r) {
+					r = unicode.ToUpper(r)
+				} else {
+					r = unicode.ToLower(r)
+				}
 			}
+			g.writeRegexRune(builder, next, r)
 		}
 
-		builder.WriteString(repeatedBuilder.String())
-	default:
-		// Handle other syntax.Op values
+	case syntax.OpCharClass:
+		g.writeRegexRune(builder, next, g.randomRequiredRune(re.Rune, *next))
+
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		g.writeRegexRune(builder, next, g.randomRequiredRune(printableRunes, *next))
+
+	case syntax.OpCapture:
+		g.generateRegexSyntheticDataRecursive(re.Sub[0], builder, next)
+
+	case syntax.OpConcat:
 		for _, sub := range re.Sub {
-			g.generateRegexSyntheticDataRecursive(sub, builder)
+			g.generateRegexSyntheticDataRecursive(sub, builder, next)
+		}
+
+	case syntax.OpAlternate:
+		g.generateRegexSyntheticDataRecursive(re.Sub[g.randomGenerator.Intn(len(re.Sub))], builder, next)
+
+	case syntax.OpStar, syntax.OpPlus, syntax.OpQuest, syntax.OpRepeat:
+		min, max := g.repeatBounds(re)
+		for count := min + g.randomGenerator.Intn(max-min+1); count > 0; count-- {
+			g.generateRegexSyntheticDataRecursive(re.Sub[0], builder, next)
+		}
+
+	case syntax.OpWordBoundary, syntax.OpNoWordBoundary:
+		// The start of the value counts as a non-word character
+		previous := []rune(builder.String())
+		previousWord := len(previous) > 0 && syntax.IsWordChar(previous[len(previous)-1])
+		// A word boundary needs the next rune to be of the other kind than the previous one, its absence of the same kind
+		if previousWord == (re.Op == syntax.OpWordBoundary) {
+			*next = nonWordRune
+		} else {
+			*next = wordRune
 		}
+
+	case syntax.OpEmptyMatch, syntax.OpBeginLine, syntax.OpEndLine, syntax.OpBeginText, syntax.OpEndText, syntax.OpNoMatch:
+		// Other zero-width assertions don't produce any characters, and nothing matches OpNoMatch
 	}
 }
 
-func (g *SyntheticDataGenerator) handleOtherRegexOperations(sub *syntax.Regexp, builder *strings.Builder) {
-	switch sub.Op {
-	case syntax.OpConcat:
-		// Concatenation, process each sub-expression
-		for _, concatSub := range sub.Sub {
-			g.generateRegexSyntheticDataRecursive(concatSub, builder)
+// writeRegexRune writes a rune of the value to the builder, after the word boundary assertion that precedes it, if any.
+// A rune at the start of the value that is of the wrong kind for the assertion gets a word character in front of it,
+// which turns the boundary of the start of the value into the one the assertion needs.
+func (g *SyntheticDataGenerator) writeRegexRune(builder *strings.Builder, next *wordRequirement, r rune) {
+	if *next != anyRune && syntax.IsWordChar(r) != (*next == wordRune) && builder.Len() == 0 {
+		builder.WriteRune(g.randomClassRune(wordRunes))
+	}
+	*next = anyRune
+	builder.WriteRune(r)
+}
+
+// randomRequiredRune returns a random rune of a character class that is of the kind a word boundary assertion requires, if the class has one.
+func (g *SyntheticDataGenerator) randomRequiredRune(class []rune, next wordRequirement) rune {
+	r := g.randomClassRune(class)
+	for attempt := 0; attempt < regexAttempts && next != anyRune && syntax.IsWordChar(r) != (next == wordRune); attempt++ {
+		r = g.randomClassRune(class)
+	}
+	return r
+}
+
+// repeatBounds returns the minimum and maximum number of repetitions of a repeat operator.
+// Unbounded repeats, and bounded ones with a wide range, get at most MaxRepeat repetitions more than their minimum.
+func (g *SyntheticDataGenerator) repeatBounds(re *syntax.Regexp) (int, int) {
+	var min, max int
+	switch re.Op {
+	case syntax.OpStar:
+		min, max = 0, -1
+	case syntax.OpPlus:
+		min, max = 1, -1
+	case syntax.OpQuest:
+		min, max = 0, 1
+	default:
+		min, max = re.Min, re.Max
+	}
+
+	limit := g.MaxRepeat
+	if limit <= 0 {
+		limit = DefaultMaxRepeat
+	}
+	if max < 0 || max-min > limit {
+		max = min + limit
+	}
+	return min, max
+}
+
+// printableRunes is the character class of the printable ASCII characters, as pairs of the first and last rune of each range.
+var printableRunes = []rune{' ', '~'}
+
+// randomClassRune returns a random rune of a character class, given as pairs of the first and last rune of each range.
+// Printable ASCII characters are preferred, so that negated classes such as [^a-z] don't produce control or unassigned characters.
+func (g *SyntheticDataGenerator) randomClassRune(class []rune) rune {
+	if len(class) == 0 {
+		return 0
+	}
+
+	// Restrict the ranges to the printable ASCII characters if any of them is in the class
+	var printable []rune
+	for i := 0; i+1 < len(class); i += 2 {
+		lo, hi := class[i], class[i+1]
+		if lo < printableRunes[0] {
+			lo = printableRunes[0]
 		}
-	case syntax.OpCharClass:
-		// Character class, randomly select a character
-		class := sub.Rune
-		if len(class) > 0 {
-			char := class[g.randomGenerator.Intn(len(class))]
-			builder.WriteRune(char)
+		if hi > printableRunes[1] {
+			hi = printableRunes[1]
 		}
-	case syntax.OpLiteral:
-		// Append literal character to the builder
-		builder.WriteString(sub.String())
-	case syntax.OpCapture, syntax.OpNoMatch:
-		// Recursively process the sub-expression for capture group or no match
-		g.generateRegexSyntheticDataRecursive(sub, builder)
-	default:
-		// Unsupported syntax.Op value
-		fmt.Println("Unsupported syntax.Op:", sub.Op)
+		if lo <= hi {
+			printable = append(printable, lo, hi)
+		}
+	}
+	if len(printable) > 0 {
+		class = printable
 	}
+
+	// Pick a range with a probability proportional to its size, then a rune within it
+	total := 0
+	for i := 0; i+1 < len(class); i += 2 {
+		total += int(class[i+1]-class[i]) + 1
+	}
+	n := g.randomGenerator.Intn(total)
+	for i := 0; i+1 < len(class); i += 2 {
+		size := int(class[i+1]-class[i]) + 1
+		if n < size {
+			return class[i] + rune(n)
+		}
+		n -= size
+	}
+	return class[0]
 }
 
 // GenerateCIDRMatch generates a synthetic value matching a specific CIDR block.
-func (g *SyntheticDataGenerator) generateCIDRMatch(cidrValue string) string {
+// The address is drawn at a random offset within the block, so blocks of any size, IPv4 or IPv6, take constant memory.
+// The network and broadcast addresses, i.e. the first and last address of the block, are excluded unless IncludeNetworkAndBroadcast is set,
+// or the block is too small to have any other address.
+func (g *SyntheticDataGenerator) generateCIDRMatch(cidrValue string) (string, error) {
 	_, ipNet, err := net.ParseCIDR(cidrValue)
 	if err != nil {
-		// Handle parsing error
-		fmt.Println("CIDR parsing error:", err)
-		return ""
+		return "", fmt.Errorf("CIDR parsing error: %w", err)
 	}
 
-	// Calculate the number of available IP addresses in the CIDR block
-	ipCount := 0
-	for range g.IPRange(ipNet) {
-		ipCount++
-	}
+	// Calculate the number of addresses in the CIDR block
+	ones, bits := ipNet.Mask.Size()
+	hostBits := bits - ones
+	count := new(big.Int).Lsh(big.NewInt(1), uint(hostBits))
 
-	// Generate a random index within the range of available IPs
-	randomIndex := g.randomGenerator.Intn(ipCount)
+	first := big.NewInt(0)
+	if !g.IncludeNetworkAndBroadcast && hostBits >= 2 {
+		first.SetInt64(1)
+		count.Sub(count, big.NewInt(2))
+	}
 
-	// Get the IP address at the randomly selected index
-	randomIP := g.GetNthIP(ipNet, randomIndex)
+	// Generate a random offset within the range of available addresses
+	offset := new(big.Int).Rand(g.randomGenerator, count)
+	offset.Add(offset, first)
 
-	return randomIP.String()
+	return ipAtOffset(ipNet, offset).String(), nil
 }
 
-// IPRange generates all IP addresses in the given CIDR block
+// IPRange generates all IP addresses in the given CIDR block, excluding the network and broadcast addresses.
+// It allocates every address of the block, so it's only suitable for small blocks.
 func (g *SyntheticDataGenerator) IPRange(ipNet *net.IPNet) []net.IP {
-	ip := ipNet.IP.Mask(ipNet.Mask)
 	var ips []net.IP
-	for ip := ip.Mask(ipNet.Mask); ipNet.Contains(ip); inc(ip) {
-		ips = append(ips, net.IP{ip[0], ip[1], ip[2], ip[3]})
+	for ip := ipNet.IP.Mask(ipNet.Mask); ipNet.Contains(ip); inc(ip) {
+		ips = append(ips, append(net.IP(nil), ip...))
+		if ip.Equal(lastIP(ipNet)) {
+			break
+		}
+	}
+	if len(ips) < 3 {
+		return ips
 	}
 	return ips[1 : len(ips)-1] // Exclude network and broadcast addresses
 }
 
 // GetNthIP returns the nth IP address in the given CIDR block
 func (g *SyntheticDataGenerator) GetNthIP(ipNet *net.IPNet, n int) net.IP {
-	ip := ipNet.IP.Mask(ipNet.Mask)
-	for i := 0; i < n; i++ {
-		inc(ip)
+	return ipAtOffset(ipNet, big.NewInt(int64(n)))
+}
+
+// ipAtOffset returns the address at the given offset from the first address of a CIDR block.
+func ipAtOffset(ipNet *net.IPNet, offset *big.Int) net.IP {
+	first := ipNet.IP.Mask(ipNet.Mask)
+	address := new(big.Int).SetBytes(first)
+	address.Add(address, offset)
+
+	// Write the address back into a slice of the same length as the block's addresses
+	ip := make(net.IP, len(first))
+	address.FillBytes(ip)
+	return ip
+}
+
+// lastIP returns the last address of a CIDR block.
+func lastIP(ipNet *net.IPNet) net.IP {
+	first := ipNet.IP.Mask(ipNet.Mask)
+	last := make(net.IP, len(first))
+	for i := range first {
+		last[i] = first[i] | ^ipNet.Mask[i]
 	}
-	return net.IP{ip[0], ip[1], ip[2], ip[3]}
+	return last
 }
 
 // Helper function to increment IP address
@@ -172,6 +703,11 @@ func inc(ip net.IP) {
 	}
 }
 
+// pick returns a random value of a non-empty list.
+func (g *SyntheticDataGenerator) pick(values []any) any {
+	return values[g.randomGenerator.Intn(len(values))]
+}
+
 // GenerateRandomString generates a random string of a specific length.
 func (g *SyntheticDataGenerator) generateRandomString(length int) string {
 	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
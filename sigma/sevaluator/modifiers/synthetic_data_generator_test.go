@@ -1,9 +1,13 @@
 package modifiers_test
 
 import (
+	"fmt"
 	"net"
+	"reflect"
 	"regexp"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/mtnmunuklu/logen/sigma/sevaluator/modifiers"
@@ -12,7 +16,10 @@ import (
 func TestSyntheticDataGeneratorContains(t *testing.T) {
 	generator := modifiers.NewSyntheticDataGenerator()
 	expectedValue := "test_value"
-	result := generator.GenerateSyntheticValue(expectedValue, "contains")
+	result, err := generator.GenerateSyntheticValue(expectedValue, "contains")
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	if !strings.Contains(result, expectedValue) {
 		t.Errorf("Expected result to contain %s, but got: %s", expectedValue, result)
@@ -22,7 +29,10 @@ func TestSyntheticDataGeneratorContains(t *testing.T) {
 func TestSyntheticDataGeneratorStartsWith(t *testing.T) {
 	generator := modifiers.NewSyntheticDataGenerator()
 	expectedPrefix := "prefix_"
-	result := generator.GenerateSyntheticValue(expectedPrefix, "startswith")
+	result, err := generator.GenerateSyntheticValue(expectedPrefix, "startswith")
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	if !strings.HasPrefix(result, expectedPrefix) {
 		t.Errorf("Expected result to start with %s, but got: %s", expectedPrefix, result)
@@ -32,17 +42,43 @@ func TestSyntheticDataGeneratorStartsWith(t *testing.T) {
 func TestSyntheticDataGeneratorEndsWith(t *testing.T) {
 	generator := modifiers.NewSyntheticDataGenerator()
 	expectedSuffix := "_suffix"
-	result := generator.GenerateSyntheticValue(expectedSuffix, "endswith")
+	result, err := generator.GenerateSyntheticValue(expectedSuffix, "endswith")
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	if !strings.HasSuffix(result, expectedSuffix) {
 		t.Errorf("Expected result to end with %s, but got: %s", expectedSuffix, result)
 	}
 }
 
+// TestSyntheticDataGeneratorWildcards checks that the wildcards of substring values are filled with random text, and escaped ones written literally.
+func TestSyntheticDataGeneratorWildcards(t *testing.T) {
+	generator := modifiers.NewSyntheticDataGenerator()
+	for _, operator := range []string{"contains", "startswith", "endswith"} {
+		value := `ssh*root?x\*y`
+		result, err := generator.GenerateSyntheticValue(value, operator)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !regexp.MustCompile(`ssh[^*]+root[^*]x\*y`).MatchString(result) {
+			t.Errorf("Expected %s %s to expand the wildcards, but got: %s", operator, value, result)
+		}
+
+		matcher, _ := modifiers.GetMatcher(operator)
+		if match, err := matcher(result, value); err != nil || !match {
+			t.Errorf("Expected %s to match %s %s", result, operator, value)
+		}
+	}
+}
+
 func TestSyntheticDataGeneratorRegex(t *testing.T) {
 	generator := modifiers.NewSyntheticDataGenerator()
 	expectedRegexPattern := "\\d{2}-BC\\S{4}"
-	result := generator.GenerateSyntheticValue(expectedRegexPattern, "re")
+	result, err := generator.GenerateSyntheticValue(expectedRegexPattern, "re")
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	matched, err := regexp.MatchString(expectedRegexPattern, result)
 	if err != nil || !matched {
@@ -50,13 +86,301 @@ func TestSyntheticDataGeneratorRegex(t *testing.T) {
 	}
 }
 
+func TestSyntheticDataGeneratorRegexSyntax(t *testing.T) {
+	generator := modifiers.NewSyntheticDataGenerator()
+	patterns := []string{
+		`^cmd(\.exe)?$`,
+		`(?i)invoke-(mimikatz|bloodhound)`,
+		`\bpowershell\b.+-enc(odedcommand)?\s+[A-Za-z0-9+/=]{20,}`,
+		`[^a-z0-9]{3}\d*x?`,
+		`(?s)begin.*end`,
+		`(?m)^line\d+$`,
+		`a{2,}b{0,3}c{5}`,
+		`[[:alpha:]]+@[\w.-]+\.(com|net)`,
+		`\\\\[^\\]+\\(C|ADMIN)\$`,
+		`\p{Greek}+`,
+	}
+
+	for _, pattern := range patterns {
+		for i := 0; i < 20; i++ {
+			result, err := generator.GenerateSyntheticValue(pattern, "re")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if matched, err := regexp.MatchString(pattern, result); err != nil || !matched {
+				t.Errorf("Expected result to match regex pattern %s, but got: %q", pattern, result)
+			}
+		}
+	}
+}
+
+func TestSyntheticDataGeneratorRegexWordBoundaries(t *testing.T) {
+	generator := modifiers.NewSyntheticDataGenerator()
+	patterns := []string{`foo\B`, `[^\x00-\x7f]\bfoo\B`, `\Bbar`, `\b-x\b`, `a\b.\Bb?`, `[a-z-]\b[a-z-]+`}
+
+	for _, pattern := range patterns {
+		for i := 0; i < 20; i++ {
+			result, err := generator.GenerateSyntheticValue(pattern, "re")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if matched, err := regexp.MatchString(pattern, result); err != nil || !matched {
+				t.Errorf("Expected result to match regex pattern %s, but got: %q", pattern, result)
+			}
+		}
+	}
+
+	// Patterns that nothing matches give an error instead of a value that doesn't match them
+	if result, err := generator.GenerateSyntheticValue(`a\bb`, "re"); err == nil {
+		t.Errorf("Expected an error for an unsatisfiable regex, but got: %q", result)
+	}
+}
+
+func TestSyntheticDataGeneratorMaxRepeat(t *testing.T) {
+	generator := modifiers.NewSyntheticDataGenerator()
+	generator.MaxRepeat = 3
+
+	for i := 0; i < 20; i++ {
+		if result, err := generator.GenerateSyntheticValue(`^a*b+c{2,1000}$`, "re"); err != nil || len(result) > 3+4+5 {
+			t.Errorf("Expected at most 3 repetitions above the minimum of each repeat, but got: %s", result)
+		}
+	}
+}
+
 func TestSyntheticDataGeneratorCIDR(t *testing.T) {
 	generator := modifiers.NewSyntheticDataGenerator()
-	expectedCIDR := "192.168.1.0/24"
-	result := generator.GenerateSyntheticValue(expectedCIDR, "cidr")
+	blocks := []string{"192.168.1.0/24", "10.0.0.0/8", "0.0.0.0/0", "192.168.1.7/32", "2001:db8::/32", "fe80::/10", "::/0", "2001:db8::1/128"}
+
+	for _, block := range blocks {
+		_, ipNet, err := net.ParseCIDR(block)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ones, bits := ipNet.Mask.Size()
+
+		for i := 0; i < 100; i++ {
+			result, err := generator.GenerateSyntheticValue(block, "cidr")
+			if err != nil {
+				t.Fatal(err)
+			}
+			ip := net.ParseIP(result)
+			if ip == nil || !ipNet.Contains(ip) {
+				t.Fatalf("Expected result to match CIDR block %s, but got: %s", block, result)
+			}
+			if bits-ones >= 2 && ip.Equal(ipNet.IP) {
+				t.Errorf("Expected the network address of CIDR block %s to be excluded, but got: %s", block, result)
+			}
+		}
+	}
+
+	// Invalid values are errors rather than empty values
+	for _, tc := range [][2]string{{"10.0.0.0/33", "cidr"}, {"10.0.0.1", "cidr"}, {"(", "re"}} {
+		if result, err := generator.GenerateSyntheticValue(tc[0], tc[1]); err == nil {
+			t.Errorf("Expected an error for %s %s, but got: %q", tc[1], tc[0], result)
+		}
+	}
+}
+
+func TestSyntheticDataGeneratorCIDREdges(t *testing.T) {
+	generator := modifiers.NewSyntheticDataGenerator()
+	block := "192.168.1.0/30"
+
+	// The edges of a /30 are only generated if they are included
+	for _, include := range []bool{false, true} {
+		generator.IncludeNetworkAndBroadcast = include
+		seen := map[string]bool{}
+		for i := 0; i < 200; i++ {
+			result, err := generator.GenerateSyntheticValue(block, "cidr")
+			if err != nil {
+				t.Fatal(err)
+			}
+			seen[result] = true
+		}
+
+		edges := seen["192.168.1.0"] || seen["192.168.1.3"]
+		if edges != include {
+			t.Errorf("Expected network and broadcast addresses to be generated: %v, but got: %v", include, seen)
+		}
+		if !seen["192.168.1.1"] || !seen["192.168.1.2"] {
+			t.Errorf("Expected every host address to be generated, but got: %v", seen)
+		}
+	}
+}
+
+func TestSyntheticDataGeneratorNumeric(t *testing.T) {
+	generator := modifiers.NewSyntheticDataGenerator()
+
+	// Integer thresholds give integers, float thresholds give floats
+	for _, tc := range []struct {
+		value, operator string
+		check           func(float64) bool
+	}{
+		{"5", "gt", func(v float64) bool { return v > 5 }},
+		{"5", "gte", func(v float64) bool { return v >= 5 }},
+		{"-5", "lt", func(v float64) bool { return v < -5 }},
+		{"5", "lte", func(v float64) bool { return v <= 5 }},
+		{"5.5", "gt", func(v float64) bool { return v > 5.5 }},
+		{"0.25", "lt", func(v float64) bool { return v < 0.25 }},
+	} {
+		for i := 0; i < 100; i++ {
+			result, err := generator.GenerateSyntheticValue(tc.value, tc.operator)
+			if err != nil {
+				t.Fatal(err)
+			}
+			value, err := strconv.ParseFloat(result, 64)
+			if err != nil || !tc.check(value) {
+				t.Fatalf("Expected a number %s %s, but got: %s", tc.operator, tc.value, result)
+			}
+			if isFloat := strings.Contains(result, "."); isFloat != strings.Contains(tc.value, ".") {
+				t.Fatalf("Expected %s %s to keep the type of the threshold, but got: %s", tc.operator, tc.value, result)
+			}
+		}
+	}
+
+	// Comparisons on the same field are combined
+	for i := 0; i < 100; i++ {
+		result, _ := generator.GenerateConstrainedValue([]modifiers.Constraint{
+			{Operator: "gt", Value: 5},
+			{Operator: "lt", Value: 10},
+			{Operator: "lt", Value: 8, Negated: true},
+		})
+		if value, ok := result.(int64); !ok || value < 8 || value >= 10 {
+			t.Fatalf("Expected an integer in [8,10), but got: %v (%T)", result, result)
+		}
+
+		result, _ = generator.GenerateConstrainedValue([]modifiers.Constraint{
+			{Operator: "gte", Value: 5.0},
+			{Operator: "lte", Value: 6.0},
+		})
+		if value, ok := result.(float64); !ok || value < 5 || value > 6 {
+			t.Fatalf("Expected a float in [5,6], but got: %v (%T)", result, result)
+		}
+	}
+
+	// Contradicting comparisons fall back to the first threshold
+	result, _ := generator.GenerateConstrainedValue([]modifiers.Constraint{
+		{Operator: "gt", Value: 5},
+		{Operator: "lt", Value: 3},
+	})
+	if result != 5 {
+		t.Errorf("Expected the threshold of the first comparison, but got: %v", result)
+	}
+}
+
+func TestSyntheticDataGeneratorNearMiss(t *testing.T) {
+	generator := modifiers.NewSyntheticDataGenerator()
+
+	if result, _ := generator.GenerateNearMiss("test_value", "contains"); strings.Contains(strings.ToLower(result), "test_value") {
+		t.Errorf("Expected result not to contain test_value, but got: %s", result)
+	}
+	if result, _ := generator.GenerateNearMiss("prefix_", "startswith"); strings.HasPrefix(strings.ToLower(result), "prefix_") || !strings.HasPrefix(result, "prefix") {
+		t.Errorf("Expected result to almost start with prefix_, but got: %s", result)
+	}
+	if result, _ := generator.GenerateNearMiss("_suffix", "endswith"); strings.HasSuffix(strings.ToLower(result), "_suffix") || !strings.Contains(result, "_suffi") {
+		t.Errorf("Expected result to almost end with _suffix, but got: %s", result)
+	}
+
+	pattern := "\\d{2}-BC\\S{4}"
+	result, err := generator.GenerateNearMiss(pattern, "re")
+	if matched, _ := regexp.MatchString(pattern, result); err != nil || matched {
+		t.Errorf("Expected result not to match regex pattern %s, but got: %s (%v)", pattern, result, err)
+	}
+
+	for cidr, expected := range map[string]string{
+		"192.168.1.0/24":   "192.168.2.0",
+		"255.255.255.0/24": "255.255.254.255",
+		"2001:db8::/32":    "2001:db9::",
+	} {
+		if result, _ := generator.GenerateNearMiss(cidr, "cidr"); result != expected {
+			t.Errorf("Expected %s just outside CIDR block %s, but got: %s", expected, cidr, result)
+		}
+	}
+
+	// Values that can't be missed, or aren't valid, are errors rather than empty near-misses
+	for _, tc := range [][2]string{{"0.0.0.0/0", "cidr"}, {"::/0", "cidr"}, {"10.0.0.0/33", "cidr"}, {"(", "re"}, {".*", "re"}} {
+		if result, err := generator.GenerateNearMiss(tc[0], tc[1]); err == nil {
+			t.Errorf("Expected an error for a near-miss of %s %s, but got: %q", tc[1], tc[0], result)
+		}
+	}
+
+	for operator, expected := range map[string]string{"gt": "5", "gte": "4", "lt": "5", "lte": "6"} {
+		if result, _ := generator.GenerateNearMiss("5", operator); result != expected {
+			t.Errorf("Expected %s to violate %s 5, but got: %s", expected, operator, result)
+		}
+	}
+	// Negated constraints are all violated, not only the first one
+	for i := 0; i < 100; i++ {
+		result, _ := generator.GenerateConstrainedValue([]modifiers.Constraint{
+			{Operator: "equal", Value: "abc", Negated: true},
+			{Operator: "startswith", Value: "ab", Negated: true},
+		})
+		if value := result.(string); strings.HasPrefix(strings.ToLower(value), "ab") {
+			t.Fatalf("Expected a value that doesn't start with ab, but got: %s", value)
+		}
+	}
+
+	// The random filler between required substrings mustn't satisfy a negated constraint either
+	for i := 0; i < 100; i++ {
+		result, _ := generator.GenerateConstrainedValue([]modifiers.Constraint{
+			{Operator: "startswith", Value: "x"},
+			{Operator: "endswith", Value: "y"},
+			{Operator: "contains", Value: "e", Negated: true},
+		})
+		if value := result.(string); !strings.HasPrefix(value, "x") || !strings.HasSuffix(value, "y") || strings.Contains(strings.ToLower(value), "e") {
+			t.Fatalf("Expected a value between x and y without an e, but got: %s", value)
+		}
+	}
+}
+
+func TestSeededSyntheticDataGenerator(t *testing.T) {
+	generate := func(seed int64) []string {
+		generator := modifiers.NewSeededSyntheticDataGenerator(seed)
+		comparator, err := generator.GetComparator("contains")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var values []string
+		for _, tc := range [][2]string{{"value", "contains"}, {`\d{3}-[a-z]+`, "re"}, {"10.0.0.0/8", "cidr"}, {"5", "gt"}} {
+			value, err := generator.GenerateSyntheticValue(tc[0], tc[1])
+			if err != nil {
+				t.Fatal(err)
+			}
+			values = append(values, value)
+		}
+		query, err := comparator("CommandLine", "value")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return append(values, query, fmt.Sprint(generator.GenerateConstrainedValue(nil)))
+	}
+
+	first, second := generate(42), generate(42)
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("Expected generators with the same seed to give the same values, but got: %v and %v", first, second)
+	}
+	if other := generate(43); reflect.DeepEqual(first, other) {
+		t.Errorf("Expected generators with different seeds to give different values, but got: %v", other)
+	}
+}
+
+// TestSyntheticDataGeneratorConcurrent shares a generator between goroutines, run it with the race detector (go test -race).
+func TestSyntheticDataGeneratorConcurrent(t *testing.T) {
+	generator := modifiers.NewSeededSyntheticDataGenerator(42)
 
-	ip, _, err := net.ParseCIDR(expectedCIDR)
-	if err != nil || result != ip.String() {
-		t.Errorf("Expected result to match CIDR block %s, but got: %s", expectedCIDR, result)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if result, err := generator.GenerateSyntheticValue("value", "contains"); err != nil || !strings.Contains(result, "value") {
+					t.Errorf("Expected result to contain value, but got: %s", result)
+				}
+				generator.GenerateConstrainedValue([]modifiers.Constraint{{Operator: "gt", Value: 5}, {Operator: "lt", Value: 10}})
+				generator.Rand().Int63()
+			}
+		}()
 	}
+	wg.Wait()
 }
@@ -0,0 +1,134 @@
+package sevaluator
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mtnmunuklu/logen/sigma"
+)
+
+// nearSearches returns the expressions of a near condition that each need an event of their own.
+// Every node of an 'and' operation needs its own event, the first node of an 'or' operation is enough,
+// and negated expressions must not match any event, so they don't need one.
+func nearSearches(search sigma.SearchExpr) []sigma.SearchExpr {
+	switch s := search.(type) {
+	case sigma.And:
+		var searches []sigma.SearchExpr
+		for _, node := range s {
+			searches = append(searches, nearSearches(node)...)
+		}
+		return searches
+
+	case sigma.Or:
+		if len(s) == 0 {
+			return nil
+		}
+		return nearSearches(s[0])
+
+	case sigma.Not:
+		return nil
+	}
+	return []sigma.SearchExpr{search}
+}
+
+// renderNear appends the expression of a near condition to the pseudo-query of its search, in which the searches are replaced by their trees.
+func (rule RuleEvaluator) renderNear(ctx context.Context, query string, near sigma.Near, searches map[string]QueryNode) (string, error) {
+	node, err := rule.compileSearchExpression(ctx, near.Condition, searches)
+	if err != nil {
+		return "", err
+	}
+	return query + " | near " + node.String(), nil
+}
+
+// generateNear builds an event that matches the search of a near condition, followed by an event for each expression that has to match close to it.
+// The events are timestamped within the timeframe of the rule by scheduleCondition.
+func (rule RuleEvaluator) generateNear(ctx context.Context, node QueryNode, near sigma.Near) ([]Event, error) {
+	builder, err := rule.constrainQuery(ctx, node)
+	if err != nil {
+		return nil, err
+	}
+	event, err := builder.build()
+	if err != nil {
+		return nil, err
+	}
+	events := []Event{event}
+
+	for _, nearSearch := range nearSearches(near.Condition) {
+		event, err := rule.generateEvent(ctx, nearSearch)
+		if err != nil {
+			return nil, fmt.Errorf("error generating near event: %w", err)
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// matchesNearSearch returns whether an event matches any of the expressions of a near condition that need an event of their own.
+func (rule RuleEvaluator) matchesNearSearch(near sigma.Near, searches map[string]bool) (bool, error) {
+	for _, nearSearch := range nearSearches(near.Condition) {
+		match, err := rule.matchSearchExpression(nearSearch, searches)
+		if err != nil || match {
+			return match, err
+		}
+	}
+	return false, nil
+}
+
+// matchNear returns whether any event that matches the search of a condition has events around it that match its near expression.
+// Events are around each other if they are logged within the timeframe of the rule, before or after.
+func (rule RuleEvaluator) matchNear(conditionIndex int, near sigma.Near, events []Event, results []MatchResult) (bool, error) {
+	timeframe := rule.timeframe()
+	for i, result := range results {
+		if !result.Conditions[conditionIndex] {
+			continue
+		}
+
+		var window []MatchResult
+		for j, event := range events {
+			if offset := event.Timestamp.Sub(events[i].Timestamp); j != i && offset > -timeframe && offset < timeframe {
+				window = append(window, results[j])
+			}
+		}
+
+		match, err := rule.matchWindow(near.Condition, window)
+		if err != nil || match {
+			return match, err
+		}
+	}
+	return false, nil
+}
+
+// matchWindow evaluates a near expression over a window of events: a search matches the window if it matches any of its events.
+func (rule RuleEvaluator) matchWindow(search sigma.SearchExpr, window []MatchResult) (bool, error) {
+	switch s := search.(type) {
+	case sigma.And:
+		for _, node := range s {
+			match, err := rule.matchWindow(node, window)
+			if err != nil || !match {
+				return false, err
+			}
+		}
+		return true, nil
+
+	case sigma.Or:
+		for _, node := range s {
+			match, err := rule.matchWindow(node, window)
+			if err != nil || match {
+				return match, err
+			}
+		}
+		return false, nil
+
+	case sigma.Not:
+		match, err := rule.matchWindow(s.Expr, window)
+		return !match, err
+	}
+
+	for _, result := range window {
+		match, err := rule.matchSearchExpression(search, result.Searches)
+		if err != nil || match {
+			return match, err
+		}
+	}
+	return false, nil
+}
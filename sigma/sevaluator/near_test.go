@@ -0,0 +1,91 @@
+package sevaluator_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mtnmunuklu/logen/sigma"
+	"github.com/mtnmunuklu/logen/sigma/sevaluator"
+)
+
+const nearTestRule = `
+title: Near Test
+logsource:
+  category: image_load
+  product: windows
+detection:
+  selection:
+    Image|endswith: '\rundll32.exe'
+  dllload1:
+    ImageLoaded|endswith: '\comsvcs.dll'
+  dllload2:
+    ImageLoaded|endswith: '\dbghelp.dll'
+  exclusion:
+    User: SYSTEM
+  timeframe: 30s
+  condition: selection | near dllload1 and dllload2 and not exclusion
+`
+
+// TestRuleEvaluator_Near checks that near conditions get an event for the search and for each search of the near expression within the timeframe.
+func TestRuleEvaluator_Near(t *testing.T) {
+	rule, err := sigma.ParseRule([]byte(nearTestRule))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	evaluator := sevaluator.ForRule(rule)
+	result, err := evaluator.Alters(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The near expression is part of the query
+	query := `image endswith '\rundll32.exe' | near imageloaded endswith '\comsvcs.dll' and imageloaded endswith '\dbghelp.dll' and not user equal 'system'`
+	if result.Queries[0] != query {
+		t.Errorf("expected query:\n%s\ngot:\n%s", query, result.Queries[0])
+	}
+
+	events := result.Events[0]
+	if len(events) != 3 {
+		t.Fatalf("expected an event for the selection and each dll load, got %d: %v", len(events), events)
+	}
+	for _, event := range events {
+		if offset := event.Timestamp.Sub(events[0].Timestamp); offset < 0 || offset >= 30*time.Second {
+			t.Errorf("event logged outside the timeframe at %v", offset)
+		}
+	}
+	if !result.Verifications[0].Passed() {
+		t.Errorf("expected the events to pass verification, got %+v", result.Verifications[0])
+	}
+
+	late := append([]sevaluator.Event{}, events...)
+	late[2] = sevaluator.Event{Fields: events[2].Fields, Timestamp: events[0].Timestamp.Add(time.Minute)}
+
+	excluded := append([]sevaluator.Event{}, events...)
+	excluded = append(excluded, sevaluator.Event{Fields: map[string]interface{}{"User": "SYSTEM"}, Timestamp: events[0].Timestamp})
+
+	tt := []struct {
+		Name   string
+		Events []sevaluator.Event
+		Match  bool
+	}{
+		{"Generated", events, true},
+		{"MissingNearEvent", events[:2], false},
+		{"MissingSelection", events[1:], false},
+		{"OutsideTimeframe", late, false},
+		{"Excluded", excluded, false},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			match, err := evaluator.MatchesAggregation(context.Background(), 0, tc.Events)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if match != tc.Match {
+				t.Errorf("expected match %v, got %v", tc.Match, match)
+			}
+		})
+	}
+}
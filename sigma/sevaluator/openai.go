@@ -2,14 +2,13 @@ package sevaluator
 
 import (
 	"context"
-	"fmt"
 
 	"github.com/sashabaranov/go-openai"
 )
 
 // OpenAIService interface defines the operations for OpenAI service
 type OpenAIService interface {
-	CreateChatCompletion(context.Context, openai.ChatCompletionRequest) (*openai.ChatCompletionResponse, error)
+	CreateChatCompletion(context.Context, openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error)
 }
 
 // OpenAIClient implements OpenAIService
@@ -24,28 +23,19 @@ func NewOpenAIClient(apiKey string) *OpenAIClient {
 	}
 }
 
-// SendMessageToOpenAI sends a message to OpenAI and returns the response
-func SendMessageToOpenAI(apiKey, content string) (string, error) {
-	openAIClient := NewOpenAIClient(apiKey)
-
-	request := openai.ChatCompletionRequest{
-		Model: openai.GPT3Dot5Turbo,
-		Messages: []openai.ChatCompletionMessage{
-			{
-				Role:    openai.ChatMessageRoleUser,
-				Content: content,
-			},
-		},
+// NewOpenAIClientWithConfig creates a new OpenAIClient instance for any OpenAI-compatible API
+func NewOpenAIClientWithConfig(config openai.ClientConfig) *OpenAIClient {
+	return &OpenAIClient{
+		Client: openai.NewClientWithConfig(config),
 	}
+}
 
-	resp, err := openAIClient.CreateChatCompletion(context.Background(), request)
+// SendMessageToOpenAI sends a message to OpenAI using the default model and returns the response
+func SendMessageToOpenAI(apiKey, content string) (string, error) {
+	provider, err := NewProvider(ProviderConfig{Backend: OpenAIBackend, APIKey: apiKey})
 	if err != nil {
-		return "", fmt.Errorf("ChatCompletion error: %v", err)
-	}
-
-	if len(resp.Choices) > 0 {
-		return resp.Choices[0].Message.Content, nil
+		return "", err
 	}
 
-	return "", fmt.Errorf("empty response from OpenAI")
+	return provider.SendMessage(context.Background(), content)
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/mtnmunuklu/logen/sigma"
+	"github.com/mtnmunuklu/logen/sigma/sevaluator/modifiers"
 )
 
 // Option is a function that takes a RuleEvaluator pointer and modifies its configuration
@@ -32,8 +33,61 @@ func WithConfig(config ...sigma.Config) Option {
 	}
 }
 
+// WithKeywordFields returns an Option that sets the free-text event fields that Sigma keywords are searched in, e.g. message, CommandLine or raw.
+// The fields replace any keyword fields declared by configs that were applied before this option.
+func WithKeywordFields(fields ...string) Option {
+	return func(e *RuleEvaluator) {
+		e.keywordFields = fields
+	}
+}
+
 // CaseSensitive turns off the default Sigma behaviour that string operations are by default case-insensitive
 // This can increase performance (especially for larger events) by skipping expensive calls to strings.ToLower
 func CaseSensitive(e *RuleEvaluator) {
 	e.caseSensitive = true
 }
+
+// NegativeSamples turns on the generation of near-miss events that must not satisfy the conditions of the rule.
+// They are stored in the Negatives map of the Result and can be used to test rules for false positives.
+func NegativeSamples(e *RuleEvaluator) {
+	e.negativeSamples = true
+}
+
+// WithTimeline returns an Option that sets how the generated events are spread over time,
+// e.g. the time of the first event, the distribution of the time between events and the time zone of their timestamps.
+func WithTimeline(timeline Timeline) Option {
+	return func(e *RuleEvaluator) {
+		e.timeline = timeline
+	}
+}
+
+// WithGenerator returns an Option that sets the generator that synthetic values and the time between events are drawn from.
+// A generator created with modifiers.NewSeededSyntheticDataGenerator makes the output reproducible:
+// the same rule, options and seed give the same events and queries, and the same timestamps if the timeline has a fixed start.
+// Generators can be shared by evaluators that run concurrently, but a seeded generator only gives reproducible output
+// if it is called in the same order, so parallel evaluations should each get a generator of their own.
+// By default the global generator of the modifiers package is used.
+func WithGenerator(generator *modifiers.SyntheticDataGenerator) Option {
+	return func(e *RuleEvaluator) {
+		e.generator = generator
+	}
+}
+
+// WithBackend returns an Option that renders the Queries of the Result in the query language of a SIEM, such as Splunk SPL or ES|QL,
+// instead of the Sigma-like pseudo-queries used by default. Backends are looked up by name with LookupBackend.
+// Rules with predicates that the query language can't express fail with an error that wraps ErrUnsupported.
+func WithBackend(backend Backend) Option {
+	return func(e *RuleEvaluator) {
+		e.backend = backend
+	}
+}
+
+// WithMaxBranches returns an Option that sets the maximum number of events generated for a condition without an aggregation.
+// Every condition is expanded into the branches of its disjunctive normal form, e.g. one for each search of '1 of selection*'
+// and each value of a field matcher, and an event is generated for each satisfiable branch up to the maximum.
+// DefaultMaxBranches is used if it's zero, and a maximum of 1 generates a single event for the first branch.
+func WithMaxBranches(max int) Option {
+	return func(e *RuleEvaluator) {
+		e.maxBranches = max
+	}
+}
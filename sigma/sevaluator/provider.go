@@ -0,0 +1,171 @@
+package sevaluator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// Provider interface defines the operations for a language model backend that enriches synthetic logs
+type Provider interface {
+	SendMessage(ctx context.Context, content string) (string, error)
+}
+
+// Supported Provider backends
+const (
+	OpenAIBackend = "openai" // The OpenAI API
+	LocalBackend  = "local"  // Any OpenAI-compatible server, such as llama.cpp or Ollama
+	AzureBackend  = "azure"  // Azure OpenAI
+	MockBackend   = "mock"   // Recorded responses loaded from a fixture file
+)
+
+// DefaultLocalBaseURL is the base URL used by the local backend if none is given (Ollama's OpenAI-compatible API)
+const DefaultLocalBaseURL = "http://localhost:11434/v1"
+
+// ProviderConfig holds the settings used to construct a Provider
+type ProviderConfig struct {
+	Backend     string        // The backend to use, one of OpenAIBackend, LocalBackend, AzureBackend or MockBackend
+	APIKey      string        // The API key sent to the backend
+	BaseURL     string        // The base URL of the backend API (the resource endpoint for Azure)
+	APIVersion  string        // The API version, only used by Azure
+	Model       string        // The model (or Azure deployment) used for completions
+	Temperature float32       // The sampling temperature, the backend default is used if zero
+	MaxTokens   int           // The maximum number of tokens to generate, the backend default is used if zero
+	Timeout     time.Duration // The timeout of a single request, no timeout is applied if zero
+	FixturePath string        // The path of the recorded responses, only used by the mock backend
+
+	RequestsPerSecond float64 // The maximum number of requests sent per second, shared by concurrent callers, unlimited if zero
+	MaxRetries        int     // The maximum number of times a request that gets a 429 Too Many Requests response is retried, honouring its Retry-After header
+}
+
+// NewProvider creates a new Provider for the backend selected in the config
+func NewProvider(config ProviderConfig) (Provider, error) {
+	if config.Model == "" {
+		config.Model = openai.GPT3Dot5Turbo
+	}
+	if config.Backend == "" {
+		config.Backend = OpenAIBackend
+	}
+
+	var clientConfig openai.ClientConfig
+	switch config.Backend {
+	case OpenAIBackend:
+		clientConfig = openai.DefaultConfig(config.APIKey)
+		if config.BaseURL != "" {
+			clientConfig.BaseURL = config.BaseURL
+		}
+	case LocalBackend:
+		clientConfig = openai.DefaultConfig(config.APIKey)
+		clientConfig.BaseURL = config.BaseURL
+		if clientConfig.BaseURL == "" {
+			clientConfig.BaseURL = DefaultLocalBaseURL
+		}
+	case AzureBackend:
+		if config.BaseURL == "" {
+			return nil, fmt.Errorf("azure backend requires a base URL")
+		}
+		clientConfig = openai.DefaultAzureConfig(config.APIKey, config.BaseURL)
+		if config.APIVersion != "" {
+			clientConfig.APIVersion = config.APIVersion
+		}
+	case MockBackend:
+		return NewMockProvider(config.FixturePath)
+	default:
+		return nil, fmt.Errorf("unknown backend %s", config.Backend)
+	}
+
+	// Requests are spaced out and retried when the backend rate limits them
+	if config.RequestsPerSecond > 0 || config.MaxRetries > 0 {
+		clientConfig.HTTPClient = &http.Client{Transport: &retryTransport{
+			base:       http.DefaultTransport,
+			limiter:    newRateLimiter(config.RequestsPerSecond),
+			maxRetries: config.MaxRetries,
+		}}
+	}
+
+	return &ChatProvider{
+		Service: NewOpenAIClientWithConfig(clientConfig),
+		Config:  config,
+	}, nil
+}
+
+// ChatProvider implements Provider on top of any OpenAIService, it is safe for concurrent use
+type ChatProvider struct {
+	Service OpenAIService  // The chat completion service used to send messages
+	Config  ProviderConfig // The model settings used for each request
+}
+
+// SendMessage sends a message to the chat completion service and returns the response
+func (p *ChatProvider) SendMessage(ctx context.Context, content string) (string, error) {
+	if p.Config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.Config.Timeout)
+		defer cancel()
+	}
+
+	request := openai.ChatCompletionRequest{
+		Model:       p.Config.Model,
+		Temperature: p.Config.Temperature,
+		MaxTokens:   p.Config.MaxTokens,
+		Messages: []openai.ChatCompletionMessage{
+			{
+				Role:    openai.ChatMessageRoleUser,
+				Content: content,
+			},
+		},
+	}
+
+	resp, err := p.Service.CreateChatCompletion(ctx, request)
+	if err != nil {
+		return "", fmt.Errorf("ChatCompletion error: %w", err)
+	}
+
+	if len(resp.Choices) > 0 {
+		return resp.Choices[0].Message.Content, nil
+	}
+
+	return "", fmt.Errorf("empty response from %s", p.Config.Backend)
+}
+
+// MockProvider implements Provider by replaying recorded responses
+type MockProvider struct {
+	Responses map[string]string `json:"responses"` // The map of messages to their recorded responses
+	Default   string            `json:"default"`   // The response to messages that haven't been recorded, if not empty
+}
+
+// NewMockProvider creates a new MockProvider from a JSON fixture file
+func NewMockProvider(fixturePath string) (*MockProvider, error) {
+	if fixturePath == "" {
+		return nil, fmt.Errorf("mock backend requires a fixture file")
+	}
+
+	contents, err := os.ReadFile(fixturePath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading fixture file: %w", err)
+	}
+
+	provider := &MockProvider{}
+	if err := json.Unmarshal(contents, provider); err != nil {
+		return nil, fmt.Errorf("error parsing fixture file: %w", err)
+	}
+
+	return provider, nil
+}
+
+// SendMessage returns the recorded response to the message
+func (p *MockProvider) SendMessage(ctx context.Context, content string) (string, error) {
+	if response, ok := p.Responses[content]; ok {
+		return response, nil
+	}
+
+	if p.Default != "" {
+		return p.Default, nil
+	}
+
+	return "", fmt.Errorf("no recorded response for message")
+}
@@ -0,0 +1,190 @@
+package sevaluator_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mtnmunuklu/logen/sigma/sevaluator"
+	"github.com/sashabaranov/go-openai"
+)
+
+// TestLocalProvider checks that the local backend sends the configured model settings to an OpenAI-compatible server.
+func TestLocalProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+
+		var request openai.ChatCompletionRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Fatal(err)
+		}
+		if request.Model != "llama3" || request.MaxTokens != 128 || request.Messages[0].Content != "hello" {
+			t.Errorf("unexpected request %+v", request)
+		}
+
+		json.NewEncoder(w).Encode(openai.ChatCompletionResponse{
+			Choices: []openai.ChatCompletionChoice{{Message: openai.ChatCompletionMessage{Content: "world"}}},
+		})
+	}))
+	defer server.Close()
+
+	provider, err := sevaluator.NewProvider(sevaluator.ProviderConfig{
+		Backend:   sevaluator.LocalBackend,
+		BaseURL:   server.URL + "/v1",
+		Model:     "llama3",
+		MaxTokens: 128,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response, err := provider.SendMessage(context.Background(), "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response != "world" {
+		t.Errorf("expected response world, got %s", response)
+	}
+}
+
+// TestDefaultProvider checks that the OpenAI backend is used if no backend is configured, and named in its errors.
+func TestDefaultProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(openai.ChatCompletionResponse{})
+	}))
+	defer server.Close()
+
+	provider, err := sevaluator.NewProvider(sevaluator.ProviderConfig{BaseURL: server.URL + "/v1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := provider.SendMessage(context.Background(), "hello"); err == nil || err.Error() != "empty response from openai" {
+		t.Errorf("expected an empty response error naming the openai backend, got %v", err)
+	}
+}
+
+// TestMockProvider checks that the mock backend replays recorded responses.
+func TestMockProvider(t *testing.T) {
+	fixturePath := filepath.Join(t.TempDir(), "fixture.json")
+	fixture := `{"responses": {"hello": "world"}, "default": "fallback"}`
+	if err := os.WriteFile(fixturePath, []byte(fixture), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	provider, err := sevaluator.NewProvider(sevaluator.ProviderConfig{Backend: sevaluator.MockBackend, FixturePath: fixturePath})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for content, expected := range map[string]string{"hello": "world", "unknown": "fallback"} {
+		response, err := provider.SendMessage(context.Background(), content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if response != expected {
+			t.Errorf("expected response %s for %s, got %s", expected, content, response)
+		}
+	}
+}
+
+// TestProviderRetryAfter checks that requests that are rate limited are retried after the time given by their Retry-After header.
+func TestProviderRetryAfter(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request openai.ChatCompletionRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Messages[0].Content != "hello" {
+			t.Errorf("expected every attempt to send the message, got %+v (%v)", request, err)
+		}
+
+		// The first two attempts are rate limited
+		if atomic.AddInt32(&requests, 1) <= 2 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			json.NewEncoder(w).Encode(map[string]any{"error": map[string]any{"message": "rate limited"}})
+			return
+		}
+		json.NewEncoder(w).Encode(openai.ChatCompletionResponse{
+			Choices: []openai.ChatCompletionChoice{{Message: openai.ChatCompletionMessage{Content: "world"}}},
+		})
+	}))
+	defer server.Close()
+
+	for _, tc := range []struct {
+		MaxRetries int
+		Expected   string
+	}{
+		{1, ""},
+		{2, "world"},
+	} {
+		atomic.StoreInt32(&requests, 0)
+		provider, err := sevaluator.NewProvider(sevaluator.ProviderConfig{Backend: sevaluator.LocalBackend, BaseURL: server.URL + "/v1", MaxRetries: tc.MaxRetries})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		response, err := provider.SendMessage(context.Background(), "hello")
+		if tc.Expected == "" {
+			// The error of the backend is wrapped, so callers can inspect it
+			var apiError *openai.APIError
+			if err == nil || !strings.Contains(err.Error(), "429") || !errors.As(err, &apiError) || apiError.HTTPStatusCode != http.StatusTooManyRequests {
+				t.Errorf("expected a 429 error after %d retries, got %v", tc.MaxRetries, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if response != tc.Expected {
+			t.Errorf("expected response %s, got %s", tc.Expected, response)
+		}
+	}
+}
+
+// TestProviderRateLimit checks that concurrent requests are spaced out to the configured rate, and that waiting requests are cancelled with their context.
+func TestProviderRateLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(openai.ChatCompletionResponse{
+			Choices: []openai.ChatCompletionChoice{{Message: openai.ChatCompletionMessage{Content: "world"}}},
+		})
+	}))
+	defer server.Close()
+
+	provider, err := sevaluator.NewProvider(sevaluator.ProviderConfig{Backend: sevaluator.LocalBackend, BaseURL: server.URL + "/v1", RequestsPerSecond: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := provider.SendMessage(context.Background(), "hello"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("expected 5 requests at 20 per second to take at least 200ms, took %v", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := provider.SendMessage(ctx, "hello"); err == nil {
+		t.Errorf("expected a cancelled request to fail")
+	}
+}
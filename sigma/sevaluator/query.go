@@ -0,0 +1,360 @@
+package sevaluator
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mtnmunuklu/logen/sigma/sevaluator/modifiers"
+)
+
+// QueryNode is a node of the query tree that the searches and conditions of a rule are compiled to.
+// The tree is shared by the consumers of a rule: the pseudo-queries of the Result are its String,
+// a Backend renders it in the language of a SIEM, and the synthetic events are generated to satisfy it.
+type QueryNode interface {
+	queryNode()     // queryNode is an empty marker method used to identify the types that implement the QueryNode interface
+	String() string // String renders the node as a Sigma-like pseudo-query
+}
+
+// And is the conjunction of query nodes. An empty conjunction matches every event.
+type And []QueryNode
+
+// Or is the disjunction of query nodes. An empty disjunction matches no event.
+type Or []QueryNode
+
+// Not is the negation of a query node.
+type Not struct {
+	Node QueryNode
+}
+
+// FieldPredicate is the comparison of a single event field with a single value, the leaves of the query tree.
+type FieldPredicate struct {
+	Field         string // The event field, after the field mappings of the config are applied
+	Operator      string // The comparator: equal, contains, startswith, endswith, re, cidr, gt, gte, lt, lte or exists
+	Value         string // The expected value after the value modifiers are applied, true or false for exists
+	Reference     bool   // Whether the value is the name of another event field, as with the fieldref modifier
+	Numeric       bool   // Whether the value of an equal predicate is a number in the rule, which is compared as a number rather than a string
+	CaseSensitive bool   // Whether strings are compared case-sensitively, Sigma compares them case-insensitively by default
+	Synthetic     string // A synthetic value of the field that satisfies the predicate, empty unless the tree is part of a Result
+}
+
+func (And) queryNode()            {}
+func (Or) queryNode()             {}
+func (Not) queryNode()            {}
+func (FieldPredicate) queryNode() {}
+
+// String joins the operands of the conjunction with "and".
+func (q And) String() string {
+	return strings.Join(groupQueries(q), " and ")
+}
+
+// String joins the operands of the disjunction with "or".
+func (q Or) String() string {
+	return strings.Join(groupQueries(q), " or ")
+}
+
+// String prefixes the operand with "not".
+func (q Not) String() string {
+	operand := groupQueries([]QueryNode{q.Node})
+	if len(operand) == 0 {
+		return ""
+	}
+	return "not " + operand[0]
+}
+
+// String renders the predicate with its comparator and expected value, as the rule states it.
+// The synthetic value isn't rendered, so that negated predicates and those that match many values read as the rule does.
+func (p FieldPredicate) String() string {
+	field := strings.ToLower(p.Field)
+	switch {
+	case p.Operator == "exists" && p.Value == "false":
+		return "not " + field + " exists"
+	case p.Operator == "exists":
+		return field + " exists"
+	case p.Reference:
+		return fmt.Sprintf("%s %s %s", field, p.Operator, strings.ToLower(p.Value))
+	}
+
+	operator, value := p.Operator, p.Value
+	// The Sigma spec defines that by default comparisons are case-insensitive
+	if !p.CaseSensitive && (operator == "equal" || operator == "contains" || operator == "startswith" || operator == "endswith") {
+		value = strings.ToLower(value)
+	}
+	return fmt.Sprintf("%s %s '%s'", field, operator, value)
+}
+
+// Pattern returns the Sigma wildcard pattern that the value of a string predicate has to match.
+// The contains, startswith and endswith comparators are turned into patterns with leading and trailing wildcards.
+func (p FieldPredicate) Pattern() string {
+	return modifiers.GlobPattern(p.Operator, p.Value)
+}
+
+// constraint returns the constraint that the value of the field has to satisfy to match the predicate.
+func (p FieldPredicate) constraint() modifiers.Constraint {
+	if p.Reference {
+		return modifiers.Constraint{Operator: p.Operator, Value: modifiers.FieldRef{Field: p.Value}}
+	}
+	return modifiers.Constraint{Operator: p.Operator, Value: p.Value}
+}
+
+// groupQueries renders the nodes that aren't empty, with the ones that combine several operands in parentheses.
+func groupQueries(nodes []QueryNode) []string {
+	var operands []string
+	for _, node := range nodes {
+		text := node.String()
+		if text == "" {
+			continue
+		}
+		switch n := node.(type) {
+		case And:
+			if len(n) > 1 {
+				text = "(" + text + ")"
+			}
+		case Or:
+			if len(n) > 1 {
+				text = "(" + text + ")"
+			}
+		}
+		operands = append(operands, text)
+	}
+	return operands
+}
+
+// searchQueries returns the pseudo-queries of the operands of a search tree, or of the tree itself if it isn't a conjunction.
+func searchQueries(node QueryNode) []string {
+	if and, ok := node.(And); ok {
+		return groupQueries(and)
+	}
+	return groupQueries([]QueryNode{node})
+}
+
+// conjunction returns the conjunction of the nodes, or the node itself if there is only one.
+func conjunction(nodes []QueryNode) QueryNode {
+	if len(nodes) == 1 {
+		return nodes[0]
+	}
+	return And(nodes)
+}
+
+// disjunction returns the disjunction of the nodes, or the node itself if there is only one.
+func disjunction(nodes []QueryNode) QueryNode {
+	if len(nodes) == 1 {
+		return nodes[0]
+	}
+	return Or(nodes)
+}
+
+// negationNormalForm returns an equivalent query tree in which only predicates are negated.
+// Negated conjunctions and disjunctions are rewritten with De Morgan's laws and double negations cancel out,
+// so that the polarity of every predicate is explicit: a negated predicate must not be satisfied by a matching event.
+func negationNormalForm(node QueryNode) QueryNode {
+	return normalize(node, false)
+}
+
+// normalize returns the negation normal form of a query tree, or of its negation if negated is true.
+func normalize(node QueryNode, negated bool) QueryNode {
+	switch n := node.(type) {
+	case And:
+		operands := make([]QueryNode, len(n))
+		for i, operand := range n {
+			operands[i] = normalize(operand, negated)
+		}
+		// not (a and b) = not a or not b
+		if negated {
+			return Or(operands)
+		}
+		return And(operands)
+
+	case Or:
+		operands := make([]QueryNode, len(n))
+		for i, operand := range n {
+			operands[i] = normalize(operand, negated)
+		}
+		// not (a or b) = not a and not b
+		if negated {
+			return And(operands)
+		}
+		return Or(operands)
+
+	case Not:
+		return normalize(n.Node, !negated)
+
+	case FieldPredicate:
+		if negated {
+			return Not{Node: n}
+		}
+		return n
+	}
+	return node
+}
+
+// disjunctiveNormalForm returns the conjuncts of the disjunctive normal form of a query tree: conjunctions of predicates and negated predicates,
+// any of which is enough to satisfy the tree. Conjuncts that are obviously contradictory or repeat an earlier one are left out.
+// At most max conjuncts are returned, in the order of the operands of the disjunctions in the tree, so the first conjunct is the one that
+// eventBuilder.constrain picks, and the operands of the first disjunctions of a conjunction are enumerated before those of the later ones.
+func disjunctiveNormalForm(node QueryNode, max int) []And {
+	var conjuncts []And
+	seen := map[string]bool{}
+	for _, conjunct := range expand(negationNormalForm(node), max) {
+		if key := conjunct.String(); !seen[key] {
+			seen[key] = true
+			conjuncts = append(conjuncts, conjunct)
+		}
+	}
+	return conjuncts
+}
+
+// expand returns up to max satisfiable conjuncts of the disjunctive normal form of a query tree in negation normal form.
+func expand(node QueryNode, max int) []And {
+	switch n := node.(type) {
+	// a conjunct of a conjunction combines a conjunct of every operand
+	case And:
+		conjuncts := []And{{}}
+		for _, operand := range n {
+			var combined []And
+			for _, operandConjunct := range expand(operand, max) {
+				for _, conjunct := range conjuncts {
+					if len(combined) == max {
+						break
+					}
+					// contradictions can only grow, so contradictory conjuncts are pruned before they are combined further
+					if candidate := append(conjunct[:len(conjunct):len(conjunct)], operandConjunct...); satisfiable(candidate) {
+						combined = append(combined, candidate)
+					}
+				}
+			}
+			conjuncts = combined
+		}
+		return conjuncts
+
+	// the conjuncts of a disjunction are those of its operands
+	case Or:
+		var conjuncts []And
+		for _, operand := range n {
+			if len(conjuncts) == max {
+				break
+			}
+			conjuncts = append(conjuncts, expand(operand, max-len(conjuncts))...)
+		}
+		return conjuncts
+	}
+
+	// predicates and negated predicates are conjuncts of their own
+	if max == 0 {
+		return nil
+	}
+	return []And{{node}}
+}
+
+// satisfiable returns false if a conjunction of predicates and negated predicates is obviously contradictory:
+// if it requires a predicate and its negation, a field that must be both present and absent, or a field that must equal two different values.
+// Other contradictions, e.g. a value that must start with two different prefixes, aren't detected.
+func satisfiable(conjunct And) bool {
+	required := map[FieldPredicate]bool{}
+	negated := map[FieldPredicate]bool{}
+	present := map[string]bool{}
+	absent := map[string]bool{}
+	equals := map[string]string{}
+	for _, literal := range conjunct {
+		predicate, ok := literal.(FieldPredicate)
+		isNegated := false
+		if not, isNot := literal.(Not); isNot {
+			predicate, ok = not.Node.(FieldPredicate)
+			isNegated = true
+		}
+		if !ok {
+			continue
+		}
+		// The same predicate of different searches has different synthetic values
+		predicate.Synthetic = ""
+
+		if isNegated {
+			if required[predicate] {
+				return false
+			}
+			negated[predicate] = true
+		} else {
+			if negated[predicate] {
+				return false
+			}
+			required[predicate] = true
+		}
+
+		switch {
+		// fields with exists false must be absent, as must fields with a negated exists true
+		case predicate.Operator == "exists":
+			if (predicate.Value == "true") != isNegated {
+				present[predicate.Field] = true
+			} else {
+				absent[predicate.Field] = true
+			}
+		// negated predicates are also violated by an absent field
+		case isNegated:
+			continue
+		default:
+			present[predicate.Field] = true
+		}
+		if present[predicate.Field] && absent[predicate.Field] {
+			return false
+		}
+
+		// values without wildcards can only equal one value
+		if predicate.Operator == "equal" && !predicate.Reference && !predicate.Numeric && !strings.ContainsAny(predicate.Value, "*?") {
+			value := predicate.Value
+			if !predicate.CaseSensitive {
+				value = strings.ToLower(value)
+			}
+			if other, ok := equals[predicate.Field]; ok && other != value {
+				return false
+			}
+			equals[predicate.Field] = value
+		}
+	}
+	return true
+}
+
+// synthesize returns a copy of the query tree, in which every predicate has a synthetic value that satisfies it.
+// The values are drawn from the generator of the rule in the order of the predicates, so that a seeded generator gives the same values every time.
+// An error is returned if no value satisfies one of the predicates, e.g. a regex that nothing matches.
+func (rule RuleEvaluator) synthesize(node QueryNode) (QueryNode, error) {
+	switch n := node.(type) {
+	case And:
+		result := make(And, len(n))
+		for i, operand := range n {
+			var err error
+			if result[i], err = rule.synthesize(operand); err != nil {
+				return nil, err
+			}
+		}
+		return result, nil
+
+	case Or:
+		result := make(Or, len(n))
+		for i, operand := range n {
+			var err error
+			if result[i], err = rule.synthesize(operand); err != nil {
+				return nil, err
+			}
+		}
+		return result, nil
+
+	case Not:
+		operand, err := rule.synthesize(n.Node)
+		if err != nil {
+			return nil, err
+		}
+		return Not{Node: operand}, nil
+
+	case FieldPredicate:
+		// There is no single value that satisfies references and exists checks
+		if n.Reference || n.Operator == "exists" {
+			return n, nil
+		}
+		synthetic, err := rule.generator.GenerateSyntheticValue(n.Value, n.Operator)
+		if err != nil {
+			return nil, fmt.Errorf("%s|%s: %w", n.Field, n.Operator, err)
+		}
+		n.Synthetic = synthetic
+		return n, nil
+	}
+	return node, nil
+}
@@ -0,0 +1,148 @@
+package sevaluator_test
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mtnmunuklu/logen/sigma"
+	"github.com/mtnmunuklu/logen/sigma/sevaluator"
+)
+
+const queryTestRule = `
+title: Query Test
+logsource:
+  category: process_creation
+  product: windows
+detection:
+  selection:
+    Image: 'C:\Windows\cmd.exe'
+    CommandLine:
+      - 'a'
+      - 'b'
+  filter_system:
+    User: SYSTEM
+  filter_parent:
+    ParentImage: null
+  condition:
+    - selection and not 1 of filter_*
+    - 1 of them
+`
+
+// TestRuleEvaluator_QueryTree checks the query trees of the searches and conditions, and the pseudo-queries rendered from them.
+// Expansions like '1 of them' follow the order in which the searches are declared in the rule.
+func TestRuleEvaluator_QueryTree(t *testing.T) {
+	rule, err := sigma.ParseRule([]byte(queryTestRule))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := sevaluator.ForRule(rule).Alters(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Values that are compared for equality are their own synthetic values, and null checks that the field doesn't exist
+	filterParent := sevaluator.FieldPredicate{Field: "ParentImage", Operator: "exists", Value: "false"}
+	filterSystem := sevaluator.FieldPredicate{Field: "User", Operator: "equal", Value: "SYSTEM", Synthetic: "SYSTEM"}
+	if !reflect.DeepEqual(result.SearchTrees["filter_parent"], filterParent) || !reflect.DeepEqual(result.SearchTrees["filter_system"], filterSystem) {
+		t.Errorf("unexpected search trees %#v and %#v", result.SearchTrees["filter_parent"], result.SearchTrees["filter_system"])
+	}
+
+	expected := sevaluator.And{result.SearchTrees["selection"], sevaluator.Not{Node: sevaluator.Or{filterSystem, filterParent}}}
+	if !reflect.DeepEqual(result.ConditionTrees[0], expected) {
+		t.Errorf("expected condition tree %#v, got %#v", expected, result.ConditionTrees[0])
+	}
+
+	// The deprecated fields keep the pseudo-queries of the searches and the tokens of the conditions
+	if selection := []string{`image equal 'c:\windows\cmd.exe'`, `(commandline equal 'a' or commandline equal 'b')`}; !reflect.DeepEqual(result.Searches["selection"], selection) {
+		t.Errorf("expected search %#v, got %#v", selection, result.Searches["selection"])
+	}
+	conditions := map[int][]string{
+		0: {"selection", " and ", " not ", "(", "filter_system", " or ", "filter_parent", ")"},
+		1: {"selection", " or ", "filter_system", " or ", "filter_parent"},
+	}
+	if !reflect.DeepEqual(result.Conditions, conditions) {
+		t.Errorf("expected conditions %#v, got %#v", conditions, result.Conditions)
+	}
+
+	queries := map[int]string{
+		0: `(image equal 'c:\windows\cmd.exe' and (commandline equal 'a' or commandline equal 'b')) and not (user equal 'system' or not parentimage exists)`,
+		1: `(image equal 'c:\windows\cmd.exe' and (commandline equal 'a' or commandline equal 'b')) or user equal 'system' or not parentimage exists`,
+	}
+	for i, query := range queries {
+		if result.Queries[i] != query {
+			t.Errorf("expected query %d:\n%s\ngot:\n%s", i, query, result.Queries[i])
+		}
+	}
+}
+
+const encodedQueryTestRule = `
+title: Encoded Query Test
+logsource:
+  product: linux
+detection:
+  selection:
+    CommandLine|base64offset|contains: '/bin/sh'
+  condition: selection
+`
+
+// TestRuleEvaluator_EncodedQuery checks that base64 values are rendered in the case that the modifier encodes them in.
+func TestRuleEvaluator_EncodedQuery(t *testing.T) {
+	rule, err := sigma.ParseRule([]byte(encodedQueryTestRule))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := sevaluator.ForRule(rule).Alters(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query := `commandline contains 'L2Jpbi9za' or commandline contains '9iaW4vc2' or commandline contains 'vYmluL3No'`
+	if result.Queries[0] != query {
+		t.Errorf("expected query:\n%s\ngot:\n%s", query, result.Queries[0])
+	}
+	if !result.Verifications[0].Passed() {
+		t.Errorf("expected the events to pass verification, got %+v", result.Verifications[0])
+	}
+}
+
+const invalidValueTestRule = `
+title: Invalid Value Test
+logsource:
+  category: network_connection
+  product: windows
+detection:
+  selection:
+    %s: '%s'
+  condition: selection
+`
+
+// TestRuleEvaluator_InvalidValues checks that invalid CIDR blocks and regexes are reported when the rule is compiled, naming their field.
+func TestRuleEvaluator_InvalidValues(t *testing.T) {
+	tt := []struct {
+		Field    string
+		Value    string
+		Expected string
+	}{
+		{"DestinationIp|cidr", "10.0.0.0/33", "DestinationIp|cidr: invalid CIDR block 10.0.0.0/33"},
+		{"DestinationIp|cidr", "10.0.0.1", "DestinationIp|cidr: invalid CIDR block 10.0.0.1"},
+		{"Image|re", "cmd(.exe", "Image|re: invalid regex cmd(.exe"},
+		{"Image|re|i", "[a-", "Image|re: invalid regex (?i)[a-"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Field, func(t *testing.T) {
+			rule, err := sigma.ParseRule([]byte(fmt.Sprintf(invalidValueTestRule, tc.Field, tc.Value)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := sevaluator.ForRule(rule).Alters(context.Background()); err == nil || !strings.Contains(err.Error(), tc.Expected) {
+				t.Errorf("expected an error containing %q, got %v", tc.Expected, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package sevaluator
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultRetryBackoff is how long a request that was rate limited waits before it is retried if the response has no Retry-After header.
+// It doubles with every retry of the same request.
+const DefaultRetryBackoff = time.Second
+
+// rateLimiter spaces out requests that are sent concurrently, so that no more than a given number are sent per second.
+// It is shared by all the requests of a Provider, so that a Retry-After response pauses all of them, not only the one that was rate limited.
+type rateLimiter struct {
+	lock     sync.Mutex
+	interval time.Duration // The minimum time between two requests, requests aren't spaced out if zero
+	next     time.Time     // The earliest time at which the next request can be sent
+}
+
+// newRateLimiter creates a rateLimiter that lets through the given number of requests per second, or any number if it's zero.
+func newRateLimiter(requestsPerSecond float64) *rateLimiter {
+	limiter := &rateLimiter{}
+	if requestsPerSecond > 0 {
+		limiter.interval = time.Duration(float64(time.Second) / requestsPerSecond)
+	}
+	return limiter
+}
+
+// Wait blocks until a request can be sent, or the context is done.
+func (l *rateLimiter) Wait(ctx context.Context) error {
+	l.lock.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	wait := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	l.lock.Unlock()
+
+	if wait <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Pause holds back all requests until the given time.
+func (l *rateLimiter) Pause(until time.Time) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.next.Before(until) {
+		l.next = until
+	}
+}
+
+// retryTransport is an http.RoundTripper that sends requests through a rateLimiter,
+// and retries requests that get a 429 Too Many Requests response after the time given by their Retry-After header.
+type retryTransport struct {
+	base       http.RoundTripper // The transport that sends the requests
+	limiter    *rateLimiter      // The limiter shared by all the requests
+	maxRetries int               // The maximum number of times a request is retried
+}
+
+func (t *retryTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		if err := t.limiter.Wait(request.Context()); err != nil {
+			return nil, err
+		}
+
+		// Every attempt needs a fresh copy of the body
+		attemptRequest := request
+		if attempt > 0 && request.GetBody != nil {
+			body, err := request.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			attemptRequest = request.Clone(request.Context())
+			attemptRequest.Body = body
+		}
+
+		response, err := t.base.RoundTrip(attemptRequest)
+		if err != nil || response.StatusCode != http.StatusTooManyRequests || attempt >= t.maxRetries || (request.Body != nil && request.GetBody == nil) {
+			return response, err
+		}
+
+		// Discard the rate limited response and hold back all requests until the backend accepts them again
+		io.Copy(io.Discard, response.Body)
+		response.Body.Close()
+		t.limiter.Pause(time.Now().Add(retryAfter(response, attempt)))
+	}
+}
+
+// retryAfter returns how long to wait before retrying a rate limited request, as given by the Retry-After header of the response,
+// either in seconds or as a date. Without the header, the wait starts at DefaultRetryBackoff and doubles with every attempt.
+func retryAfter(response *http.Response, attempt int) time.Duration {
+	header := response.Header.Get("Retry-After")
+	if seconds, err := strconv.Atoi(header); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(header); err == nil {
+		return time.Until(date)
+	}
+	return DefaultRetryBackoff << attempt
+}
@@ -0,0 +1,151 @@
+package sevaluator
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/mtnmunuklu/logen/sigma"
+)
+
+// Timeline describes how the synthetic events of a rule are spread over time.
+// The zero Timeline logs single events now and spreads the events of an aggregation evenly over the timeframe of the rule.
+type Timeline struct {
+	Start        time.Time      // The time at which the first event is logged, now if zero
+	Interval     time.Duration  // The mean time between consecutive events
+	Jitter       time.Duration  // The maximum random deviation of the time between consecutive events
+	Distribution string         // The distribution of the time between consecutive events, one of the InterArrivals, constant if empty
+	Location     *time.Location // The time zone that the timestamps are expressed in, the local time zone if nil
+
+	// Whether negative events are logged after the timeframe of the positive events of their condition,
+	// so that they can't take part in the same aggregation window
+	NegativesOutsideTimeframe bool
+}
+
+// InterArrival is a function that draws the time between two consecutive events from a distribution with the given mean.
+// The random values are drawn from the source of randomness of the generator of the rule, so that seeded runs are reproducible.
+type InterArrival func(random *rand.Rand, mean time.Duration) time.Duration
+
+// InterArrivals maps the names of the supported distributions to the functions that draw from them.
+var InterArrivals = map[string]InterArrival{
+	// Events are logged at a fixed rate
+	"constant": func(random *rand.Rand, mean time.Duration) time.Duration {
+		return mean
+	},
+	// Any time between zero and twice the mean is equally likely
+	"uniform": func(random *rand.Rand, mean time.Duration) time.Duration {
+		if mean <= 0 {
+			return 0
+		}
+		return time.Duration(random.Int63n(2*int64(mean) + 1))
+	},
+	// Events arrive independently of each other, like in a Poisson process
+	"exponential": func(random *rand.Rand, mean time.Duration) time.Duration {
+		return time.Duration(random.ExpFloat64() * float64(mean))
+	},
+}
+
+// timeframe returns the time window of the aggregations of the rule, or DefaultTimeframe if the rule doesn't declare one.
+func (rule RuleEvaluator) timeframe() time.Duration {
+	if rule.Detection.Timeframe > 0 {
+		return rule.Detection.Timeframe
+	}
+	return DefaultTimeframe
+}
+
+// interArrival returns the distribution of the time between consecutive events of the timeline.
+func (t Timeline) interArrival() (InterArrival, error) {
+	if t.Distribution == "" {
+		return InterArrivals["constant"], nil
+	}
+	interArrival, ok := InterArrivals[t.Distribution]
+	if !ok {
+		return nil, fmt.Errorf("unknown inter-arrival distribution %s", t.Distribution)
+	}
+	return interArrival, nil
+}
+
+// intervals draws the times between count consecutive events with the given mean, jittered by up to Jitter in either direction.
+func (t Timeline) intervals(random *rand.Rand, interArrival InterArrival, count int, mean time.Duration) []time.Duration {
+	intervals := make([]time.Duration, count)
+	for i := range intervals {
+		interval := interArrival(random, mean)
+		if t.Jitter > 0 {
+			interval += time.Duration(random.Int63n(2*int64(t.Jitter)+1)) - t.Jitter
+		}
+		if interval < 0 {
+			interval = 0
+		}
+		intervals[i] = interval
+	}
+	return intervals
+}
+
+// scheduleCondition assigns timestamps to the positive and negative events of a condition, starting at the given time.
+// The events of an aggregation are squeezed into the timeframe of the rule if their intervals would spread them over a longer time.
+// It returns the time at which the events of the next condition start.
+func (rule RuleEvaluator) scheduleCondition(interArrival InterArrival, start time.Time, condition sigma.Condition, events []Event, negatives []Event) time.Time {
+	timeline := rule.timeline
+	timeframe := rule.timeframe()
+
+	random := rule.generator.Rand()
+	var now time.Time
+	if condition.Aggregation != nil {
+		now = timeline.schedule(random, interArrival, start, events, timeframe)
+	} else {
+		now = timeline.schedule(random, interArrival, start, events, 0)
+	}
+
+	if timeline.NegativesOutsideTimeframe && len(negatives) > 0 {
+		if outside := start.Add(timeframe); now.Before(outside) {
+			now = outside
+		}
+	}
+	return timeline.schedule(random, interArrival, now, negatives, 0)
+}
+
+// schedule assigns timestamps to consecutive events, starting at the given time, and returns the time after the last event.
+// If a window is given, the events are spread evenly over it by default, and squeezed into it if their intervals would spread them over a longer time.
+func (t Timeline) schedule(random *rand.Rand, interArrival InterArrival, start time.Time, events []Event, window time.Duration) time.Time {
+	mean := t.Interval
+	if window > 0 && mean <= 0 && len(events) > 0 {
+		mean = window / time.Duration(len(events))
+	}
+
+	// The last interval separates the events from whatever comes after them
+	intervals := t.intervals(random, interArrival, len(events), mean)
+	if window > 0 && len(events) > 1 {
+		var span time.Duration
+		for _, interval := range intervals[:len(intervals)-1] {
+			span += interval
+		}
+		if limit := window * time.Duration(len(events)-1) / time.Duration(len(events)); span > limit {
+			for i := range intervals[:len(intervals)-1] {
+				intervals[i] = time.Duration(float64(intervals[i]) * float64(limit) / float64(span))
+			}
+		}
+	}
+
+	now := start
+	for i := range events {
+		events[i].Timestamp = now.In(t.location())
+		now = now.Add(intervals[i])
+	}
+	return now
+}
+
+// start returns the time at which the first event of the timeline is logged.
+func (t Timeline) start() time.Time {
+	if t.Start.IsZero() {
+		return time.Now()
+	}
+	return t.Start
+}
+
+// location returns the time zone that the timestamps of the timeline are expressed in.
+func (t Timeline) location() *time.Location {
+	if t.Location == nil {
+		return time.Local
+	}
+	return t.Location
+}
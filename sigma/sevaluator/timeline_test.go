@@ -0,0 +1,118 @@
+package sevaluator_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mtnmunuklu/logen/sigma"
+	"github.com/mtnmunuklu/logen/sigma/sevaluator"
+)
+
+// TestRuleEvaluator_Timeline checks that the events are timestamped along the timeline, and that aggregations stay within the timeframe.
+func TestRuleEvaluator_Timeline(t *testing.T) {
+	config, err := sigma.ParseConfig([]byte(testConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	location, err := time.LoadLocation("Europe/Istanbul")
+	if err != nil {
+		t.Skip("time zone database not available:", err)
+	}
+	start := time.Date(2024, time.January, 2, 15, 4, 5, 0, time.UTC)
+
+	tt := []struct {
+		Name      string
+		Condition string
+		Timeline  sevaluator.Timeline
+		Check     func(t *testing.T, events []sevaluator.Event, negatives []sevaluator.Event)
+	}{
+		{
+			Name:      "Constant",
+			Condition: "selection and not filter",
+			Timeline:  sevaluator.Timeline{Start: start, Interval: time.Second, Location: location},
+			Check: func(t *testing.T, events []sevaluator.Event, negatives []sevaluator.Event) {
+				if !events[0].Timestamp.Equal(start) || events[0].Timestamp.Location() != location {
+					t.Errorf("expected the first event at %v in %v, got %v", start, location, events[0].Timestamp)
+				}
+				for i, negative := range negatives {
+					if expected := start.Add(time.Duration(i+1) * time.Second); !negative.Timestamp.Equal(expected) {
+						t.Errorf("expected negative event %d at %v, got %v", i, expected, negative.Timestamp)
+					}
+				}
+			},
+		},
+		{
+			Name:      "Exponential",
+			Condition: "selection | count() > 20",
+			Timeline:  sevaluator.Timeline{Start: start, Interval: time.Hour, Jitter: time.Minute, Distribution: "exponential"},
+			Check: func(t *testing.T, events []sevaluator.Event, negatives []sevaluator.Event) {
+				for i, event := range events {
+					if offset := event.Timestamp.Sub(start); offset < 0 || offset >= 10*time.Minute {
+						t.Errorf("event logged outside the timeframe at %v", offset)
+					}
+					if i > 0 && event.Timestamp.Before(events[i-1].Timestamp) {
+						t.Errorf("event %d logged before the previous one", i)
+					}
+				}
+			},
+		},
+		{
+			Name:      "NegativesOutsideTimeframe",
+			Condition: "selection | count() > 2",
+			Timeline:  sevaluator.Timeline{Start: start, Distribution: "uniform", NegativesOutsideTimeframe: true},
+			Check: func(t *testing.T, events []sevaluator.Event, negatives []sevaluator.Event) {
+				if len(negatives) == 0 {
+					t.Fatal("expected negative events")
+				}
+				for _, negative := range negatives {
+					if negative.Timestamp.Before(start.Add(10 * time.Minute)) {
+						t.Errorf("negative event logged inside the timeframe at %v", negative.Timestamp)
+					}
+				}
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			rule, err := sigma.ParseRule([]byte(fmt.Sprintf(timelineTestRule, tc.Condition)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := sevaluator.ForRule(rule, sevaluator.WithConfig(config), sevaluator.WithTimeline(tc.Timeline), sevaluator.NegativeSamples).Alters(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !result.Verifications[0].Passed() {
+				t.Errorf("expected the events to pass verification, got %+v", result.Verifications[0])
+			}
+			tc.Check(t, result.Events[0], result.Negatives[0])
+		})
+	}
+
+	// Unknown distributions are rejected
+	rule, err := sigma.ParseRule([]byte(fmt.Sprintf(timelineTestRule, "selection")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sevaluator.ForRule(rule, sevaluator.WithTimeline(sevaluator.Timeline{Distribution: "normal"})).Alters(context.Background()); err == nil {
+		t.Error("expected an error for an unknown distribution")
+	}
+}
+
+const timelineTestRule = `
+title: Timeline Test
+logsource:
+  category: process_creation
+  product: windows
+detection:
+  selection:
+    Image|endswith: '\net.exe'
+  filter:
+    User: SYSTEM
+  timeframe: 10m
+  condition: %s
+`